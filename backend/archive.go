@@ -0,0 +1,685 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// A board archive is a portable copy of one board: its spec (settings,
+// lists, member roles, labels, custom fields), the profiles of every member
+// it references, and its tasks, checklists, links, comments, activities and
+// documents. Importing an archive always creates a new board; all ids are
+// remapped. MoziBoard has no attachments, so archives carry none, and
+// embeddings are not exported (see ?reembed= on import).
+const (
+	archiveFormat  = "moziboard-board-archive"
+	archiveVersion = 1
+)
+
+// Limits on what a zip archive may expand to, so a small upload cannot
+// exhaust memory.
+const (
+	maxArchiveFile  = 16 << 20 // bytes per file
+	maxArchiveTotal = 64 << 20 // bytes across all files
+)
+
+type BoardArchive struct {
+	Format     string        `json:"format"`
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Board      BoardSpec     `json:"board"`
+	Members    []Member      `json:"members"`
+	Tasks      []ArchiveTask `json:"tasks"`
+	Links      []TaskLink    `json:"links"`
+	Comments   []Comment     `json:"comments"`
+	Activities []Activity    `json:"activities"`
+	Docs       []ArchiveDoc  `json:"docs"`
+}
+
+// ArchiveTask is a task with its checklist. Labels are matched by name on
+// import.
+type ArchiveTask struct {
+	Task
+	Checklist []ChecklistItem `json:"checklist"`
+}
+
+// ArchiveDoc is a document. In a zip archive its content lives in the
+// Markdown file named by File instead.
+type ArchiveDoc struct {
+	Document
+	File string `json:"file,omitempty"`
+}
+
+// Member conflict modes for import: keep existing profiles, overwrite them
+// from the archive, or fail when an existing profile differs.
+const (
+	memberConflictKeep   = "keep"
+	memberConflictUpdate = "update"
+	memberConflictFail   = "fail"
+)
+
+type ArchiveImportOptions struct {
+	Title   string // overrides the archived board title
+	Members string // member conflict mode
+	Owner   string // member made owner of the new board, if any
+}
+
+type ArchiveImportResult struct {
+	BoardID string `json:"board_id"`
+	// Created counts the rows created by kind.
+	Created map[string]int `json:"created"`
+	// Members reports, per archived member, whether it was created, updated or kept.
+	Members  map[string]string `json:"members"`
+	TaskIDs  map[int]int       `json:"task_ids"`
+	DocIDs   map[int]int       `json:"doc_ids"`
+	Warnings []string          `json:"warnings"`
+}
+
+func exportBoardArchive(q dbtx, boardID string) (BoardArchive, error) {
+	ctx := context.Background()
+	a := BoardArchive{Format: archiveFormat, Version: archiveVersion, ExportedAt: time.Now().UTC(),
+		Members: []Member{}, Tasks: []ArchiveTask{}, Comments: []Comment{}, Activities: []Activity{}, Docs: []ArchiveDoc{}}
+	var err error
+	if a.Board, err = exportBoardSpec(q, boardID); err != nil {
+		return a, err
+	}
+	a.Board.ID = ""
+
+	rows, err := q.Query(ctx, "SELECT "+taskColumns+" FROM tasks t WHERE t.board_id=$1 ORDER BY t.id", boardID)
+	if err != nil {
+		return a, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return a, err
+	}
+	if err := attachLabels(q, tasks); err != nil {
+		return a, err
+	}
+	index := make(map[int]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+		a.Tasks = append(a.Tasks, ArchiveTask{Task: t, Checklist: []ChecklistItem{}})
+	}
+	rows, err = q.Query(ctx, `
+		SELECT `+checklistColumns+` FROM checklist_items
+		WHERE task_id IN (SELECT id FROM tasks WHERE board_id=$1) ORDER BY task_id, position, id`, boardID)
+	if err != nil {
+		return a, err
+	}
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			rows.Close()
+			return a, err
+		}
+		t := &a.Tasks[index[item.TaskID]]
+		t.Checklist = append(t.Checklist, item)
+	}
+	rows.Close()
+
+	// Links to tasks on other boards are left out.
+	rows, err = q.Query(ctx, `
+		SELECT id, task_id, target_id, type, COALESCE(created_by, '') FROM task_links
+		WHERE task_id IN (SELECT id FROM tasks WHERE board_id=$1) AND target_id IN (SELECT id FROM tasks WHERE board_id=$1)
+		ORDER BY id`, boardID)
+	if err != nil {
+		return a, err
+	}
+	if a.Links, err = scanLinks(rows); err != nil {
+		return a, err
+	}
+
+	rows, err = q.Query(ctx, `
+		SELECT c.id, c.task_id, c.user_id, c.content, c.created_at FROM comments c
+		JOIN tasks t ON t.id = c.task_id WHERE t.board_id=$1 ORDER BY c.id`, boardID)
+	if err != nil {
+		return a, err
+	}
+	for rows.Next() {
+		var cm Comment
+		if err := rows.Scan(&cm.ID, &cm.TaskID, &cm.UserID, &cm.Content, &cm.CreatedAt); err != nil {
+			rows.Close()
+			return a, err
+		}
+		a.Comments = append(a.Comments, cm)
+	}
+	rows.Close()
+
+	rows, err = q.Query(ctx, `
+		SELECT a.id, a.task_id, a.user_id, a.action, COALESCE(a.details, ''), a.created_at FROM activities a
+		JOIN tasks t ON t.id = a.task_id WHERE t.board_id=$1 ORDER BY a.id`, boardID)
+	if err != nil {
+		return a, err
+	}
+	for rows.Next() {
+		var act Activity
+		if err := rows.Scan(&act.ID, &act.TaskID, &act.UserID, &act.Action, &act.Details, &act.CreatedAt); err != nil {
+			rows.Close()
+			return a, err
+		}
+		a.Activities = append(a.Activities, act)
+	}
+	rows.Close()
+
+	rows, err = q.Query(ctx, `
+		SELECT id, board_id::text, title, COALESCE(content, ''), created_at, updated_at FROM documents
+		WHERE board_id=$1 ORDER BY id`, boardID)
+	if err != nil {
+		return a, err
+	}
+	for rows.Next() {
+		var d ArchiveDoc
+		if err := rows.Scan(&d.ID, &d.BoardID, &d.Title, &d.Content, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			rows.Close()
+			return a, err
+		}
+		a.Docs = append(a.Docs, d)
+	}
+	rows.Close()
+
+	// Every member the board refers to travels with it, so assignees and
+	// authors can be recreated on another instance.
+	ids := map[string]bool{}
+	for _, m := range a.Board.Members {
+		ids[m.MemberID] = true
+	}
+	for _, t := range a.Tasks {
+		if t.AssigneeID != nil {
+			ids[*t.AssigneeID] = true
+		}
+		for _, item := range t.Checklist {
+			if item.AssigneeID != nil {
+				ids[*item.AssigneeID] = true
+			}
+		}
+	}
+	for _, cm := range a.Comments {
+		ids[cm.UserID] = true
+	}
+	for _, act := range a.Activities {
+		ids[act.UserID] = true
+	}
+	memberIDs := make([]string, 0, len(ids))
+	for id := range ids {
+		memberIDs = append(memberIDs, id)
+	}
+	rows, err = q.Query(ctx, "SELECT "+memberColumns+" FROM members m WHERE m.id = ANY($1) ORDER BY m.id", memberIDs)
+	if err != nil {
+		return a, err
+	}
+	a.Members, err = scanMembers(rows)
+	return a, err
+}
+
+// writeArchiveZip writes a as a zip holding board.json and one Markdown file
+// per document under docs/.
+func writeArchiveZip(w io.Writer, a BoardArchive) error {
+	zw := zip.NewWriter(w)
+	docs := make([]ArchiveDoc, len(a.Docs))
+	files := make([][]byte, len(a.Docs))
+	for i, d := range a.Docs {
+		files[i] = []byte("# " + d.Title + "\n\n" + d.Content)
+		if !bytes.HasSuffix(files[i], []byte("\n")) {
+			files[i] = append(files[i], '\n')
+		}
+		d.File = fmt.Sprintf("docs/%d-%s.md", d.ID, slugify(d.Title))
+		d.Content = ""
+		docs[i] = d
+	}
+	a.Docs = docs
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := zw.Create("board.json")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	for i, d := range docs {
+		f, err := zw.Create(d.File)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(files[i]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// parseArchive decodes a JSON archive or a zip written by writeArchiveZip.
+// A document's Markdown file supplies its content and, through its leading
+// "# " heading, its title.
+func parseArchive(data []byte) (BoardArchive, error) {
+	var a BoardArchive
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if err := json.Unmarshal(data, &a); err != nil {
+			return a, err
+		}
+		return a, checkArchive(&a)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return a, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	remaining := int64(maxArchiveTotal)
+	readFile := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("archive has no %s", name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		limit := min(int64(maxArchiveFile), remaining)
+		data, err := io.ReadAll(io.LimitReader(r, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("%s: archive expands past %d MB per file or %d MB in total", name, maxArchiveFile>>20, maxArchiveTotal>>20)
+		}
+		remaining -= int64(len(data))
+		return data, nil
+	}
+	manifest, err := readFile("board.json")
+	if err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(manifest, &a); err != nil {
+		return a, fmt.Errorf("board.json: %w", err)
+	}
+	for i := range a.Docs {
+		d := &a.Docs[i]
+		if d.File == "" {
+			continue
+		}
+		markdown, err := readFile(d.File)
+		if err != nil {
+			return a, err
+		}
+		title, content := splitMarkdownTitle(string(markdown))
+		if title != "" {
+			d.Title = title
+		}
+		d.Content = content
+	}
+	return a, checkArchive(&a)
+}
+
+func checkArchive(a *BoardArchive) error {
+	if a.Format != archiveFormat {
+		return fmt.Errorf("not a board archive (format %q)", a.Format)
+	}
+	if a.Version < 1 || a.Version > archiveVersion {
+		return fmt.Errorf("unsupported archive version %d (this server reads up to %d)", a.Version, archiveVersion)
+	}
+	return nil
+}
+
+// importBoardArchive recreates a's board in one transaction and reports
+// how archived ids map to the new ones.
+func importBoardArchive(a *BoardArchive, opts ArchiveImportOptions) (ArchiveImportResult, error) {
+	ctx := context.Background()
+	res := ArchiveImportResult{Created: map[string]int{}, Members: map[string]string{},
+		TaskIDs: map[int]int{}, DocIDs: map[int]int{}, Warnings: []string{}}
+	warn := func(format string, args ...interface{}) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf(format, args...))
+	}
+	if opts.Members == "" {
+		opts.Members = memberConflictKeep
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback(ctx)
+
+	active := map[string]bool{}
+	for _, m := range a.Members {
+		existing, err := scanMember(tx.QueryRow(ctx, "SELECT "+memberColumns+" FROM members m WHERE m.id=$1", m.ID))
+		if err == pgx.ErrNoRows {
+			if err := validateMember(&m); err != nil {
+				return res, badRequest(fmt.Errorf("member %q: %w", m.ID, err))
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO members (id, name, kind, role, avatar, email, title, bio, active, deactivated_at)
+				VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8, $9)`,
+				m.ID, m.Name, m.Kind, m.Avatar, m.Email, m.Title, m.Bio, m.Active, m.DeactivatedAt); err != nil {
+				return res, err
+			}
+			res.Members[m.ID] = "created"
+			active[m.ID] = m.Active
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		active[m.ID] = existing.Active
+		same := existing.Name == m.Name && existing.Kind == m.Kind && existing.Email == m.Email
+		switch {
+		case same || opts.Members == memberConflictKeep:
+			res.Members[m.ID] = "kept"
+		case opts.Members == memberConflictFail:
+			return res, fiber.NewError(409, fmt.Sprintf("Member %q already exists with a different profile", m.ID))
+		default:
+			if err := validateMember(&m); err != nil {
+				return res, badRequest(fmt.Errorf("member %q: %w", m.ID, err))
+			}
+			if _, err := tx.Exec(ctx,
+				"UPDATE members SET name=$2, kind=$3, role=$3, avatar=$4, email=$5, title=$6, bio=$7 WHERE id=$1",
+				m.ID, m.Name, m.Kind, m.Avatar, m.Email, m.Title, m.Bio); err != nil {
+				return res, err
+			}
+			res.Members[m.ID] = "updated"
+		}
+	}
+	isMember := func(id string) bool {
+		if _, ok := active[id]; !ok {
+			var exists bool
+			tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM members WHERE id=$1)", id).Scan(&exists)
+			if !exists {
+				return false
+			}
+			active[id] = true
+		}
+		return true
+	}
+
+	spec := a.Board
+	spec.ID = ""
+	if opts.Title != "" {
+		spec.Title = opts.Title
+	}
+	roles := []BoardMemberReq{}
+	for _, m := range spec.Members {
+		switch {
+		case m.MemberID == opts.Owner:
+		case !isMember(m.MemberID) || !active[m.MemberID]:
+			warn("member %s is missing or inactive and was not added to the board", m.MemberID)
+		default:
+			roles = append(roles, m)
+		}
+	}
+	if opts.Owner != "" {
+		roles = append(roles, BoardMemberReq{MemberID: opts.Owner, Role: RoleOwner})
+	}
+	spec.Members = roles
+	if err := validateBoardSpec(&spec); err != nil {
+		return res, badRequest(err)
+	}
+	plan, err := reconcileBoard(tx, &spec, true)
+	if err != nil {
+		return res, err
+	}
+	res.BoardID = plan.BoardID
+
+	lists, err := loadLists(tx, res.BoardID)
+	if err != nil {
+		return res, err
+	}
+	if len(lists) == 0 {
+		return res, badRequest(fmt.Errorf("the archived board has no lists"))
+	}
+	listIDs := make(map[string]bool, len(lists))
+	for _, l := range lists {
+		listIDs[l.ID] = true
+	}
+
+	labels, err := loadLabels(tx, res.BoardID)
+	if err != nil {
+		return res, err
+	}
+	labelIDs := make(map[string]int, len(labels))
+	for _, l := range labels {
+		labelIDs[strings.ToLower(l.Name)] = l.ID
+	}
+	res.Created["labels"] = len(labels)
+	res.Created["custom_fields"] = len(spec.Fields)
+
+	memberOrNil := func(id *string, what string) *string {
+		if id == nil || isMember(*id) {
+			return id
+		}
+		warn("%s: unknown member %s was dropped", what, *id)
+		return nil
+	}
+	for _, t := range a.Tasks {
+		if t.Priority == "" {
+			t.Priority = PriorityMedium
+		}
+		if t.CustomFields == nil {
+			t.CustomFields = map[string]interface{}{}
+		}
+		if !listIDs[t.ListID] {
+			warn("task %d: list %q is not on the board; moved to %s", t.ID, t.ListID, lists[0].ID)
+			t.ListID = lists[0].ID
+		}
+		var id int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO tasks (board_id, title, description, list_id, position, assignee_id,
+				priority, start_date, due_date, estimate, estimate_unit, completed_at, custom_fields)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13) RETURNING id`,
+			res.BoardID, t.Title, t.Description, t.ListID, t.Position, memberOrNil(t.AssigneeID, fmt.Sprintf("task %d assignee", t.ID)),
+			t.Priority, t.StartDate, t.DueDate, t.Estimate, t.EstimateUnit, t.CompletedAt, t.CustomFields).Scan(&id)
+		if err != nil {
+			return res, err
+		}
+		res.TaskIDs[t.ID] = id
+		for _, l := range t.Labels {
+			labelID, ok := labelIDs[strings.ToLower(l.Name)]
+			if !ok {
+				warn("task %d: label %q is not defined on the board", t.ID, l.Name)
+				continue
+			}
+			if _, err := tx.Exec(ctx, "INSERT INTO task_labels (task_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, labelID); err != nil {
+				return res, err
+			}
+		}
+		for _, item := range t.Checklist {
+			if _, err := tx.Exec(ctx,
+				"INSERT INTO checklist_items (task_id, position, text, done, assignee_id, completed_at) VALUES ($1, $2, $3, $4, $5, $6)",
+				id, item.Position, item.Text, item.Done, memberOrNil(item.AssigneeID, fmt.Sprintf("task %d checklist", t.ID)), item.CompletedAt); err != nil {
+				return res, err
+			}
+			res.Created["checklist_items"]++
+		}
+	}
+	res.Created["tasks"] = len(res.TaskIDs)
+	for _, t := range a.Tasks {
+		if t.ParentID == nil {
+			continue
+		}
+		parent, ok := res.TaskIDs[*t.ParentID]
+		if !ok {
+			warn("task %d: parent %d is not in the archive", t.ID, *t.ParentID)
+			continue
+		}
+		if _, err := tx.Exec(ctx, "UPDATE tasks SET parent_id=$1 WHERE id=$2", parent, res.TaskIDs[t.ID]); err != nil {
+			return res, err
+		}
+	}
+
+	for _, l := range a.Links {
+		from, ok1 := res.TaskIDs[l.TaskID]
+		to, ok2 := res.TaskIDs[l.TargetID]
+		if !ok1 || !ok2 {
+			warn("link %d: task %d or %d is not in the archive", l.ID, l.TaskID, l.TargetID)
+			continue
+		}
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO task_links (task_id, target_id, type, created_by) VALUES ($1, $2, $3, NULLIF($4, '')) ON CONFLICT DO NOTHING",
+			from, to, l.Type, l.CreatedBy); err != nil {
+			return res, err
+		}
+		res.Created["links"]++
+	}
+	for _, cm := range a.Comments {
+		taskID, ok := res.TaskIDs[cm.TaskID]
+		if !ok {
+			warn("comment %d: task %d is not in the archive", cm.ID, cm.TaskID)
+			continue
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO comments (task_id, user_id, content, created_at) VALUES ($1, $2, $3, $4)",
+			taskID, cm.UserID, cm.Content, cm.CreatedAt); err != nil {
+			return res, err
+		}
+		res.Created["comments"]++
+	}
+	for _, act := range a.Activities {
+		taskID, ok := res.TaskIDs[act.TaskID]
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO activities (task_id, user_id, action, details, created_at) VALUES ($1, $2, $3, $4, $5)",
+			taskID, act.UserID, act.Action, act.Details, act.CreatedAt); err != nil {
+			return res, err
+		}
+		res.Created["activities"]++
+	}
+	for _, d := range a.Docs {
+		var id int
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO documents (board_id, title, content, created_at, updated_at)
+			VALUES ($1, $2, $3, COALESCE($4, CURRENT_TIMESTAMP), COALESCE($5, CURRENT_TIMESTAMP)) RETURNING id`,
+			res.BoardID, d.Title, d.Content, nullTime(d.CreatedAt), nullTime(d.UpdatedAt)).Scan(&id); err != nil {
+			return res, err
+		}
+		res.DocIDs[d.ID] = id
+	}
+	res.Created["docs"] = len(res.DocIDs)
+	sort.Strings(res.Warnings)
+	return res, tx.Commit(ctx)
+}
+
+// reembedArchive embeds the tasks and documents created from a, returning
+// the number of failures.
+func reembedArchive(a *BoardArchive, res ArchiveImportResult) int {
+	failed := 0
+	for i := range a.Tasks {
+		t := a.Tasks[i].Task
+		if err := storeEmbedding("tasks", res.TaskIDs[t.ID], taskEmbeddingText(&t)); err != nil {
+			log.Printf("Emb err: task %d: %v", res.TaskIDs[t.ID], err)
+			failed++
+		}
+	}
+	for _, d := range a.Docs {
+		if err := storeEmbedding("documents", res.DocIDs[d.ID], d.Title+" "+d.Content); err != nil {
+			log.Printf("Emb err: document %d: %v", res.DocIDs[d.ID], err)
+			failed++
+		}
+	}
+	return failed
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// slugify turns a title into a lowercase file-name fragment.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if len(s) > 60 {
+		s = strings.TrimSuffix(s[:60], "-")
+	}
+	if s == "" {
+		return "untitled"
+	}
+	return s
+}
+
+// exportBoard handles GET /api/boards/:id/export. The archive is JSON with
+// documents inline, or a zip with Markdown documents for ?format=zip.
+func exportBoard(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	if _, err := loadBoard(boardID); err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	} else if err != nil {
+		return err
+	}
+	if _, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor, RoleViewer); err != nil {
+		return err
+	}
+	a, err := exportBoardArchive(db, boardID)
+	if err != nil {
+		return err
+	}
+	switch c.Query("format", "json") {
+	case "json":
+		return c.JSON(a)
+	case "zip":
+		var buf bytes.Buffer
+		if err := writeArchiveZip(&buf, a); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, "application/zip")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.zip"`, slugify(a.Board.Title)))
+		return c.Send(buf.Bytes())
+	}
+	return invalidField("format", "must be json or zip")
+}
+
+// importBoard handles POST /api/boards/import with a JSON or zip archive
+// as the body. The caller becomes an owner of the new board. Member profiles
+// are global, so ?members=update is left to "moziboard admin import".
+func importBoard(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	opts := ArchiveImportOptions{Title: c.Query("title"), Members: c.Query("members", memberConflictKeep), Owner: caller}
+	if !contains([]string{memberConflictKeep, memberConflictFail}, opts.Members) {
+		return invalidField("members", "must be keep or fail")
+	}
+	a, err := parseArchive(c.Body())
+	if err != nil {
+		return badRequest(err)
+	}
+	res, err := importBoardArchive(&a, opts)
+	if err != nil {
+		return err
+	}
+	if c.QueryBool("reembed") && embedFor(c, len(a.Tasks)+len(a.Docs)) {
+		go reembedArchive(&a, res)
+	}
+	if b, err := loadBoard(res.BoardID); err == nil {
+		emitEvent(res.BoardID, EventBoardCreated, nil, caller, b)
+	}
+	return c.Status(201).JSON(res)
+}
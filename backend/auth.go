@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// callerID returns the member making the request, or "" when anonymous.
+// Only an API token (see tokenAuth) identifies the caller.
+func callerID(c *fiber.Ctx) string {
+	id, _ := c.Locals(callerLocal).(string)
+	return id
+}
+
+// requireCaller returns the calling member, rejecting anonymous requests and
+// unknown or deactivated members.
+func requireCaller(c *fiber.Ctx) (string, error) {
+	id := callerID(c)
+	if id == "" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="moziboard"`)
+		return "", fiber.NewError(401, "An API token is required")
+	}
+	var active bool
+	err := db.QueryRow(context.Background(), "SELECT active FROM members WHERE id=$1", id).Scan(&active)
+	if err != nil || !active {
+		return "", fiber.NewError(401, "Unknown or inactive member")
+	}
+	return id, nil
+}
+
+// isAdmin reports whether memberID is listed in ADMIN_MEMBERS, a
+// comma-separated list of member ids.
+func isAdmin(memberID string) bool {
+	for _, id := range strings.Split(os.Getenv("ADMIN_MEMBERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" && id == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin resolves the caller and checks they are an admin.
+func requireAdmin(c *fiber.Ctx) (string, error) {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return "", err
+	}
+	if !isAdmin(caller) {
+		return "", fiber.NewError(403, "Only admins can do this")
+	}
+	return caller, nil
+}
+
+// boardRole returns the caller's role on a board, or "" if not a member.
+func boardRole(boardID, memberID string) string {
+	var role string
+	db.QueryRow(context.Background(),
+		"SELECT role FROM board_members WHERE board_id=$1 AND member_id=$2",
+		boardID, memberID).Scan(&role)
+	return role
+}
+
+// requireBoardRole resolves the caller and checks they hold one of roles on
+// the board.
+func requireBoardRole(c *fiber.Ctx, boardID string, roles ...string) (string, error) {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return "", err
+	}
+	role := boardRole(boardID, caller)
+	for _, r := range roles {
+		if role == r {
+			return caller, nil
+		}
+	}
+	return "", fiber.NewError(403, "Insufficient board role")
+}
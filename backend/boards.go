@@ -0,0 +1,641 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Board roles held in board_members.role.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+const (
+	EventBoardCreated  = "board.created"
+	EventBoardUpdated  = "board.updated"
+	EventBoardArchived = "board.archived"
+)
+
+type ListCategory string
+
+const (
+	ListTodo  ListCategory = "todo"
+	ListDoing ListCategory = "doing"
+	ListDone  ListCategory = "done"
+)
+
+func (c ListCategory) Valid() bool {
+	switch c {
+	case ListTodo, ListDoing, ListDone:
+		return true
+	}
+	return false
+}
+
+type List struct {
+	ID       string       `json:"id" yaml:"id"`
+	Title    string       `json:"title" yaml:"title"`
+	Position int          `json:"position" yaml:"position"`
+	Category ListCategory `json:"category" yaml:"category"`
+}
+
+var defaultLists = []List{
+	{ID: "backlog", Title: "Backlog", Position: 0, Category: ListTodo},
+	{ID: "todo", Title: "To Do", Position: 1, Category: ListTodo},
+	{ID: "doing", Title: "In Progress", Position: 2, Category: ListDoing},
+	{ID: "done", Title: "Done", Position: 3, Category: ListDone},
+}
+
+type BoardSettings struct {
+	// DefaultListID is used for new tasks created without a list_id.
+	DefaultListID string `json:"default_list_id,omitempty" yaml:"default_list_id,omitempty"`
+	// Transitions restricts which lists a task may move between. When empty,
+	// any move is allowed.
+	Transitions []TransitionRule `json:"transitions,omitempty" yaml:"transitions,omitempty"`
+	// LabelRouting auto-assigns tasks by label.
+	LabelRouting []LabelRoute `json:"label_routing,omitempty" yaml:"label_routing,omitempty"`
+	// RequireSubtasksDone blocks moving a task to a done list while any of
+	// its subtasks are still open.
+	RequireSubtasksDone bool `json:"require_subtasks_done,omitempty" yaml:"require_subtasks_done,omitempty"`
+}
+
+// TransitionRule allows moving tasks from list From (or "*" for any list) to
+// each list in To ("*" for any list).
+type TransitionRule struct {
+	From string   `json:"from" yaml:"from"`
+	To   []string `json:"to" yaml:"to"`
+}
+
+// AllowsMove reports whether the board's transition rules permit a move.
+func (s BoardSettings) AllowsMove(from, to string) bool {
+	if len(s.Transitions) == 0 || from == to {
+		return true
+	}
+	for _, r := range s.Transitions {
+		if r.From != "*" && r.From != from {
+			continue
+		}
+		for _, t := range r.To {
+			if t == "*" || t == to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeLists fills in list defaults and validates ids and categories,
+// returning the set of list ids.
+func normalizeLists(lists []List) (map[string]bool, error) {
+	listIDs := map[string]bool{}
+	for i := range lists {
+		l := &lists[i]
+		if l.ID == "" || listIDs[l.ID] {
+			return nil, fmt.Errorf("lists[%d]: id is missing or duplicated", i)
+		}
+		if l.Title == "" {
+			l.Title = l.ID
+		}
+		if l.Category == "" {
+			l.Category = ListTodo
+		}
+		if !l.Category.Valid() {
+			return nil, fmt.Errorf("lists[%d]: category must be one of todo, doing, done", i)
+		}
+		if l.Position == 0 {
+			l.Position = i
+		}
+		listIDs[l.ID] = true
+	}
+	return listIDs, nil
+}
+
+// validateSettings checks that settings only reference existing lists.
+func validateSettings(s BoardSettings, listIDs map[string]bool) error {
+	if s.DefaultListID != "" && !listIDs[s.DefaultListID] {
+		return fmt.Errorf("settings.default_list_id %q is not a list", s.DefaultListID)
+	}
+	for i, r := range s.Transitions {
+		if r.From != "*" && !listIDs[r.From] {
+			return fmt.Errorf("settings.transitions[%d]: from %q is not a list", i, r.From)
+		}
+		for _, to := range r.To {
+			if to != "*" && !listIDs[to] {
+				return fmt.Errorf("settings.transitions[%d]: to %q is not a list", i, to)
+			}
+		}
+	}
+	for i, r := range s.LabelRouting {
+		if strings.TrimSpace(r.Label) == "" || r.AssigneeID == "" {
+			return fmt.Errorf("settings.label_routing[%d]: label and assignee_id are required", i)
+		}
+	}
+	return nil
+}
+
+type Board struct {
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Settings    BoardSettings `json:"settings"`
+	Archived    bool          `json:"archived"`
+	ArchivedAt  *time.Time    `json:"archived_at,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// BoardPatch carries the fields of PATCH /api/boards/:id; nil means unchanged.
+type BoardPatch struct {
+	Title       *string        `json:"title"`
+	Description *string        `json:"description"`
+	Settings    *BoardSettings `json:"settings"`
+}
+
+type CloneBoardReq struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	IncludeTasks bool   `json:"include_tasks"`
+	IncludeDocs  bool   `json:"include_docs"`
+}
+
+// dbtx is satisfied by both the pool and a transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const boardColumns = "id::text, title, COALESCE(description, ''), settings, archived_at, created_at"
+
+func scanBoard(row pgx.Row) (Board, error) {
+	var b Board
+	err := row.Scan(&b.ID, &b.Title, &b.Description, &b.Settings, &b.ArchivedAt, &b.CreatedAt)
+	b.Archived = b.ArchivedAt != nil
+	return b, err
+}
+
+func loadBoard(boardID string) (Board, error) {
+	return scanBoard(db.QueryRow(context.Background(), "SELECT "+boardColumns+" FROM boards WHERE id=$1", boardID))
+}
+
+func loadLists(q dbtx, boardID string) ([]List, error) {
+	rows, err := q.Query(context.Background(),
+		"SELECT id, title, position, category FROM lists WHERE board_id=$1 ORDER BY position ASC, id ASC", boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lists []List
+	for rows.Next() {
+		var l List
+		if err := rows.Scan(&l.ID, &l.Title, &l.Position, &l.Category); err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	if lists == nil {
+		lists = []List{}
+	}
+	return lists, rows.Err()
+}
+
+func insertLists(q dbtx, boardID string, lists []List) error {
+	for _, l := range lists {
+		_, err := q.Exec(context.Background(),
+			"INSERT INTO lists (board_id, id, title, position, category) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING",
+			boardID, l.ID, l.Title, l.Position, l.Category)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedBoardLists gives boards created before lists existed the default lists
+// plus any list_id their tasks already use.
+func seedBoardLists() {
+	rows, err := db.Query(context.Background(), "SELECT id::text FROM boards b WHERE NOT EXISTS (SELECT 1 FROM lists l WHERE l.board_id = b.id)")
+	if err != nil {
+		fmt.Println("seedBoardLists: failed to query boards:", err)
+		return
+	}
+	var boardIDs []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		boardIDs = append(boardIDs, id)
+	}
+	rows.Close()
+
+	for _, bid := range boardIDs {
+		insertLists(db, bid, defaultLists)
+		db.Exec(context.Background(), `
+			INSERT INTO lists (board_id, id, title, position, category)
+			SELECT DISTINCT board_id, list_id, list_id, $2::int, 'todo' FROM tasks WHERE board_id=$1
+			ON CONFLICT DO NOTHING`, bid, len(defaultLists))
+	}
+}
+
+func getBoards(c *fiber.Ctx) error {
+	page, err := parsePage(c, "created")
+	if err != nil {
+		return err
+	}
+	where := []string{"TRUE"}
+	var args []interface{}
+	if !c.QueryBool("include_archived") {
+		where = append(where, "archived_at IS NULL")
+	}
+	var after time.Time
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		args = append(args, after, page.Cursor.Key)
+		where = append(where, "(created_at, id) > ($1, $2::uuid)")
+	}
+	rows, err := db.Query(context.Background(),
+		"SELECT "+boardColumns+" FROM boards WHERE "+strings.Join(where, " AND ")+" ORDER BY created_at ASC, id ASC"+page.limitSQL(), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var boards []Board
+	for rows.Next() {
+		b, err := scanBoard(rows)
+		if err != nil {
+			return err
+		}
+		boards = append(boards, b)
+	}
+	return sendPage(c, page, boards, func(b Board) pageCursor { return cursorAt("created", b.CreatedAt, 0, b.ID) })
+}
+
+func getBoard(c *fiber.Ctx) error {
+	b, err := loadBoard(c.Params("id"))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(b)
+}
+
+func getBoardLists(c *fiber.Ctx) error {
+	lists, err := loadLists(db, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(lists)
+}
+
+func createBoard(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	b := new(Board)
+	if err := parseBody(c, b); err != nil {
+		return err
+	}
+	if name := c.Query("template"); name != "" {
+		return createBoardFromTemplate(c, caller, b, name)
+	}
+	if strings.TrimSpace(b.Title) == "" {
+		return invalidField("title", "is required")
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := scanBoard(tx.QueryRow(ctx,
+		"INSERT INTO boards (title, description, settings) VALUES ($1, $2, $3) RETURNING "+boardColumns,
+		b.Title, b.Description, b.Settings))
+	if err != nil {
+		return err
+	}
+	if err := insertLists(tx, created.ID, defaultLists); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3)", created.ID, caller, RoleOwner); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	emitEvent(created.ID, EventBoardCreated, nil, caller, created)
+	return c.JSON(created)
+}
+
+func updateBoard(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	b, err := loadBoard(boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	req := new(BoardPatch)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	if req.Title != nil {
+		if strings.TrimSpace(*req.Title) == "" {
+			return invalidField("title", "cannot be empty")
+		}
+		b.Title = *req.Title
+	}
+	if req.Description != nil {
+		b.Description = *req.Description
+	}
+	if req.Settings != nil {
+		lists, err := loadLists(db, boardID)
+		if err != nil {
+			return err
+		}
+		listIDs, _ := normalizeLists(lists)
+		if err := validateSettings(*req.Settings, listIDs); err != nil {
+			return badRequest(err)
+		}
+		b.Settings = *req.Settings
+	}
+
+	b, err = scanBoard(db.QueryRow(context.Background(),
+		"UPDATE boards SET title=$1, description=$2, settings=$3 WHERE id=$4 RETURNING "+boardColumns,
+		b.Title, b.Description, b.Settings, boardID))
+	if err != nil {
+		return err
+	}
+	emitEvent(boardID, EventBoardUpdated, nil, caller, b)
+	return c.JSON(b)
+}
+
+func setBoardArchived(c *fiber.Ctx, archived bool) error {
+	boardID := c.Params("id")
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	b, err := scanBoard(db.QueryRow(context.Background(),
+		"UPDATE boards SET archived_at = CASE WHEN $1 THEN COALESCE(archived_at, CURRENT_TIMESTAMP) END WHERE id=$2 RETURNING "+boardColumns,
+		archived, boardID))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	eventType := EventBoardUpdated
+	if archived {
+		eventType = EventBoardArchived
+	}
+	emitEvent(boardID, eventType, nil, caller, b)
+	return c.JSON(b)
+}
+
+func archiveBoard(c *fiber.Ctx) error   { return setBoardArchived(c, true) }
+func unarchiveBoard(c *fiber.Ctx) error { return setBoardArchived(c, false) }
+
+const deleteTokenTTL = 5 * time.Minute
+
+var deleteTokenKey = func() []byte {
+	k := make([]byte, 32)
+	rand.Read(k)
+	return k
+}()
+
+func boardDeleteToken(boardID, caller string, expires int64) string {
+	mac := hmac.New(sha256.New, deleteTokenKey)
+	fmt.Fprintf(mac, "%s|%s|%d", boardID, caller, expires)
+	return fmt.Sprintf("%d.%s", expires, hex.EncodeToString(mac.Sum(nil))[:24])
+}
+
+func validBoardDeleteToken(boardID, caller, token string) bool {
+	expStr, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(boardDeleteToken(boardID, caller, expires)))
+}
+
+// deleteBoard permanently removes a board. It is a two-step operation: a call
+// without ?confirm= returns a short-lived token that must be sent back.
+func deleteBoard(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	b, err := loadBoard(boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, boardID, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	token := c.Query("confirm")
+	if token == "" {
+		expires := time.Now().Add(deleteTokenTTL).Unix()
+		return c.Status(202).JSON(fiber.Map{
+			"board":         b,
+			"confirm_token": boardDeleteToken(boardID, caller, expires),
+			"expires_at":    time.Unix(expires, 0).UTC(),
+		})
+	}
+	if !validBoardDeleteToken(boardID, caller, token) {
+		return fiber.NewError(400, "Invalid or expired confirmation token")
+	}
+	if _, err := db.Exec(context.Background(), "DELETE FROM boards WHERE id=$1", boardID); err != nil {
+		return err
+	}
+	invalidateCache(boardID)
+	return c.SendStatus(200)
+}
+
+// copyBoardTasks copies every task of src, with its labels and checklist, into
+// dst (whose labels must already exist). Parent links and task links are
+// remapped to the copies. It returns the mapping from source to new task IDs.
+func copyBoardTasks(tx pgx.Tx, srcID, dstID string) (map[int]int, error) {
+	ctx := context.Background()
+	rows, err := tx.Query(ctx, "SELECT id, parent_id FROM tasks WHERE board_id=$1 ORDER BY id", srcID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	parents := map[int]int{}
+	for rows.Next() {
+		var id int
+		var parentID *int
+		rows.Scan(&id, &parentID)
+		ids = append(ids, id)
+		if parentID != nil {
+			parents[id] = *parentID
+		}
+	}
+	rows.Close()
+
+	mapping := make(map[int]int, len(ids))
+	for _, oldID := range ids {
+		var newID int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO tasks (board_id, title, description, list_id, position, assignee_id, embedding,
+				priority, start_date, due_date, estimate, estimate_unit, completed_at, custom_fields)
+			SELECT $1, title, description, list_id, position, assignee_id, embedding,
+				priority, start_date, due_date, estimate, estimate_unit, completed_at, custom_fields FROM tasks WHERE id=$2
+			RETURNING id`, dstID, oldID).Scan(&newID)
+		if err != nil {
+			return nil, err
+		}
+		mapping[oldID] = newID
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO task_labels (task_id, label_id)
+			SELECT $1, dl.id FROM task_labels tl
+			JOIN labels sl ON sl.id = tl.label_id
+			JOIN labels dl ON dl.board_id = $3 AND lower(dl.name) = lower(sl.name)
+			WHERE tl.task_id = $2`, newID, oldID, dstID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO checklist_items (task_id, position, text, done, assignee_id, completed_at)
+			SELECT $1, position, text, done, assignee_id, completed_at FROM checklist_items WHERE task_id=$2 ORDER BY position, id`,
+			newID, oldID); err != nil {
+			return nil, err
+		}
+	}
+	for oldID, parentID := range parents {
+		if newParent, ok := mapping[parentID]; ok {
+			if _, err := tx.Exec(ctx, "UPDATE tasks SET parent_id=$1 WHERE id=$2", newParent, mapping[oldID]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Links between copied tasks are copied too; links leaving the board are not.
+	rows, err = tx.Query(ctx, "SELECT id, task_id, target_id, type, COALESCE(created_by, '') FROM task_links WHERE task_id = ANY($1) AND target_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	links, err := scanLinks(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		if _, err := tx.Exec(ctx, "INSERT INTO task_links (task_id, target_id, type, created_by) VALUES ($1, $2, $3, NULLIF($4, ''))",
+			mapping[l.TaskID], mapping[l.TargetID], l.Type, l.CreatedBy); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// cloneBoard copies a board's lists, labels, custom fields, settings and members, and optionally its
+// tasks and documents, into a new board owned by the caller.
+func cloneBoard(c *fiber.Ctx) error {
+	srcID := c.Params("id")
+	src, err := loadBoard(srcID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, srcID, RoleOwner, RoleEditor, RoleViewer)
+	if err != nil {
+		return err
+	}
+	req := new(CloneBoardReq)
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, req); err != nil {
+			return err
+		}
+	}
+	if req.Title == "" {
+		req.Title = src.Title + " (copy)"
+	}
+	if req.Description == "" {
+		req.Description = src.Description
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	dst, err := scanBoard(tx.QueryRow(ctx,
+		"INSERT INTO boards (title, description, settings) VALUES ($1, $2, $3) RETURNING "+boardColumns,
+		req.Title, req.Description, src.Settings))
+	if err != nil {
+		return err
+	}
+	lists, err := loadLists(tx, srcID)
+	if err != nil {
+		return err
+	}
+	if err := insertLists(tx, dst.ID, lists); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO board_members (board_id, member_id, role)
+		SELECT $1, member_id, role FROM board_members WHERE board_id=$2 AND member_id <> $3`,
+		dst.ID, srcID, caller); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3)", dst.ID, caller, RoleOwner); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO labels (board_id, name, color) SELECT $1, name, color FROM labels WHERE board_id=$2", dst.ID, srcID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO custom_fields (board_id, key, name, type, options, required, position, display)
+		SELECT $1, key, name, type, options, required, position, display FROM custom_fields WHERE board_id=$2`, dst.ID, srcID); err != nil {
+		return err
+	}
+	if req.IncludeTasks {
+		if _, err := copyBoardTasks(tx, srcID, dst.ID); err != nil {
+			return err
+		}
+	}
+	if req.IncludeDocs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO documents (board_id, title, content, embedding)
+			SELECT $1, title, content, embedding FROM documents WHERE board_id=$2 ORDER BY id`,
+			dst.ID, srcID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	emitEvent(dst.ID, EventBoardCreated, nil, caller, dst)
+	return c.JSON(dst)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hot board reads are cached in Redis per board and kind. Each (board, kind)
+// has a generation counter that is part of every entry's key; invalidating
+// bumps the counter, so stale entries are never read again and simply expire.
+const (
+	cacheSnapshot = "snapshot"
+	cacheTasks    = "tasks"
+	cacheMembers  = "members"
+
+	cacheTTL          = 5 * time.Minute
+	cachePrefix       = "moziboard:cache:"
+	cacheBypassHeader = "X-Cache-Bypass"
+)
+
+type cacheCounters struct {
+	Hits   atomic.Int64
+	Misses atomic.Int64
+	Errors atomic.Int64
+}
+
+var cacheStats = map[string]*cacheCounters{
+	cacheSnapshot: {},
+	cacheTasks:    {},
+	cacheMembers:  {},
+}
+
+// redisEnabled reports whether Redis is configured; without it caching and
+// rate limiting are off.
+func redisEnabled() bool {
+	return rdb != nil && os.Getenv("REDIS_ADDR") != ""
+}
+
+func cacheGenKey(boardID, kind string) string {
+	return cachePrefix + boardID + ":" + kind + ":gen"
+}
+
+// cacheKindsFor maps an event type to the cached kinds it makes stale.
+func cacheKindsFor(eventType string) []string {
+	switch {
+	case strings.HasPrefix(eventType, "task."), strings.HasPrefix(eventType, "comment."),
+		strings.HasPrefix(eventType, "label."), strings.HasPrefix(eventType, "field."):
+		return []string{cacheTasks, cacheSnapshot}
+	case strings.HasPrefix(eventType, "board.member_"):
+		return []string{cacheMembers, cacheSnapshot}
+	case strings.HasPrefix(eventType, "doc."):
+		return []string{cacheSnapshot}
+	case strings.HasPrefix(eventType, "board."):
+		// Spec applies and list changes can touch tasks too.
+		return []string{cacheTasks, cacheSnapshot}
+	}
+	return []string{cacheSnapshot, cacheTasks, cacheMembers}
+}
+
+// invalidateCache drops boardID's cached kinds; with no kinds, all of them.
+func invalidateCache(boardID string, kinds ...string) {
+	if !redisEnabled() || boardID == "" {
+		return
+	}
+	if len(kinds) == 0 {
+		kinds = []string{cacheSnapshot, cacheTasks, cacheMembers}
+	}
+	pipe := rdb.Pipeline()
+	for _, kind := range kinds {
+		pipe.Incr(context.Background(), cacheGenKey(boardID, kind))
+	}
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		log.Printf("Cache invalidate err: %v", err)
+	}
+}
+
+// invalidateMemberBoards drops the member lists of every board memberID is on.
+func invalidateMemberBoards(memberID string) {
+	if !redisEnabled() {
+		return
+	}
+	rows, err := db.Query(context.Background(), "SELECT board_id::text FROM board_members WHERE member_id=$1", memberID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var boardID string
+		if rows.Scan(&boardID) == nil {
+			invalidateCache(boardID, cacheMembers, cacheSnapshot)
+		}
+	}
+}
+
+// cached serves a GET handler for the board in :id from Redis. Entries are
+// keyed on the query string, plus the caller for task lists since queries
+// may say assignee:me. Only 200 responses are stored. Clients can skip the
+// cache with the X-Cache-Bypass header; X-Cache reports HIT, MISS or BYPASS.
+func cached(kind string, handler fiber.Handler) fiber.Handler {
+	stats := cacheStats[kind]
+	return func(c *fiber.Ctx) error {
+		if !redisEnabled() || c.Get(cacheBypassHeader) != "" {
+			c.Set("X-Cache", "BYPASS")
+			return handler(c)
+		}
+		ctx := context.Background()
+		boardID := c.Params("id")
+		gen, err := rdb.Get(ctx, cacheGenKey(boardID, kind)).Result()
+		if err == redis.Nil {
+			gen, err = "0", nil
+		}
+		if err != nil {
+			stats.Errors.Add(1)
+			return handler(c)
+		}
+		variant := string(c.Request().URI().QueryString())
+		if kind == cacheTasks {
+			variant += "\x00" + callerID(c)
+		}
+		sum := sha1.Sum([]byte(variant))
+		key := cachePrefix + boardID + ":" + kind + ":" + gen + ":" + hex.EncodeToString(sum[:])
+
+		if body, err := rdb.Get(ctx, key).Bytes(); err == nil {
+			stats.Hits.Add(1)
+			c.Set("X-Cache", "HIT")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Send(body)
+		} else if err != redis.Nil {
+			stats.Errors.Add(1)
+			return handler(c)
+		}
+
+		stats.Misses.Add(1)
+		c.Set("X-Cache", "MISS")
+		if err := handler(c); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() == fiber.StatusOK {
+			if err := rdb.Set(ctx, key, c.Response().Body(), cacheTTL).Err(); err != nil {
+				stats.Errors.Add(1)
+			}
+		}
+		return nil
+	}
+}
+
+// getCacheStats handles GET /api/cache/stats with hit and miss counts per
+// cached kind since the server started.
+func getCacheStats(c *fiber.Ctx) error {
+	out := fiber.Map{"enabled": redisEnabled()}
+	for kind, s := range cacheStats {
+		hits, misses := s.Hits.Load(), s.Misses.Load()
+		ratio := 0.0
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+		out[kind] = fiber.Map{"hits": hits, "misses": misses, "errors": s.Errors.Load(), "hit_ratio": ratio}
+	}
+	return c.JSON(out)
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const EventChecklistChanged = "task.checklist_changed"
+
+type ChecklistItem struct {
+	ID          int        `json:"id"`
+	TaskID      int        `json:"task_id"`
+	Position    int        `json:"position"`
+	Text        string     `json:"text"`
+	Done        bool       `json:"done"`
+	AssigneeID  *string    `json:"assignee_id"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// ChecklistItemReq creates or partially updates a checklist item. An empty
+// assignee_id clears the assignee.
+type ChecklistItemReq struct {
+	Text       *string `json:"text"`
+	Done       *bool   `json:"done"`
+	AssigneeID *string `json:"assignee_id"`
+	Position   *int    `json:"position"`
+	UpdatedBy  string  `json:"updated_by"`
+}
+
+// Progress rolls up a task's checklist items and subtasks.
+type Progress struct {
+	ChecklistDone  int `json:"checklist_done"`
+	ChecklistTotal int `json:"checklist_total"`
+	SubtasksDone   int `json:"subtasks_done"`
+	SubtasksTotal  int `json:"subtasks_total"`
+	Percent        int `json:"percent"`
+}
+
+// TaskDetail is a task with its checklist and direct subtasks.
+type TaskDetail struct {
+	Task
+	Checklist []ChecklistItem `json:"checklist"`
+	Subtasks  []Task          `json:"subtasks"`
+}
+
+const checklistColumns = "id, task_id, position, text, done, assignee_id, completed_at"
+
+func scanChecklistItem(row pgx.Row) (ChecklistItem, error) {
+	var i ChecklistItem
+	err := row.Scan(&i.ID, &i.TaskID, &i.Position, &i.Text, &i.Done, &i.AssigneeID, &i.CompletedAt)
+	return i, err
+}
+
+func loadChecklist(taskID int) ([]ChecklistItem, error) {
+	rows, err := db.Query(context.Background(),
+		"SELECT "+checklistColumns+" FROM checklist_items WHERE task_id=$1 ORDER BY position, id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ChecklistItem{}
+	for rows.Next() {
+		i, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// attachProgress fills in Progress for tasks that have checklist items or
+// subtasks.
+func attachProgress(q dbtx, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int, len(tasks))
+	index := make(map[int]int, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		index[tasks[i].ID] = i
+	}
+	progress := map[int]*Progress{}
+	get := func(id int) *Progress {
+		if progress[id] == nil {
+			progress[id] = &Progress{}
+		}
+		return progress[id]
+	}
+
+	rows, err := q.Query(context.Background(), `
+		SELECT task_id, COUNT(*) FILTER (WHERE done), COUNT(*)
+		FROM checklist_items WHERE task_id = ANY($1) GROUP BY task_id`, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, done, total int
+		if err := rows.Scan(&id, &done, &total); err != nil {
+			rows.Close()
+			return err
+		}
+		p := get(id)
+		p.ChecklistDone, p.ChecklistTotal = done, total
+	}
+	rows.Close()
+
+	rows, err = q.Query(context.Background(), `
+		SELECT parent_id, COUNT(*) FILTER (WHERE completed_at IS NOT NULL), COUNT(*)
+		FROM tasks WHERE parent_id = ANY($1) GROUP BY parent_id`, ids)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, done, total int
+		if err := rows.Scan(&id, &done, &total); err != nil {
+			rows.Close()
+			return err
+		}
+		p := get(id)
+		p.SubtasksDone, p.SubtasksTotal = done, total
+	}
+	rows.Close()
+
+	for id, p := range progress {
+		p.Percent = 100 * (p.ChecklistDone + p.SubtasksDone) / (p.ChecklistTotal + p.SubtasksTotal)
+		tasks[index[id]].Progress = p
+	}
+	return nil
+}
+
+// validateParent checks that parentID can become the parent of taskID (0 for
+// a new task) on boardID without creating a cycle.
+func validateParent(taskID, parentID int, boardID string) error {
+	if parentID == taskID {
+		return invalidField("parent_id", "cannot be the task itself")
+	}
+	var parentBoard string
+	err := db.QueryRow(context.Background(), "SELECT board_id::text FROM tasks WHERE id=$1", parentID).Scan(&parentBoard)
+	if err == pgx.ErrNoRows {
+		return invalidField("parent_id", "task %d not found", parentID)
+	}
+	if err != nil {
+		return err
+	}
+	if parentBoard != boardID {
+		return invalidField("parent_id", "must be a task on the same board")
+	}
+	if taskID == 0 {
+		return nil
+	}
+	var cycle bool
+	err = db.QueryRow(context.Background(), `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT parent_id FROM tasks WHERE id=$1
+			UNION
+			SELECT t.parent_id FROM tasks t JOIN ancestors a ON t.id = a.id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id=$2)`, parentID, taskID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return invalidField("parent_id", "would make task %d its own ancestor", taskID)
+	}
+	return nil
+}
+
+func openSubtasks(taskID int) int {
+	var n int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM tasks WHERE parent_id=$1 AND completed_at IS NULL", taskID).Scan(&n)
+	return n
+}
+
+// emitProgress publishes task.updated for a task whose roll-up changed.
+func emitProgress(taskID int, actor string) {
+	t, err := loadTask(taskID)
+	if err != nil {
+		return
+	}
+	tasks := []Task{t}
+	decorateTasks(db, tasks)
+	emitEvent(t.BoardID, EventTaskUpdated, &taskID, actor, tasks[0])
+}
+
+func getTask(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	t, err := loadTask(id)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Task not found")
+	}
+	if err != nil {
+		return err
+	}
+	tasks := []Task{t}
+	if err := decorateTasks(db, tasks); err != nil {
+		return err
+	}
+	detail := TaskDetail{Task: tasks[0]}
+	if detail.Checklist, err = loadChecklist(id); err != nil {
+		return err
+	}
+	if detail.Subtasks, err = loadSubtasks(id); err != nil {
+		return err
+	}
+	return c.JSON(detail)
+}
+
+func loadSubtasks(parentID int) ([]Task, error) {
+	rows, err := db.Query(context.Background(),
+		"SELECT "+taskColumns+" FROM tasks t WHERE t.parent_id=$1 ORDER BY t.position, t.id", parentID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, decorateTasks(db, tasks)
+}
+
+func getSubtasks(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	tasks, err := loadSubtasks(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(tasks)
+}
+
+func getChecklist(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	items, err := loadChecklist(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(items)
+}
+
+func createChecklistItem(c *fiber.Ctx) error {
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	t, err := loadTask(taskID)
+	if err != nil {
+		return fiber.NewError(404, "Task not found")
+	}
+	req := new(ChecklistItemReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	if req.Text == nil || strings.TrimSpace(*req.Text) == "" {
+		return invalidField("text", "is required")
+	}
+	userID, err := actorOr(c, req.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	if err := validateAssignee(req.AssigneeID); err != nil {
+		return err
+	}
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+	done := req.Done != nil && *req.Done
+	item, err := scanChecklistItem(db.QueryRow(context.Background(), `
+		INSERT INTO checklist_items (task_id, position, text, done, assignee_id, completed_at)
+		VALUES ($1, CASE WHEN $2 >= 0 THEN $2 ELSE (SELECT COALESCE(MAX(position) + 1, 0) FROM checklist_items WHERE task_id=$1) END,
+			$3, $4, NULLIF($5, ''), CASE WHEN $4 THEN CURRENT_TIMESTAMP END)
+		RETURNING `+checklistColumns,
+		taskID, position, strings.TrimSpace(*req.Text), done, stringValue(req.AssigneeID)))
+	if err != nil {
+		return err
+	}
+	go logActivity(taskID, userID, "checklist_added", fmt.Sprintf("Added checklist item %q", item.Text))
+	emitEvent(t.BoardID, EventChecklistChanged, &taskID, userID, fiber.Map{"action": "added", "item": item})
+	return c.Status(201).JSON(item)
+}
+
+func updateChecklistItem(c *fiber.Ctx) error {
+	req := new(ChecklistItemReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	return changeChecklistItem(c, req, false)
+}
+
+// toggleChecklistItem flips an item's done flag.
+func toggleChecklistItem(c *fiber.Ctx) error {
+	req := new(ChecklistItemReq)
+	c.BodyParser(req)
+	return changeChecklistItem(c, &ChecklistItemReq{UpdatedBy: req.UpdatedBy}, true)
+}
+
+// changeChecklistItem applies req to the item in :id, or flips its done flag
+// when toggle is set.
+func changeChecklistItem(c *fiber.Ctx, req *ChecklistItemReq, toggle bool) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	old, err := scanChecklistItem(db.QueryRow(context.Background(), "SELECT "+checklistColumns+" FROM checklist_items WHERE id=$1", id))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Checklist item not found")
+	}
+	if err != nil {
+		return err
+	}
+	item := old
+	if req.Text != nil {
+		if strings.TrimSpace(*req.Text) == "" {
+			return invalidField("text", "must not be empty")
+		}
+		item.Text = strings.TrimSpace(*req.Text)
+	}
+	if req.Position != nil {
+		item.Position = *req.Position
+	}
+	if req.AssigneeID != nil {
+		item.AssigneeID = req.AssigneeID
+		if *req.AssigneeID == "" {
+			item.AssigneeID = nil
+		}
+		if stringValue(item.AssigneeID) != stringValue(old.AssigneeID) {
+			if err := validateAssignee(item.AssigneeID); err != nil {
+				return err
+			}
+		}
+	}
+	if toggle {
+		item.Done = !old.Done
+	} else if req.Done != nil {
+		item.Done = *req.Done
+	}
+	if item.Done != old.Done {
+		item.CompletedAt = nil
+		if item.Done {
+			now := time.Now()
+			item.CompletedAt = &now
+		}
+	}
+
+	userID, err := actorOr(c, req.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(context.Background(),
+		"UPDATE checklist_items SET text=$1, position=$2, assignee_id=$3, done=$4, completed_at=$5 WHERE id=$6",
+		item.Text, item.Position, item.AssigneeID, item.Done, item.CompletedAt, id)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case item.Done && !old.Done:
+		go logActivity(item.TaskID, userID, "checklist_checked", fmt.Sprintf("Checked %q", item.Text))
+	case !item.Done && old.Done:
+		go logActivity(item.TaskID, userID, "checklist_unchecked", fmt.Sprintf("Unchecked %q", item.Text))
+	}
+	if item.Text != old.Text || stringValue(item.AssigneeID) != stringValue(old.AssigneeID) || item.Position != old.Position {
+		go logActivity(item.TaskID, userID, "checklist_updated", fmt.Sprintf("Updated checklist item %q", item.Text))
+	}
+	if boardID, err := taskBoardID(item.TaskID); err == nil {
+		taskID := item.TaskID
+		emitEvent(boardID, EventChecklistChanged, &taskID, userID, fiber.Map{"action": "updated", "item": item})
+	}
+	return c.JSON(item)
+}
+
+func deleteChecklistItem(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	userID, err := actorOr(c, c.Query("updated_by"))
+	if err != nil {
+		return err
+	}
+	item, err := scanChecklistItem(db.QueryRow(context.Background(), "DELETE FROM checklist_items WHERE id=$1 RETURNING "+checklistColumns, id))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Checklist item not found")
+	}
+	if err != nil {
+		return err
+	}
+	go logActivity(item.TaskID, userID, "checklist_removed", fmt.Sprintf("Removed checklist item %q", item.Text))
+	if boardID, err := taskBoardID(item.TaskID); err == nil {
+		taskID := item.TaskID
+		emitEvent(boardID, EventChecklistChanged, &taskID, userID, fiber.Map{"action": "removed", "item": item})
+	}
+	return c.SendStatus(200)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// actorOr returns the member to attribute an edit to. With an API token that
+// is always the caller, and naming anyone else is refused; requests without
+// one fall back to userID, which is empty for anonymous edits.
+func actorOr(c *fiber.Ctx, userID string) (string, error) {
+	caller := callerID(c)
+	if caller == "" {
+		return userID, nil
+	}
+	if userID != "" && userID != caller {
+		return "", fiber.NewError(403, "Cannot act on behalf of another member")
+	}
+	return caller, nil
+}
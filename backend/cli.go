@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const cliUsage = `usage: moziboard [command]
+
+With no command, or with serve, runs the server.
+
+Commands:
+  board list|show|export|apply   boards and board-as-code specs
+  task list|show|create|move|comment
+  doc list|pull|push             knowledge base documents as Markdown files
+  search [-docs] <query>         semantic search
+  import trello|jira <file>      create a board from a Trello JSON or Jira CSV/XML export
+  admin migrate|reindex|token
+  admin export|import            portable board archives (JSON or zip)
+  openapi [-check]               print or verify the OpenAPI document
+
+HTTP commands read MOZIBOARD_URL, MOZIBOARD_TOKEN, MOZIBOARD_MEMBER and
+MOZIBOARD_OUTPUT, or the same keys (url, token, member, output) from
+~/.config/moziboard/config.yaml. Admin commands and board export use the
+server's DB_* environment, and admin import its REDIS_* one as well.
+`
+
+// runCLI handles command-line subcommands. It reports false for no command
+// or "serve", in which case the caller starts the server; anything else it
+// does not know prints the usage and exits 2.
+func runCLI(args []string) bool {
+	if len(args) == 0 || args[0] == "serve" {
+		return false
+	}
+	switch args[0] {
+	case "board":
+		os.Exit(runBoardCmd(args[1:]))
+	case "task":
+		os.Exit(runTaskCmd(args[1:]))
+	case "doc":
+		os.Exit(runDocCmd(args[1:]))
+	case "search":
+		os.Exit(runSearchCmd(args[1:]))
+	case "import":
+		os.Exit(runImportCmd(args[1:]))
+	case "admin":
+		os.Exit(runAdminCmd(args[1:]))
+	case "openapi":
+		os.Exit(runOpenAPICmd(args[1:]))
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+	os.Exit(2)
+	return false
+}
+
+// runOpenAPICmd prints the OpenAPI document, or with -check reports routes
+// and spec operations that disagree and exits 1 if there are any. It needs
+// no database, so CI can run it.
+func runOpenAPICmd(args []string) int {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	check := fs.Bool("check", false, "verify that the router and the spec agree")
+	fs.Parse(args)
+	if *check {
+		problems := checkOpenAPI(newApp())
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) > 0 {
+			return 1
+		}
+		fmt.Println("openapi: routes and spec agree")
+		return 0
+	}
+	return writeOutput(buildOpenAPI(), "json")
+}
+
+func runBoardCmd(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: board <list|show|export|apply> [flags]")
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("board list", flag.ExitOnError)
+		output := outputFlag(fs)
+		archived := fs.Bool("archived", false, "include archived boards")
+		fs.Parse(args[1:])
+		client, err := newAPIClient()
+		if err != nil {
+			return fail("board list", err)
+		}
+		query := url.Values{}
+		if *archived {
+			query.Set("include_archived", "true")
+		}
+		var boards []Board
+		if err := client.do("GET", "/boards", query, nil, &boards); err != nil {
+			return fail("board list", err)
+		}
+		rows := make([][]string, len(boards))
+		for i, b := range boards {
+			rows[i] = []string{b.ID, strconv.FormatBool(b.Archived), b.CreatedAt.Format("2006-01-02"), b.Title}
+		}
+		return render(*output, boards, []string{"ID", "ARCHIVED", "CREATED", "TITLE"}, rows)
+
+	case "show":
+		fs := flag.NewFlagSet("board show", flag.ExitOnError)
+		output := outputFlag(fs)
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: board show [-o format] <board-id>")
+			return 2
+		}
+		client, err := newAPIClient()
+		if err != nil {
+			return fail("board show", err)
+		}
+		var s BoardSnapshot
+		if err := client.do("GET", "/boards/"+url.PathEscape(fs.Arg(0))+"/snapshot", nil, nil, &s); err != nil {
+			return fail("board show", err)
+		}
+		var rows [][]string
+		for _, l := range s.Lists {
+			for _, t := range s.Tasks {
+				if t.ListID == l.ID {
+					rows = append(rows, []string{l.Title, strconv.Itoa(t.ID), string(t.Priority), deref(t.AssigneeID), shortDate(t.DueDate), t.Title})
+				}
+			}
+		}
+		if *output != "json" && *output != "yaml" {
+			fmt.Printf("%s (%s)\n\n", s.Board.Title, s.Board.ID)
+		}
+		return render(*output, s, []string{"LIST", "ID", "PRIORITY", "ASSIGNEE", "DUE", "TITLE"}, rows)
+
+	case "export":
+		fs := flag.NewFlagSet("board export", flag.ExitOnError)
+		format := fs.String("format", "yaml", "output format: yaml or json")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: board export [-format yaml|json] <board-id>")
+			return 2
+		}
+		initDB()
+		spec, err := exportBoardSpec(db, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "export:", err)
+			return 1
+		}
+		return writeOutput(spec, *format)
+
+	case "apply":
+		fs := flag.NewFlagSet("board apply", flag.ExitOnError)
+		file := fs.String("f", "", "spec file (YAML or JSON)")
+		dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+		fs.Parse(args[1:])
+		if *file == "" {
+			fmt.Fprintln(os.Stderr, "usage: board apply -f board.yaml [-dry-run]")
+			return 2
+		}
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "apply:", err)
+			return 1
+		}
+		spec := new(BoardSpec)
+		if err := yaml.Unmarshal(data, spec); err != nil {
+			fmt.Fprintln(os.Stderr, "apply: invalid spec:", err)
+			return 1
+		}
+		if err := validateBoardSpec(spec); err != nil {
+			fmt.Fprintln(os.Stderr, "apply:", err)
+			return 1
+		}
+		// Apply through the server, so its caches and live streams see the
+		// change and only board owners can apply.
+		client, err := newAPIClient()
+		if err != nil {
+			return fail("apply", err)
+		}
+		path := "/boards/apply"
+		if spec.ID != "" {
+			path = "/boards/" + url.PathEscape(spec.ID) + "/apply"
+		}
+		query := url.Values{}
+		if *dryRun {
+			query.Set("dry_run", "true")
+		}
+		var plan ApplyPlan
+		if err := client.do("POST", path, query, spec, &plan); err != nil {
+			return fail("apply", err)
+		}
+		fmt.Print(plan.String())
+		if *dryRun {
+			fmt.Println("Dry run: nothing was applied.")
+		} else if spec.ID == "" {
+			fmt.Println("Created board", plan.BoardID)
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown board command %q\n", args[0])
+	return 2
+}
+
+func writeOutput(v interface{}, format string) int {
+	var out []byte
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		out, err = json.MarshalIndent(v, "", "  ")
+		out = append(out, '\n')
+	default:
+		out, err = yaml.Marshal(v)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
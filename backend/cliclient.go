@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cliConfig configures the HTTP commands of the CLI. Values come from the
+// config file ($MOZIBOARD_CONFIG, default ~/.config/moziboard/config.yaml)
+// and are overridden by MOZIBOARD_URL, MOZIBOARD_TOKEN, MOZIBOARD_MEMBER and
+// MOZIBOARD_OUTPUT.
+type cliConfig struct {
+	URL    string `yaml:"url"`
+	Token  string `yaml:"token"`
+	Member string `yaml:"member"`
+	Output string `yaml:"output"`
+}
+
+func cliConfigPath() string {
+	if p := os.Getenv("MOZIBOARD_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "moziboard", "config.yaml")
+}
+
+func loadCLIConfig() (cliConfig, error) {
+	cfg := cliConfig{URL: "http://localhost:8080/api/" + apiVersion, Output: "table"}
+	if path := cliConfigPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return cfg, err
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for env, dst := range map[string]*string{
+		"MOZIBOARD_URL": &cfg.URL, "MOZIBOARD_TOKEN": &cfg.Token,
+		"MOZIBOARD_MEMBER": &cfg.Member, "MOZIBOARD_OUTPUT": &cfg.Output,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*dst = v
+		}
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return cfg, nil
+}
+
+// apiClient calls the REST API as the configured member.
+type apiClient struct {
+	cfg  cliConfig
+	http *http.Client
+}
+
+func newAPIClient() (*apiClient, error) {
+	cfg, err := loadCLIConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &apiClient{cfg: cfg, http: &http.Client{Timeout: 60 * time.Second}}, nil
+}
+
+// apiStatusError is an error response from the API.
+type apiStatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiStatusError) Error() string { return e.Message }
+
+// httpStatus returns the HTTP status of an API error, or 0.
+func httpStatus(err error) int {
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	return 0
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out. Error responses come back as their APIError message.
+// Rate-limited requests are retried after the server's Retry-After.
+func (a *apiClient) do(method, path string, query url.Values, body, out interface{}) error {
+	u := a.cfg.URL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, u, reader)
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if a.cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+		}
+		resp, err := a.http.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == 429 && attempt < 5 {
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			time.Sleep(time.Duration(max(wait, 1)) * time.Second)
+			continue
+		}
+		if resp.StatusCode >= 400 {
+			var apiErr APIError
+			if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+				return &apiStatusError{resp.StatusCode, fmt.Sprintf("%s (%s, HTTP %d)", apiErr.Message, apiErr.Code, resp.StatusCode)}
+			}
+			return &apiStatusError{resp.StatusCode, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))}
+		}
+		if out == nil || len(data) == 0 {
+			return nil
+		}
+		if raw, ok := out.(*[]byte); ok {
+			*raw = data
+			return nil
+		}
+		return json.Unmarshal(data, out)
+	}
+}
+
+// outputFlag registers -o on fs, defaulting to the configured format.
+func outputFlag(fs *flag.FlagSet) *string {
+	def := os.Getenv("MOZIBOARD_OUTPUT")
+	if def == "" {
+		if cfg, err := loadCLIConfig(); err == nil {
+			def = cfg.Output
+		}
+	}
+	return fs.String("o", def, "output format: table, json or yaml")
+}
+
+// render prints v as JSON or YAML, or as a table with the given header and
+// one row per item.
+func render(format string, v interface{}, header []string, rows [][]string) int {
+	if format == "json" || format == "yaml" {
+		return writeOutput(v, format)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return 0
+}
+
+// fail prints err for the named command and returns the exit status.
+func fail(cmd string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+	return 1
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func shortDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
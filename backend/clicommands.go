@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP subcommands of the CLI. They go through the REST API, so they work
+// against any deployment and are subject to its permissions.
+
+func runTaskCmd(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: task <list|show|create|move|comment> [flags]")
+		return 2
+	}
+	client, err := newAPIClient()
+	if err != nil {
+		return fail("task", err)
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("task list", flag.ExitOnError)
+		output := outputFlag(fs)
+		q := fs.String("q", "", `task query, e.g. 'assignee:me list:doing "free text"'`)
+		sortBy := fs.String("sort", "", "sort key, e.g. priority or -due_date")
+		list := fs.String("list", "", "comma-separated list ids")
+		assignee := fs.String("assignee", "", "comma-separated member ids")
+		label := fs.String("label", "", "comma-separated label names")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: task list [-q query] [-sort key] [-list ids] [-assignee ids] [-label names] [-o format] <board-id>")
+			return 2
+		}
+		query := url.Values{}
+		for k, v := range map[string]string{"q": *q, "sort": *sortBy, "list_id": *list, "assignee": *assignee, "label": *label} {
+			if v != "" {
+				query.Set(k, v)
+			}
+		}
+		var tasks []Task
+		if err := client.do("GET", "/boards/"+url.PathEscape(fs.Arg(0))+"/tasks", query, nil, &tasks); err != nil {
+			return fail("task list", err)
+		}
+		return render(*output, tasks, taskHeader, taskRows(tasks))
+
+	case "show":
+		fs := flag.NewFlagSet("task show", flag.ExitOnError)
+		output := outputFlag(fs)
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: task show [-o format] <task-id>")
+			return 2
+		}
+		var t TaskDetail
+		if err := client.do("GET", "/tasks/"+url.PathEscape(fs.Arg(0)), nil, nil, &t); err != nil {
+			return fail("task show", err)
+		}
+		rows := [][]string{
+			{"id", strconv.Itoa(t.ID)}, {"title", t.Title}, {"board", t.BoardID}, {"list", t.ListID},
+			{"priority", string(t.Priority)}, {"assignee", deref(t.AssigneeID)}, {"due", shortDate(t.DueDate)},
+			{"description", t.Description},
+		}
+		for _, item := range t.Checklist {
+			mark := "[ ]"
+			if item.Done {
+				mark = "[x]"
+			}
+			rows = append(rows, []string{"checklist", mark + " " + item.Text})
+		}
+		for _, sub := range t.Subtasks {
+			rows = append(rows, []string{"subtask", fmt.Sprintf("#%d %s (%s)", sub.ID, sub.Title, sub.ListID)})
+		}
+		return render(*output, t, []string{"FIELD", "VALUE"}, rows)
+
+	case "create":
+		fs := flag.NewFlagSet("task create", flag.ExitOnError)
+		output := outputFlag(fs)
+		board := fs.String("board", "", "board id (default: the parent's board or the first board)")
+		title := fs.String("title", "", "task title (required)")
+		description := fs.String("description", "", "task description")
+		list := fs.String("list", "", "list id (default: the board's default list)")
+		priority := fs.String("priority", "", "low, medium, high or urgent")
+		assignee := fs.String("assignee", "", "assignee member id")
+		due := fs.String("due", "", "due date (YYYY-MM-DD or RFC 3339)")
+		parent := fs.Int("parent", 0, "parent task id, to create a subtask")
+		fs.Parse(args[1:])
+		if *title == "" || fs.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "usage: task create -title text [-board id] [-list id] [-priority p] [-assignee id] [-due date] [-parent id] [-description text]")
+			return 2
+		}
+		body := map[string]interface{}{"title": *title}
+		for k, v := range map[string]string{"board_id": *board, "description": *description, "list_id": *list, "priority": *priority, "updated_by": client.cfg.Member} {
+			if v != "" {
+				body[k] = v
+			}
+		}
+		if *assignee != "" {
+			body["assignee_id"] = *assignee
+		}
+		if *due != "" {
+			ts, err := parseCLIDate(*due)
+			if err != nil {
+				return fail("task create", err)
+			}
+			body["due_date"] = ts
+		}
+		if *parent != 0 {
+			body["parent_id"] = *parent
+		}
+		var t Task
+		if err := client.do("POST", "/tasks", nil, body, &t); err != nil {
+			return fail("task create", err)
+		}
+		return render(*output, t, taskHeader, taskRows([]Task{t}))
+
+	case "move":
+		fs := flag.NewFlagSet("task move", flag.ExitOnError)
+		position := fs.Int("position", 0, "position in the target list")
+		fs.Parse(args[1:])
+		if fs.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "usage: task move [-position n] <task-id> <list-id>")
+			return 2
+		}
+		body := map[string]interface{}{"list_id": fs.Arg(1), "position": *position}
+		if client.cfg.Member != "" {
+			body["updated_by"] = client.cfg.Member
+		}
+		var t Task
+		if err := client.do("PUT", "/tasks/"+url.PathEscape(fs.Arg(0)), nil, body, &t); err != nil {
+			return fail("task move", err)
+		}
+		fmt.Printf("Moved #%d to %s\n", t.ID, t.ListID)
+		return 0
+
+	case "comment":
+		fs := flag.NewFlagSet("task comment", flag.ExitOnError)
+		fs.Parse(args[1:])
+		if fs.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "usage: task comment <task-id> <text...>")
+			return 2
+		}
+		body := map[string]interface{}{"content": strings.Join(fs.Args()[1:], " ")}
+		if client.cfg.Member != "" {
+			body["user_id"] = client.cfg.Member
+		}
+		var cm Comment
+		if err := client.do("POST", "/tasks/"+url.PathEscape(fs.Arg(0))+"/comments", nil, body, &cm); err != nil {
+			return fail("task comment", err)
+		}
+		fmt.Printf("Commented on #%d as %s\n", cm.TaskID, cm.UserID)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown task command %q\n", args[0])
+	return 2
+}
+
+var taskHeader = []string{"ID", "LIST", "PRIORITY", "ASSIGNEE", "DUE", "TITLE"}
+
+func taskRows(tasks []Task) [][]string {
+	rows := make([][]string, len(tasks))
+	for i, t := range tasks {
+		rows[i] = []string{strconv.Itoa(t.ID), t.ListID, string(t.Priority), deref(t.AssigneeID), shortDate(t.DueDate), t.Title}
+	}
+	return rows
+}
+
+func parseCLIDate(s string) (time.Time, error) {
+	if ts, err := time.Parse("2006-01-02", s); err == nil {
+		return ts, nil
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return ts, fmt.Errorf("date must be YYYY-MM-DD or RFC 3339: %q", s)
+	}
+	return ts, nil
+}
+
+func runDocCmd(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: doc <list|pull|push> [flags]")
+		return 2
+	}
+	client, err := newAPIClient()
+	if err != nil {
+		return fail("doc", err)
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("doc list", flag.ExitOnError)
+		output := outputFlag(fs)
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: doc list [-o format] <board-id>")
+			return 2
+		}
+		var docs []Document
+		if err := client.do("GET", "/boards/"+url.PathEscape(fs.Arg(0))+"/docs", nil, nil, &docs); err != nil {
+			return fail("doc list", err)
+		}
+		rows := make([][]string, len(docs))
+		for i, d := range docs {
+			rows[i] = []string{strconv.Itoa(d.ID), d.UpdatedAt.Format("2006-01-02 15:04"), d.Title}
+		}
+		return render(*output, docs, []string{"ID", "UPDATED", "TITLE"}, rows)
+
+	case "pull":
+		fs := flag.NewFlagSet("doc pull", flag.ExitOnError)
+		file := fs.String("f", "", "file to write (default: stdout)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: doc pull [-f file.md] <doc-id>")
+			return 2
+		}
+		var d Document
+		if err := client.do("GET", "/docs/"+url.PathEscape(fs.Arg(0)), nil, nil, &d); err != nil {
+			return fail("doc pull", err)
+		}
+		markdown := "# " + d.Title + "\n\n" + d.Content
+		if !strings.HasSuffix(markdown, "\n") {
+			markdown += "\n"
+		}
+		if *file == "" {
+			fmt.Print(markdown)
+			return 0
+		}
+		if err := os.WriteFile(*file, []byte(markdown), 0o644); err != nil {
+			return fail("doc pull", err)
+		}
+		return 0
+
+	case "push":
+		fs := flag.NewFlagSet("doc push", flag.ExitOnError)
+		board := fs.String("board", "", "board to create the document on")
+		id := fs.Int("id", 0, "document to update instead of creating one")
+		title := fs.String("title", "", "title (default: the file's first '# ' heading, else its name)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 || (*board == "") == (*id == 0) {
+			fmt.Fprintln(os.Stderr, "usage: doc push (-board id | -id doc-id) [-title text] <file.md>")
+			return 2
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return fail("doc push", err)
+		}
+		docTitle, content := splitMarkdownTitle(string(data))
+		if *title != "" {
+			docTitle = *title
+		}
+		if docTitle == "" {
+			docTitle = strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+		}
+		body := map[string]interface{}{"title": docTitle, "content": content}
+		var d Document
+		if *id != 0 {
+			err = client.do("PUT", "/docs/"+strconv.Itoa(*id), nil, body, &d)
+		} else {
+			err = client.do("POST", "/boards/"+url.PathEscape(*board)+"/docs", nil, body, &d)
+		}
+		if err != nil {
+			return fail("doc push", err)
+		}
+		fmt.Printf("Pushed %s as document %d\n", fs.Arg(0), d.ID)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown doc command %q\n", args[0])
+	return 2
+}
+
+// splitMarkdownTitle takes a leading "# Title" line off a Markdown file.
+func splitMarkdownTitle(markdown string) (string, string) {
+	first, rest, _ := strings.Cut(markdown, "\n")
+	if !strings.HasPrefix(first, "# ") {
+		return "", markdown
+	}
+	return strings.TrimSpace(first[2:]), strings.TrimLeft(rest, "\n")
+}
+
+func runSearchCmd(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	output := outputFlag(fs)
+	docs := fs.Bool("docs", false, "search documents instead of tasks")
+	board := fs.String("board", "", "restrict a document search to one board")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: search [-docs [-board id]] [-o format] <query...>")
+		return 2
+	}
+	client, err := newAPIClient()
+	if err != nil {
+		return fail("search", err)
+	}
+	query := url.Values{"q": {strings.Join(fs.Args(), " ")}}
+	if *docs {
+		if *board != "" {
+			query.Set("board_id", *board)
+		}
+		var results []Document
+		if err := client.do("GET", "/docs/search", query, nil, &results); err != nil {
+			return fail("search", err)
+		}
+		rows := make([][]string, len(results))
+		for i, d := range results {
+			rows[i] = []string{strconv.Itoa(d.ID), d.BoardID, d.Title}
+		}
+		return render(*output, results, []string{"ID", "BOARD", "TITLE"}, rows)
+	}
+	var tasks []Task
+	if err := client.do("GET", "/search", query, nil, &tasks); err != nil {
+		return fail("search", err)
+	}
+	return render(*output, tasks, taskHeader, taskRows(tasks))
+}
+
+// runImportCmd imports a Trello or Jira export through the API.
+func runImportCmd(args []string) int {
+	parsers := map[string]func([]byte) (*importPlan, error){"trello": parseTrello, "jira": parseJira}
+	if len(args) == 0 || parsers[args[0]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: import <trello|jira> [flags] <export-file>")
+		return 2
+	}
+	fs := flag.NewFlagSet("import "+args[0], flag.ExitOnError)
+	output := outputFlag(fs)
+	members := fs.String("members", "", "member mapping file (YAML: members: {source-user: member-id}, create_missing: bool)")
+	title := fs.String("title", "", "board title (default: the source board or project name)")
+	owner := fs.String("owner", "", "member who owns the new board (default: MOZIBOARD_MEMBER)")
+	dryRun := fs.Bool("dry-run", false, "report what would be created without creating anything")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: import %s [-members map.yaml] [-title text] [-owner id] [-dry-run] [-o format] <export-file>\n", args[0])
+		return 2
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fail("import", err)
+	}
+	plan, err := parsers[args[0]](data)
+	if err != nil {
+		return fail("import", err)
+	}
+	if *title != "" {
+		plan.Title = *title
+	}
+	if plan.Title == "" {
+		plan.Title = strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+	}
+	if len(plan.Lists) == 0 {
+		return fail("import", fmt.Errorf("%s has no lists or issues to import", fs.Arg(0)))
+	}
+	mapping, err := loadMemberMapping(*members)
+	if err != nil {
+		return fail("import", err)
+	}
+	client, err := newAPIClient()
+	if err != nil {
+		return fail("import", err)
+	}
+	if *owner == "" {
+		*owner = client.cfg.Member
+	}
+	if *owner == "" {
+		return fail("import", fmt.Errorf("set -owner or MOZIBOARD_MEMBER to the member who will own the board"))
+	}
+	resolved, err := resolveMembers(client, plan, mapping)
+	if err != nil {
+		return fail("import", err)
+	}
+	report := runImport(client, plan, resolved, *owner, *dryRun)
+	if *output == "json" || *output == "yaml" {
+		writeOutput(report, *output)
+	} else {
+		fmt.Print(report.String())
+	}
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Admin subcommands work on the database directly with the server's own
+// code, using the same DB_* and AI environment as the server.
+func runAdminCmd(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: admin <migrate|reindex|export|import|token> [flags]")
+		return 2
+	}
+	switch args[0] {
+	case "migrate":
+		initDB()
+		fmt.Println("Database schema is up to date.")
+		return 0
+
+	case "reindex":
+		fs := flag.NewFlagSet("admin reindex", flag.ExitOnError)
+		board := fs.String("board", "", "only this board")
+		missing := fs.Bool("missing", false, "only rows without an embedding")
+		tasksOnly := fs.Bool("tasks", false, "only tasks")
+		docsOnly := fs.Bool("docs", false, "only documents")
+		fs.Parse(args[1:])
+		initDB()
+		initAI()
+		failed := 0
+		if !*docsOnly {
+			n, errs := reindexTasks(*board, *missing)
+			fmt.Printf("Reindexed %d task(s)\n", n)
+			failed += errs
+		}
+		if !*tasksOnly {
+			n, errs := reindexDocs(*board, *missing)
+			fmt.Printf("Reindexed %d document(s)\n", n)
+			failed += errs
+		}
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "reindex: %d embedding(s) failed\n", failed)
+			return 1
+		}
+		return 0
+
+	case "export":
+		fs := flag.NewFlagSet("admin export", flag.ExitOnError)
+		file := fs.String("f", "", "file to write; a .zip name writes a zip with Markdown documents (default: JSON on stdout)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: admin export [-f board.json|board.zip] <board-id>")
+			return 2
+		}
+		initDB()
+		a, err := exportBoardArchive(db, fs.Arg(0))
+		if err != nil {
+			return fail("admin export", err)
+		}
+		var buf bytes.Buffer
+		if strings.HasSuffix(*file, ".zip") {
+			err = writeArchiveZip(&buf, a)
+		} else {
+			enc := json.NewEncoder(&buf)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(a)
+		}
+		if err == nil && *file != "" {
+			err = os.WriteFile(*file, buf.Bytes(), 0o644)
+		} else if err == nil {
+			_, err = os.Stdout.Write(buf.Bytes())
+		}
+		if err != nil {
+			return fail("admin export", err)
+		}
+		return 0
+
+	case "import":
+		fs := flag.NewFlagSet("admin import", flag.ExitOnError)
+		title := fs.String("title", "", "title of the new board (default: the archived title)")
+		members := fs.String("members", memberConflictKeep, "existing members whose profile differs: keep, update or fail")
+		owner := fs.String("owner", "", "member to make owner of the new board")
+		reembed := fs.Bool("reembed", false, "generate embeddings for the imported tasks and documents")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 || !contains([]string{memberConflictKeep, memberConflictUpdate, memberConflictFail}, *members) {
+			fmt.Fprintln(os.Stderr, "usage: admin import [-title text] [-members keep|update|fail] [-owner id] [-reembed] <board.json|board.zip>")
+			return 2
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return fail("admin import", err)
+		}
+		a, err := parseArchive(data)
+		if err != nil {
+			return fail("admin import", err)
+		}
+		initDB()
+		initRedis()
+		res, err := importBoardArchive(&a, ArchiveImportOptions{Title: *title, Members: *members, Owner: *owner})
+		if err != nil {
+			return fail("admin import", toAPIError(err))
+		}
+		// Updated profiles show up in other boards' cached members.
+		for id, action := range res.Members {
+			if action == "updated" {
+				invalidateMemberBoards(id)
+			}
+		}
+		if b, err := loadBoard(res.BoardID); err == nil {
+			emitEvent(res.BoardID, EventBoardCreated, nil, *owner, b)
+		}
+		for _, w := range res.Warnings {
+			fmt.Fprintln(os.Stderr, "warning:", w)
+		}
+		fmt.Printf("Imported board %s: %d task(s), %d comment(s), %d document(s)\n",
+			res.BoardID, res.Created["tasks"], res.Created["comments"], res.Created["docs"])
+		if *reembed {
+			initAI()
+			if failed := reembedArchive(&a, res); failed > 0 {
+				fmt.Fprintf(os.Stderr, "admin import: %d embedding(s) failed\n", failed)
+				return 1
+			}
+		}
+		return 0
+
+	case "token":
+		fs := flag.NewFlagSet("admin token", flag.ExitOnError)
+		name := fs.String("name", "cli", "token name")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: admin token [-name text] <member-id>")
+			return 2
+		}
+		initDB()
+		t, err := issueMemberToken(fs.Arg(0), *name)
+		if err != nil {
+			return fail("admin token", toAPIError(err))
+		}
+		fmt.Println(t.Token)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown admin command %q\n", args[0])
+	return 2
+}
+
+func reindexTasks(boardID string, missingOnly bool) (done, failed int) {
+	query := "SELECT " + taskColumns + " FROM tasks t WHERE ($1 = '' OR t.board_id::text = $1)"
+	if missingOnly {
+		query += " AND t.embedding IS NULL"
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY t.id", boardID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reindex:", err)
+		return 0, 1
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reindex:", err)
+		return 0, 1
+	}
+	for i := range tasks {
+		if err := storeEmbedding("tasks", tasks[i].ID, taskEmbeddingText(&tasks[i])); err != nil {
+			fmt.Fprintf(os.Stderr, "reindex: task %d: %v\n", tasks[i].ID, err)
+			failed++
+			continue
+		}
+		done++
+	}
+	return done, failed
+}
+
+func reindexDocs(boardID string, missingOnly bool) (done, failed int) {
+	query := "SELECT id, title, content FROM documents WHERE ($1 = '' OR board_id::text = $1)"
+	if missingOnly {
+		query += " AND embedding IS NULL"
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY id", boardID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "reindex:", err)
+		return 0, 1
+	}
+	var docs []Document
+	for rows.Next() {
+		var d Document
+		if err := rows.Scan(&d.ID, &d.Title, &d.Content); err != nil {
+			rows.Close()
+			fmt.Fprintln(os.Stderr, "reindex:", err)
+			return 0, 1
+		}
+		docs = append(docs, d)
+	}
+	rows.Close()
+	for _, d := range docs {
+		if err := storeEmbedding("documents", d.ID, d.Title+" "+d.Content); err != nil {
+			fmt.Fprintf(os.Stderr, "reindex: document %d: %v\n", d.ID, err)
+			failed++
+			continue
+		}
+		done++
+	}
+	return done, failed
+}
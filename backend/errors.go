@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// APIError is the JSON body of every error response:
+//
+//	{"code": "invalid_reference", "message": "assignee_id does not exist",
+//	 "fields": [{"field": "assignee_id", "message": "does not exist"}],
+//	 "request_id": "..."}
+//
+// Code is stable and meant for programs; Message is for people.
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+var errorCodes = map[int]string{
+	400: "bad_request",
+	401: "unauthorized",
+	403: "forbidden",
+	404: "not_found",
+	405: "method_not_allowed",
+	409: "conflict",
+	410: "gone",
+	413: "payload_too_large",
+	415: "unsupported_media_type",
+	422: "unprocessable",
+	426: "upgrade_required",
+	429: "rate_limited",
+	500: "internal",
+	503: "unavailable",
+}
+
+// invalidField reports a request field that failed validation.
+func invalidField(field, format string, args ...interface{}) *APIError {
+	msg := fmt.Sprintf(format, args...)
+	return &APIError{Status: 400, Code: "validation_failed", Message: field + " " + msg,
+		Fields: []FieldError{{Field: field, Message: msg}}}
+}
+
+// badRequest reports err as a 400, keeping the field details of an APIError.
+func badRequest(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return fiber.NewError(400, err.Error())
+}
+
+// validator is implemented by request bodies that can check themselves.
+type validator interface {
+	Validate() error
+}
+
+// parseBody decodes the request body into dst and runs its Validate method
+// if it has one.
+func parseBody(c *fiber.Ctx, dst interface{}) error {
+	if err := c.BodyParser(dst); err != nil {
+		return &APIError{Status: 400, Code: "invalid_body", Message: "Request body is invalid: " + err.Error()}
+	}
+	if v, ok := dst.(validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// paramInt reads a positive integer path parameter such as a task id.
+func paramInt(c *fiber.Ctx, name string) (int, error) {
+	n, err := strconv.Atoi(c.Params(name))
+	if err != nil || n <= 0 {
+		return 0, &APIError{Status: 400, Code: "invalid_param", Message: name + " must be a positive integer",
+			Fields: []FieldError{{Field: name, Message: "must be a positive integer"}}}
+	}
+	return n, nil
+}
+
+// keyColumnPattern finds the column in a constraint violation's detail,
+// e.g. `Key (assignee_id)=(nobody) is not present in table "members".`
+var keyColumnPattern = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// toAPIError classifies err. Known Postgres errors become 4xx responses
+// naming the offending field; anything unrecognised is a 500.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		code, ok := errorCodes[fe.Code]
+		if !ok {
+			code = "error"
+		}
+		return &APIError{Status: fe.Code, Code: code, Message: fe.Message}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &APIError{Status: 404, Code: "not_found", Message: "Not found"}
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		field := pgErr.ColumnName
+		if m := keyColumnPattern.FindStringSubmatch(pgErr.Detail); m != nil {
+			field = m[1]
+		}
+		withField := func(e *APIError, msg string) *APIError {
+			if field != "" {
+				e.Message = field + " " + msg
+				e.Fields = []FieldError{{Field: field, Message: msg}}
+			}
+			return e
+		}
+		switch pgErr.Code {
+		case "23503": // foreign_key_violation
+			if strings.Contains(pgErr.Detail, "is still referenced") {
+				return &APIError{Status: 409, Code: "in_use", Message: "The record is still referenced from " + pgErr.TableName}
+			}
+			return withField(&APIError{Status: 422, Code: "invalid_reference", Message: "A referenced record does not exist"}, "does not exist")
+		case "23505": // unique_violation
+			return withField(&APIError{Status: 409, Code: "already_exists", Message: "The record already exists"}, "already exists")
+		case "23502": // not_null_violation
+			return withField(&APIError{Status: 400, Code: "validation_failed", Message: "A required field is missing"}, "is required")
+		case "23514": // check_violation
+			return &APIError{Status: 400, Code: "validation_failed", Message: "Value violates " + pgErr.ConstraintName}
+		case "22P02", "22007", "22008", "22003", "22001": // invalid text, datetime, range or length
+			return &APIError{Status: 400, Code: "invalid_input", Message: pgErr.Message}
+		}
+	}
+	return &APIError{Status: 500, Code: "internal", Message: "Internal server error"}
+}
+
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestid").(string)
+	return id
+}
+
+// errorHandler renders every error returned by a handler as an APIError.
+// Internal errors are logged with the request id rather than sent back.
+func errorHandler(c *fiber.Ctx, err error) error {
+	apiErr := *toAPIError(err)
+	apiErr.RequestID = requestID(c)
+	if apiErr.Status >= 500 {
+		log.Printf("[%s] %s %s: %v", apiErr.RequestID, c.Method(), c.Path(), err)
+	}
+	return c.Status(apiErr.Status).JSON(apiErr)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Board event types. Every mutation that changes what a board looks like is
+// recorded under one of these so clients can replay what they missed.
+const (
+	EventTaskCreated        = "task.created"
+	EventTaskUpdated        = "task.updated"
+	EventCommentCreated     = "comment.created"
+	EventDocCreated         = "doc.created"
+	EventDocUpdated         = "doc.updated"
+	EventDocDeleted         = "doc.deleted"
+	EventBoardMemberAdded   = "board.member_added"
+	EventBoardMemberRemoved = "board.member_removed"
+)
+
+const (
+	eventReplayPageSize = 500
+	eventSubBuffer      = 256
+	sseKeepAlive        = 15 * time.Second
+)
+
+type BoardEvent struct {
+	Seq       int64           `json:"seq"`
+	BoardID   string          `json:"board_id"`
+	Type      string          `json:"type"`
+	TaskID    *int            `json:"task_id,omitempty"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// wsClientMessage is what a WebSocket client may send to (re)subscribe to a
+// board, optionally resuming after the last sequence it has seen.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	BoardID string `json:"board_id"`
+	Since   *int64 `json:"since"`
+}
+
+type subscription struct {
+	boardID    string
+	ch         chan BoardEvent
+	overflowed bool
+}
+
+// eventHub fans out committed board events to live subscribers. A subscriber
+// with an empty boardID receives events for every board.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+var hub = &eventHub{subs: make(map[*subscription]struct{})}
+
+func (h *eventHub) subscribe(boardID string) *subscription {
+	s := &subscription{boardID: boardID, ch: make(chan BoardEvent, eventSubBuffer)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *eventHub) unsubscribe(s *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+func (h *eventHub) publish(ev BoardEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.boardID != "" && s.boardID != ev.BoardID {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			// Slow consumer: drop it rather than block every writer. The client
+			// reconnects and resumes from its last sequence.
+			s.overflowed = true
+			delete(h.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// emitEvent appends an event to the board's log and publishes it to live
+// subscribers. Failures are logged; they never fail the originating request.
+func emitEvent(boardID, eventType string, taskID *int, actorID string, payload interface{}) {
+	var raw json.RawMessage
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("Event marshal err: %v", err)
+			return
+		}
+		raw = b
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		log.Printf("Event tx err: %v", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	ev := BoardEvent{BoardID: boardID, Type: eventType, TaskID: taskID, ActorID: actorID, Payload: raw}
+	// Bumping the counter row-locks the board, so sequences are gap-free and
+	// committed in order.
+	err = tx.QueryRow(ctx, "UPDATE boards SET event_seq = event_seq + 1 WHERE id=$1 RETURNING event_seq", boardID).Scan(&ev.Seq)
+	if err != nil {
+		log.Printf("Event seq err: %v", err)
+		return
+	}
+	err = tx.QueryRow(ctx,
+		"INSERT INTO board_events (board_id, seq, type, task_id, actor_id, payload) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at",
+		boardID, ev.Seq, ev.Type, ev.TaskID, ev.ActorID, ev.Payload).Scan(&ev.CreatedAt)
+	if err != nil {
+		log.Printf("Event insert err: %v", err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Event commit err: %v", err)
+		return
+	}
+	invalidateCache(boardID, cacheKindsFor(eventType)...)
+	hub.publish(ev)
+}
+
+func queryEvents(boardID string, since int64, limit int) ([]BoardEvent, error) {
+	rows, err := db.Query(context.Background(),
+		"SELECT seq, board_id::text, type, task_id, COALESCE(actor_id, ''), payload, created_at FROM board_events WHERE board_id=$1 AND seq > $2 ORDER BY seq ASC LIMIT $3",
+		boardID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []BoardEvent
+	for rows.Next() {
+		var ev BoardEvent
+		if err := rows.Scan(&ev.Seq, &ev.BoardID, &ev.Type, &ev.TaskID, &ev.ActorID, &ev.Payload, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if events == nil {
+		events = []BoardEvent{}
+	}
+	return events, rows.Err()
+}
+
+func taskBoardID(taskID int) (string, error) {
+	var boardID string
+	err := db.QueryRow(context.Background(), "SELECT board_id::text FROM tasks WHERE id=$1", taskID).Scan(&boardID)
+	return boardID, err
+}
+
+func getBoardEvents(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	since, err := strconv.ParseInt(c.Query("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		return invalidField("since", "must be a non-negative integer")
+	}
+	limit := c.QueryInt("limit", eventReplayPageSize)
+	if limit <= 0 || limit > eventReplayPageSize {
+		limit = eventReplayPageSize
+	}
+	events, err := queryEvents(boardID, since, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(events)
+}
+
+// streamEvents delivers the events after `since` (when resuming) followed by
+// live events from sub, skipping anything already sent. Events are published
+// after their transaction commits, so they can reach the hub out of order;
+// a board subscription that sees a gap reads it from the log instead. It
+// returns when the subscription ends; overflowed reports whether the hub
+// dropped it.
+func streamEvents(sub *subscription, since int64, send func(BoardEvent) error) (overflowed bool, err error) {
+	last := since
+	replay := func() error {
+		for {
+			events, err := queryEvents(sub.boardID, last, eventReplayPageSize)
+			if err != nil {
+				return err
+			}
+			for _, ev := range events {
+				if err := send(ev); err != nil {
+					return err
+				}
+				last = ev.Seq
+			}
+			if len(events) < eventReplayPageSize {
+				return nil
+			}
+		}
+	}
+	if sub.boardID != "" {
+		if last < 0 {
+			// Live only: start at the board's current sequence.
+			err := db.QueryRow(context.Background(),
+				"SELECT COALESCE(MAX(event_seq), 0) FROM boards WHERE id=$1", sub.boardID).Scan(&last)
+			if err != nil {
+				return false, err
+			}
+		}
+		if err := replay(); err != nil {
+			return false, err
+		}
+	}
+	for ev := range sub.ch {
+		if sub.boardID != "" {
+			if ev.Seq > last+1 {
+				if err := replay(); err != nil {
+					return false, err
+				}
+			}
+			if ev.Seq <= last {
+				continue
+			}
+		}
+		if err := send(ev); err != nil {
+			return false, err
+		}
+		if sub.boardID != "" {
+			last = ev.Seq
+		}
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return sub.overflowed, nil
+}
+
+// serveWS streams one board at a time to a member of it; the handshake
+// middleware has already required a caller.
+func serveWS(c *websocket.Conn) {
+	var writeMu sync.Mutex
+	send := func(ev BoardEvent) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return c.WriteJSON(ev)
+	}
+	caller, _ := c.Locals(callerLocal).(string)
+
+	var sub *subscription
+	start := func(boardID string, since int64) bool {
+		if boardID == "" || boardRole(boardID, caller) == "" {
+			writeMu.Lock()
+			c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Not a member of this board"))
+			writeMu.Unlock()
+			return false
+		}
+		if sub != nil {
+			hub.unsubscribe(sub)
+		}
+		sub = hub.subscribe(boardID)
+		go func(s *subscription) {
+			overflowed, err := streamEvents(s, since, send)
+			if err != nil {
+				log.Println("WS write error:", err)
+			}
+			if overflowed || err != nil {
+				c.Close()
+			}
+		}(sub)
+		return true
+	}
+	defer func() {
+		if sub != nil {
+			hub.unsubscribe(sub)
+		}
+		c.Close()
+	}()
+
+	since := int64(-1)
+	if s, err := strconv.ParseInt(c.Query("since"), 10, 64); err == nil && s >= 0 {
+		since = s
+	}
+	if !start(c.Query("board_id"), since) {
+		return
+	}
+
+	for {
+		_, raw, err := c.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg wsClientMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			continue
+		}
+		if msg.Type != "subscribe" && msg.Type != "resume" {
+			continue
+		}
+		since := int64(-1)
+		if msg.Since != nil && *msg.Since >= 0 {
+			since = *msg.Since
+		}
+		if !start(msg.BoardID, since) {
+			return
+		}
+	}
+}
+
+// streamBoardEvents is the Server-Sent Events counterpart of /ws: the same
+// events from the same hub, resumable via Last-Event-ID (or ?since=).
+func streamBoardEvents(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	if _, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor, RoleViewer); err != nil {
+		return err
+	}
+	since := int64(-1)
+	if v := c.Get("Last-Event-ID", c.Query("since")); v != "" {
+		s, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || s < 0 {
+			return fiber.NewError(400, "Last-Event-ID must be a non-negative integer")
+		}
+		since = s
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub := hub.subscribe(boardID)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer hub.unsubscribe(sub)
+
+		var writeMu sync.Mutex
+		write := func(chunk string) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if _, err := w.WriteString(chunk); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+		send := func(ev BoardEvent) error {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			return write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data))
+		}
+
+		if err := write("retry: 3000\n\n"); err != nil {
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if _, err := streamEvents(sub, since, send); err != nil {
+				log.Println("SSE write error:", err)
+			}
+		}()
+		// w is only ours until we return: end the stream and wait for it.
+		defer func() {
+			hub.unsubscribe(sub)
+			<-done
+		}()
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := write(": keepalive\n\n"); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
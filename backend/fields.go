@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const EventFieldChanged = "field.changed"
+
+type FieldType string
+
+const (
+	FieldText        FieldType = "text"
+	FieldNumber      FieldType = "number"
+	FieldDate        FieldType = "date"
+	FieldSelect      FieldType = "select"
+	FieldMultiSelect FieldType = "multi_select"
+	FieldURL         FieldType = "url"
+	FieldMember      FieldType = "member"
+)
+
+func (t FieldType) Valid() bool {
+	switch t {
+	case FieldText, FieldNumber, FieldDate, FieldSelect, FieldMultiSelect, FieldURL, FieldMember:
+		return true
+	}
+	return false
+}
+
+// FieldDisplay carries presentation hints for the frontend.
+type FieldDisplay struct {
+	ShowOnCard  bool   `json:"show_on_card,omitempty" yaml:"show_on_card,omitempty"`
+	Placeholder string `json:"placeholder,omitempty" yaml:"placeholder,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// CustomFieldSpec defines a board custom field. Task values are stored in
+// tasks.custom_fields under Key.
+type CustomFieldSpec struct {
+	Key      string       `json:"key" yaml:"key"`
+	Name     string       `json:"name" yaml:"name"`
+	Type     FieldType    `json:"type" yaml:"type"`
+	Options  []string     `json:"options,omitempty" yaml:"options,omitempty"`
+	Required bool         `json:"required,omitempty" yaml:"required,omitempty"`
+	Position int          `json:"position" yaml:"position"`
+	Display  FieldDisplay `json:"display" yaml:"display,omitempty"`
+}
+
+type CustomField struct {
+	ID      int    `json:"id"`
+	BoardID string `json:"board_id"`
+	CustomFieldSpec
+}
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+func normalizeField(f *CustomFieldSpec) error {
+	if !fieldKeyPattern.MatchString(f.Key) {
+		return invalidField("key", "must be lowercase letters, digits or '_' and start with a letter")
+	}
+	if strings.TrimSpace(f.Name) == "" {
+		f.Name = f.Key
+	}
+	if !f.Type.Valid() {
+		return invalidField("type", "must be text, number, date, select, multi_select, url or member")
+	}
+	if f.Type == FieldSelect || f.Type == FieldMultiSelect {
+		if len(f.Options) == 0 {
+			return invalidField("options", "are required for %s fields", f.Type)
+		}
+		seen := map[string]bool{}
+		for _, o := range f.Options {
+			if o == "" || seen[o] {
+				return invalidField("options", "must be non-empty and unique")
+			}
+			seen[o] = true
+		}
+	} else {
+		f.Options = nil
+	}
+	return nil
+}
+
+// normalizeFields validates a set of field specs and rejects duplicate keys.
+func normalizeFields(fields []CustomFieldSpec) error {
+	seen := map[string]bool{}
+	for i := range fields {
+		if err := normalizeField(&fields[i]); err != nil {
+			return fmt.Errorf("fields[%d] (%s): %v", i, fields[i].Key, err)
+		}
+		if seen[fields[i].Key] {
+			return fmt.Errorf("fields[%d]: %q is listed twice", i, fields[i].Key)
+		}
+		seen[fields[i].Key] = true
+	}
+	return nil
+}
+
+const fieldColumns = "id, board_id::text, key, name, type, options, required, position, display"
+
+func scanField(row pgx.Row) (CustomField, error) {
+	var f CustomField
+	err := row.Scan(&f.ID, &f.BoardID, &f.Key, &f.Name, &f.Type, &f.Options, &f.Required, &f.Position, &f.Display)
+	return f, err
+}
+
+func loadFields(q dbtx, boardID string) ([]CustomField, error) {
+	rows, err := q.Query(context.Background(), "SELECT "+fieldColumns+" FROM custom_fields WHERE board_id=$1 ORDER BY position, id", boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fields := []CustomField{}
+	for rows.Next() {
+		f, err := scanField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+func insertFields(q dbtx, boardID string, fields []CustomFieldSpec) error {
+	for _, f := range fields {
+		if _, err := q.Exec(context.Background(), `
+			INSERT INTO custom_fields (board_id, key, name, type, options, required, position, display)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`,
+			boardID, f.Key, f.Name, f.Type, f.Options, f.Required, f.Position, f.Display); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldValue checks v against f and returns its stored form: text, url,
+// select and member values are strings, numbers are float64, dates are
+// YYYY-MM-DD and multi-select values are string slices.
+func fieldValue(f CustomField, v interface{}) (interface{}, error) {
+	bad := func(want string) error { return fmt.Errorf("custom field %s must be %s", f.Key, want) }
+	switch f.Type {
+	case FieldNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return nil, bad("a number")
+		}
+		return n, nil
+	case FieldMultiSelect:
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, bad("a list of options")
+		}
+		out := []string{}
+		seen := map[string]bool{}
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || !contains(f.Options, s) {
+				return nil, bad("a list of: " + strings.Join(f.Options, ", "))
+			}
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, bad("a string")
+	}
+	switch f.Type {
+	case FieldDate:
+		if d, err := time.Parse("2006-01-02", s); err == nil {
+			return d.Format("2006-01-02"), nil
+		}
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, bad("a date (YYYY-MM-DD)")
+		}
+		return d.UTC().Format("2006-01-02"), nil
+	case FieldSelect:
+		if !contains(f.Options, s) {
+			return nil, bad("one of: " + strings.Join(f.Options, ", "))
+		}
+	case FieldURL:
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, bad("an http(s) URL")
+		}
+	case FieldMember:
+		var active bool
+		if err := db.QueryRow(context.Background(), "SELECT active FROM members WHERE id=$1", s).Scan(&active); err != nil || !active {
+			return nil, bad("an active member id")
+		}
+	}
+	return s, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCustomValues validates changes against the board's fields and merges
+// them into current. A null value clears a field. When creating, required
+// fields must be present.
+func applyCustomValues(boardID string, current, changes map[string]interface{}, creating bool) (map[string]interface{}, error) {
+	fields, err := loadFields(db, boardID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]CustomField{}
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+	merged := map[string]interface{}{}
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range changes {
+		f, ok := byKey[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field %q", k)
+		}
+		if v == nil {
+			if f.Required {
+				return nil, fmt.Errorf("custom field %s is required", k)
+			}
+			delete(merged, k)
+			continue
+		}
+		if merged[k], err = fieldValue(f, v); err != nil {
+			return nil, err
+		}
+	}
+	if creating {
+		for _, f := range fields {
+			if _, ok := merged[f.Key]; f.Required && !ok {
+				return nil, fmt.Errorf("custom field %s is required", f.Key)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// formatFieldValue renders a stored value for activities and CSV.
+func formatFieldValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case []string:
+		return strings.Join(x, "; ")
+	case []interface{}:
+		parts := make([]string, len(x))
+		for i, p := range x {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, "; ")
+	}
+	return fmt.Sprint(v)
+}
+
+// customFieldFilter adds clauses for cf.<key>=value (exact match, or
+// membership for multi-select) and cf.<key>.gte / cf.<key>.lte (numeric when
+// the bound is a number, lexical otherwise, which orders dates correctly).
+func customFieldFilter(c *fiber.Ctx, where []string, arg func(interface{}) string) ([]string, error) {
+	queries := c.Queries()
+	keys := make([]string, 0, len(queries))
+	for k := range queries {
+		if strings.HasPrefix(k, "cf.") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, param := range keys {
+		value := queries[param]
+		key, op, _ := strings.Cut(strings.TrimPrefix(param, "cf."), ".")
+		if !fieldKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid custom field %q", key)
+		}
+		k := arg(key)
+		switch op {
+		case "":
+			v := arg(value)
+			where = append(where, "(t.custom_fields->>"+k+" = "+v+" OR t.custom_fields->"+k+" @> jsonb_build_array("+v+"::text))")
+		case "gte", "lte":
+			cmp := map[string]string{"gte": ">=", "lte": "<="}[op]
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				where = append(where, "(CASE WHEN jsonb_typeof(t.custom_fields->"+k+") = 'number' THEN (t.custom_fields->>"+k+")::numeric END) "+cmp+" "+arg(n))
+			} else {
+				where = append(where, "t.custom_fields->>"+k+" "+cmp+" "+arg(value))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported custom field operator %q", op)
+		}
+	}
+	return where, nil
+}
+
+// taskSort is a parsed task sort key: position (the default), priority,
+// due_date, start_date, title, created or cf.<key>, optionally prefixed with
+// '-' for descending order. Nulls sort last and ties break on task id.
+type taskSort struct {
+	Key  string
+	Expr string
+	Desc bool
+}
+
+func parseTaskSort(sortBy string) (taskSort, error) {
+	if sortBy == "" {
+		sortBy = "position"
+	}
+	var s taskSort
+	if strings.HasPrefix(sortBy, "-") {
+		sortBy, s.Desc = sortBy[1:], true
+	}
+	s.Key = sortBy
+	switch sortBy {
+	case "position":
+		s.Expr = "t.position"
+	case "priority":
+		s.Expr = priorityRankSQL
+	case "due_date", "start_date", "title":
+		s.Expr = "t." + sortBy
+	case "created":
+		s.Expr = "t.id"
+	default:
+		key := strings.TrimPrefix(sortBy, "cf.")
+		if !strings.HasPrefix(sortBy, "cf.") || !fieldKeyPattern.MatchString(key) {
+			return s, fmt.Errorf("cannot sort by %q", sortBy)
+		}
+		// jsonb orders numbers numerically and strings lexically.
+		s.Expr = "t.custom_fields->'" + key + "'"
+	}
+	return s, nil
+}
+
+// taskOrder builds the ORDER BY clause for a sort key.
+func taskOrder(sortBy string) (string, error) {
+	s, err := parseTaskSort(sortBy)
+	if err != nil {
+		return "", err
+	}
+	dir := "ASC"
+	if s.Desc {
+		dir = "DESC"
+	}
+	return s.Expr + " " + dir + " NULLS LAST, t.id ASC", nil
+}
+
+// value returns t's sort value as SQL sees it, or nil when it is null.
+func (s taskSort) value(t Task) interface{} {
+	switch s.Key {
+	case "position":
+		return t.Position
+	case "priority":
+		return priorityRank[t.Priority]
+	case "due_date":
+		if t.DueDate != nil {
+			return *t.DueDate
+		}
+	case "start_date":
+		if t.StartDate != nil {
+			return *t.StartDate
+		}
+	case "title":
+		return t.Title
+	case "created":
+	default:
+		if v := t.CustomFields[strings.TrimPrefix(s.Key, "cf.")]; v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// after builds the keyset condition selecting tasks that sort after cur.
+func (s taskSort) after(cur *pageCursor, arg func(interface{}) string) (string, error) {
+	cmp := ">"
+	if s.Desc {
+		cmp = "<"
+	}
+	if s.Key == "created" {
+		return "t.id " + cmp + " " + arg(cur.ID), nil
+	}
+	if cur.Value == nil {
+		return "(" + s.Expr + " IS NULL AND t.id > " + arg(cur.ID) + ")", nil
+	}
+	var v string
+	switch s.Key {
+	case "position", "priority":
+		var n int
+		if err := json.Unmarshal(cur.Value, &n); err != nil {
+			return "", err
+		}
+		v = arg(n)
+	case "due_date", "start_date":
+		var ts time.Time
+		if err := json.Unmarshal(cur.Value, &ts); err != nil {
+			return "", err
+		}
+		v = arg(ts)
+	case "title":
+		var title string
+		if err := json.Unmarshal(cur.Value, &title); err != nil {
+			return "", err
+		}
+		v = arg(title)
+	default:
+		v = arg(string(cur.Value)) + "::jsonb"
+	}
+	return "(" + s.Expr + " " + cmp + " " + v + " OR (" + s.Expr + " = " + v + " AND t.id > " + arg(cur.ID) + ") OR " + s.Expr + " IS NULL)", nil
+}
+
+// taskCursor derives page cursors for tasks listed in sortBy order.
+func taskCursor(sortBy string) func(Task) pageCursor {
+	s, _ := parseTaskSort(sortBy)
+	return func(t Task) pageCursor { return cursorAt(sortBy, s.value(t), t.ID, "") }
+}
+
+func getBoardFields(c *fiber.Ctx) error {
+	fields, err := loadFields(db, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(fields)
+}
+
+func createField(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	spec := new(CustomFieldSpec)
+	if err := parseBody(c, spec); err != nil {
+		return err
+	}
+	if err := normalizeField(spec); err != nil {
+		return badRequest(err)
+	}
+	f, err := scanField(db.QueryRow(context.Background(), `
+		INSERT INTO custom_fields (board_id, key, name, type, options, required, position, display)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING RETURNING `+fieldColumns,
+		boardID, spec.Key, spec.Name, spec.Type, spec.Options, spec.Required, spec.Position, spec.Display))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(409, "A field with this key already exists")
+	}
+	if err != nil {
+		return err
+	}
+	emitEvent(boardID, EventFieldChanged, nil, caller, f)
+	return c.Status(201).JSON(f)
+}
+
+// updateField changes a field's name, options, required flag, position or
+// display. Key and type are fixed once created.
+func updateField(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	f, err := scanField(db.QueryRow(context.Background(), "SELECT "+fieldColumns+" FROM custom_fields WHERE id=$1", id))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Field not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, f.BoardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	spec := f.CustomFieldSpec
+	if err := parseBody(c, &spec); err != nil {
+		return err
+	}
+	if spec.Key != f.Key || spec.Type != f.Type {
+		return fiber.NewError(400, "A field's key and type cannot be changed")
+	}
+	if err := normalizeField(&spec); err != nil {
+		return badRequest(err)
+	}
+	f.CustomFieldSpec = spec
+	_, err = db.Exec(context.Background(),
+		"UPDATE custom_fields SET name=$1, options=$2, required=$3, position=$4, display=$5 WHERE id=$6",
+		spec.Name, spec.Options, spec.Required, spec.Position, spec.Display, id)
+	if err != nil {
+		return err
+	}
+	emitEvent(f.BoardID, EventFieldChanged, nil, caller, f)
+	return c.JSON(f)
+}
+
+// deleteField removes a field and its values from every task on the board.
+func deleteField(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	f, err := scanField(db.QueryRow(context.Background(), "SELECT "+fieldColumns+" FROM custom_fields WHERE id=$1", id))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Field not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, f.BoardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := removeField(db, f.BoardID, f.Key); err != nil {
+		return err
+	}
+	emitEvent(f.BoardID, EventFieldChanged, nil, caller, fiber.Map{"id": id, "key": f.Key, "deleted": true})
+	return c.SendStatus(200)
+}
+
+func removeField(q dbtx, boardID, key string) error {
+	ctx := context.Background()
+	if _, err := q.Exec(ctx, "DELETE FROM custom_fields WHERE board_id=$1 AND key=$2", boardID, key); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx, "UPDATE tasks SET custom_fields = custom_fields - $2 WHERE board_id=$1 AND custom_fields ? $2", boardID, key)
+	return err
+}
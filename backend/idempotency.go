@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Mutating requests may carry an Idempotency-Key. The first request with a
+// key reserves it, and its response is stored together with a fingerprint
+// of the request; retries with the same key replay that response instead of
+// running the handler again. Keys are scoped to the caller and expire after
+// IDEMPOTENCY_TTL (default 24h).
+const (
+	idempotencyHeader = "Idempotency-Key"
+	maxIdempotencyKey = 255
+)
+
+func idempotencyTTL() time.Duration {
+	if v, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && v > 0 {
+		return v
+	}
+	return 24 * time.Hour
+}
+
+func requestFingerprint(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method() + " " + c.Path() + "?"))
+	h.Write(c.Request().URI().QueryString())
+	h.Write([]byte("\n"))
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotency is the middleware for POST, PUT and PATCH. A replay gets the
+// stored status and body with Idempotent-Replayed: true; the same key with a
+// different method, path, query or body gets 422, and a retry that arrives while
+// the first request is still running gets 409. Responses with a 5xx status
+// are not kept, so the request can be retried.
+func idempotency(c *fiber.Ctx) error {
+	key := c.Get(idempotencyHeader)
+	if key == "" {
+		return c.Next()
+	}
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch:
+	default:
+		return c.Next()
+	}
+	if len(key) > maxIdempotencyKey {
+		return fiber.NewError(400, "Idempotency-Key is too long")
+	}
+
+	ctx := context.Background()
+	subject := rateLimitSubject(c)
+	fingerprint := requestFingerprint(c)
+	expiry := time.Now().Add(-idempotencyTTL())
+	db.Exec(ctx, "DELETE FROM idempotency_keys WHERE subject=$1 AND key=$2 AND created_at < $3", subject, key, expiry)
+	result, err := db.Exec(ctx,
+		"INSERT INTO idempotency_keys (subject, key, fingerprint) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		subject, key, fingerprint)
+	if err != nil {
+		log.Printf("Idempotency reserve err: %v", err)
+		return c.Next()
+	}
+	if result.RowsAffected() == 0 {
+		var storedFingerprint, contentType string
+		var status *int
+		var body []byte
+		err := db.QueryRow(ctx,
+			"SELECT fingerprint, status, COALESCE(content_type, ''), body FROM idempotency_keys WHERE subject=$1 AND key=$2",
+			subject, key).Scan(&storedFingerprint, &status, &contentType, &body)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(409, "Idempotency-Key was released; retry the request")
+		}
+		if err != nil {
+			return err
+		}
+		if storedFingerprint != fingerprint {
+			return fiber.NewError(422, "Idempotency-Key was already used for a different request")
+		}
+		if status == nil {
+			return fiber.NewError(409, "A request with this Idempotency-Key is still in progress")
+		}
+		c.Set("Idempotent-Replayed", "true")
+		if contentType != "" {
+			c.Set(fiber.HeaderContentType, contentType)
+		}
+		return c.Status(*status).Send(body)
+	}
+
+	// Render errors here so the stored response is what the client saw.
+	if err := c.Next(); err != nil {
+		if herr := c.App().Config().ErrorHandler(c, err); herr != nil {
+			db.Exec(ctx, "DELETE FROM idempotency_keys WHERE subject=$1 AND key=$2", subject, key)
+			return herr
+		}
+	}
+	status := c.Response().StatusCode()
+	if status >= 500 {
+		db.Exec(ctx, "DELETE FROM idempotency_keys WHERE subject=$1 AND key=$2", subject, key)
+		return nil
+	}
+	_, err = db.Exec(ctx, "UPDATE idempotency_keys SET status=$1, content_type=$2, body=$3 WHERE subject=$4 AND key=$5",
+		status, string(c.Response().Header.ContentType()), c.Response().Body(), subject, key)
+	if err != nil {
+		log.Printf("Idempotency store err: %v", err)
+	}
+	return nil
+}
+
+// startIdempotencyJanitor removes expired keys every hour.
+func startIdempotencyJanitor() {
+	go func() {
+		for {
+			db.Exec(context.Background(), "DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-idempotencyTTL()))
+			time.Sleep(time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,814 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Importers read a Trello board JSON export or a Jira CSV/XML export into an
+// importPlan, which is then replayed through the REST API: the board is
+// created with POST /boards/apply and every task, label, checklist item and
+// comment with the same endpoints the app uses. Imported work therefore gets
+// the same validation, activity log, events and embeddings as work created
+// by hand.
+
+// importPlan is a source board in MoziBoard terms. User references are
+// source user keys (Trello usernames, Jira user names) until mapped.
+type importPlan struct {
+	Source      string
+	Title       string
+	Description string
+	Lists       []List
+	Labels      []LabelSpec
+	Tasks       []importTask
+	Users       map[string]string // source user key -> display name
+	Skipped     []string
+}
+
+type importTask struct {
+	Key         string // source id, referenced by Parent
+	Title       string
+	Description string
+	ListID      string
+	Position    int
+	Assignee    string
+	Priority    Priority
+	DueDate     *time.Time
+	Labels      []string
+	Checklist   []importCheckItem
+	Comments    []importComment
+	Parent      string
+}
+
+type importCheckItem struct {
+	Text string
+	Done bool
+}
+
+type importComment struct {
+	Author string
+	Text   string
+	At     time.Time
+}
+
+// memberMapping is the -members file. Members maps source users (by key or
+// display name, case-insensitively) to MoziBoard member ids; mapped members
+// that do not exist yet are created. With create_missing, unmapped users
+// become members with an id derived from their key; otherwise their tasks
+// are left unassigned and their comments are posted by the importer.
+type memberMapping struct {
+	Members       map[string]string `yaml:"members" json:"members"`
+	CreateMissing bool              `yaml:"create_missing" json:"create_missing"`
+}
+
+// ImportReport describes what an import creates (or, in a dry run, would).
+type ImportReport struct {
+	Source  string         `json:"source" yaml:"source"`
+	DryRun  bool           `json:"dry_run" yaml:"dry_run"`
+	BoardID string         `json:"board_id,omitempty" yaml:"board_id,omitempty"`
+	Title   string         `json:"title" yaml:"title"`
+	Lists   []List         `json:"lists" yaml:"lists"`
+	Labels  []LabelSpec    `json:"labels" yaml:"labels"`
+	Counts  map[string]int `json:"counts" yaml:"counts"`
+	Members []ImportMember `json:"members" yaml:"members"`
+	Skipped []string       `json:"skipped" yaml:"skipped"`
+	Errors  []string       `json:"errors" yaml:"errors"`
+}
+
+// ImportMember maps one source user. Action is existing, create or
+// unmapped.
+type ImportMember struct {
+	Source   string `json:"source" yaml:"source"`
+	Name     string `json:"name" yaml:"name"`
+	MemberID string `json:"member_id,omitempty" yaml:"member_id,omitempty"`
+	Action   string `json:"action" yaml:"action"`
+}
+
+func loadMemberMapping(path string) (memberMapping, error) {
+	var m memberMapping
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// importListID derives a list id from a source list name, unique within
+// taken.
+func importListID(name string, taken map[string]bool) string {
+	base := slugify(name)
+	id := base
+	for n := 2; taken[id]; n++ {
+		id = base + "-" + strconv.Itoa(n)
+	}
+	taken[id] = true
+	return id
+}
+
+// guessCategory infers a list's workflow category from its name.
+func guessCategory(name string) ListCategory {
+	n := strings.ToLower(name)
+	for _, w := range []string{"done", "complete", "closed", "resolved", "shipped", "released"} {
+		if strings.Contains(n, w) {
+			return ListDone
+		}
+	}
+	for _, w := range []string{"doing", "progress", "review", "testing", "qa", "active"} {
+		if strings.Contains(n, w) {
+			return ListDoing
+		}
+	}
+	return ListTodo
+}
+
+var trelloColors = map[string]string{
+	"green": "#61bd4f", "yellow": "#f2d600", "orange": "#ff9f1a", "red": "#eb5a46", "purple": "#c377e0",
+	"blue": "#0079bf", "sky": "#00c2e0", "lime": "#51e898", "pink": "#ff78cb", "black": "#344563",
+}
+
+type trelloExport struct {
+	Name  string `json:"name"`
+	Desc  string `json:"desc"`
+	Lists []struct {
+		ID     string  `json:"id"`
+		Name   string  `json:"name"`
+		Closed bool    `json:"closed"`
+		Pos    float64 `json:"pos"`
+	} `json:"lists"`
+	Cards []struct {
+		ID           string     `json:"id"`
+		Name         string     `json:"name"`
+		Desc         string     `json:"desc"`
+		IDList       string     `json:"idList"`
+		Closed       bool       `json:"closed"`
+		Pos          float64    `json:"pos"`
+		Due          *time.Time `json:"due"`
+		ShortURL     string     `json:"shortUrl"`
+		IDMembers    []string   `json:"idMembers"`
+		IDLabels     []string   `json:"idLabels"`
+		IDChecklists []string   `json:"idChecklists"`
+	} `json:"cards"`
+	Labels []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	Checklists []struct {
+		ID         string  `json:"id"`
+		IDCard     string  `json:"idCard"`
+		Name       string  `json:"name"`
+		Pos        float64 `json:"pos"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+	Members []struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		FullName string `json:"fullName"`
+	} `json:"members"`
+	Actions []struct {
+		Type            string    `json:"type"`
+		Date            time.Time `json:"date"`
+		IDMemberCreator string    `json:"idMemberCreator"`
+		MemberCreator   struct {
+			Username string `json:"username"`
+			FullName string `json:"fullName"`
+		} `json:"memberCreator"`
+		Data struct {
+			Text string `json:"text"`
+			Card struct {
+				ID string `json:"id"`
+			} `json:"card"`
+		} `json:"data"`
+	} `json:"actions"`
+}
+
+// parseTrello reads a board exported with Trello's "Export as JSON". Archived
+// lists and cards are skipped; a card's first member becomes its assignee.
+func parseTrello(data []byte) (*importPlan, error) {
+	var ex trelloExport
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, fmt.Errorf("not a Trello board export: %w", err)
+	}
+	if ex.Name == "" || ex.Lists == nil {
+		return nil, fmt.Errorf("not a Trello board export: no board name or lists")
+	}
+	p := &importPlan{Source: "trello", Title: ex.Name, Description: ex.Desc, Users: map[string]string{}}
+
+	users := map[string]string{} // Trello member id -> username
+	for _, m := range ex.Members {
+		users[m.ID] = m.Username
+		p.Users[m.Username] = m.FullName
+	}
+
+	sort.SliceStable(ex.Lists, func(i, j int) bool { return ex.Lists[i].Pos < ex.Lists[j].Pos })
+	lists := map[string]string{}
+	taken := map[string]bool{}
+	for _, l := range ex.Lists {
+		if l.Closed {
+			p.Skipped = append(p.Skipped, fmt.Sprintf("archived list %q", l.Name))
+			continue
+		}
+		id := importListID(l.Name, taken)
+		lists[l.ID] = id
+		p.Lists = append(p.Lists, List{ID: id, Title: l.Name, Position: len(p.Lists), Category: guessCategory(l.Name)})
+	}
+
+	labels := map[string]string{}
+	seen := map[string]bool{}
+	for _, l := range ex.Labels {
+		name := strings.TrimSpace(l.Name)
+		if name == "" {
+			name = capitalize(l.Color)
+		}
+		if name == "" || seen[strings.ToLower(name)] {
+			labels[l.ID] = name
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		labels[l.ID] = name
+		color := trelloColors[strings.TrimSuffix(strings.TrimSuffix(l.Color, "_dark"), "_light")]
+		p.Labels = append(p.Labels, LabelSpec{Name: name, Color: color})
+	}
+
+	checklists := map[string][]int{} // card id -> checklist indexes
+	for i, cl := range ex.Checklists {
+		checklists[cl.IDCard] = append(checklists[cl.IDCard], i)
+	}
+	comments := map[string][]importComment{}
+	for i := len(ex.Actions) - 1; i >= 0; i-- { // actions are newest first
+		a := ex.Actions[i]
+		if a.Type != "commentCard" {
+			continue
+		}
+		author := a.MemberCreator.Username
+		if author == "" {
+			author = users[a.IDMemberCreator]
+		}
+		if author != "" && p.Users[author] == "" {
+			p.Users[author] = a.MemberCreator.FullName
+		}
+		comments[a.Data.Card.ID] = append(comments[a.Data.Card.ID], importComment{Author: author, Text: a.Data.Text, At: a.Date})
+	}
+
+	sort.SliceStable(ex.Cards, func(i, j int) bool { return ex.Cards[i].Pos < ex.Cards[j].Pos })
+	positions := map[string]int{}
+	for _, c := range ex.Cards {
+		listID, ok := lists[c.IDList]
+		switch {
+		case c.Closed:
+			p.Skipped = append(p.Skipped, fmt.Sprintf("archived card %q", c.Name))
+			continue
+		case !ok:
+			p.Skipped = append(p.Skipped, fmt.Sprintf("card %q in an archived list", c.Name))
+			continue
+		}
+		t := importTask{Key: c.ID, Title: c.Name, Description: c.Desc, ListID: listID, Position: positions[listID],
+			Priority: PriorityMedium, DueDate: c.Due, Comments: comments[c.ID]}
+		positions[listID]++
+		if c.ShortURL != "" {
+			t.Description = importFooter(t.Description, "Imported from Trello: "+c.ShortURL)
+		}
+		if len(c.IDMembers) > 0 {
+			t.Assignee = users[c.IDMembers[0]]
+		}
+		for _, id := range c.IDLabels {
+			if name := labels[id]; name != "" {
+				t.Labels = append(t.Labels, name)
+			}
+		}
+		cardLists := checklists[c.ID]
+		sort.SliceStable(cardLists, func(i, j int) bool { return ex.Checklists[cardLists[i]].Pos < ex.Checklists[cardLists[j]].Pos })
+		for _, i := range cardLists {
+			cl := ex.Checklists[i]
+			items := cl.CheckItems
+			sort.SliceStable(items, func(i, j int) bool { return items[i].Pos < items[j].Pos })
+			for _, item := range items {
+				text := item.Name
+				if len(cardLists) > 1 {
+					text = cl.Name + ": " + text
+				}
+				t.Checklist = append(t.Checklist, importCheckItem{Text: text, Done: item.State == "complete"})
+			}
+		}
+		p.Tasks = append(p.Tasks, t)
+	}
+	return p, nil
+}
+
+func importFooter(description, note string) string {
+	if description == "" {
+		return "_" + note + "_"
+	}
+	return description + "\n\n_" + note + "_"
+}
+
+// jiraPriority maps Jira's default priority schemes onto MoziBoard's.
+func jiraPriority(name string) Priority {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "highest", "blocker", "critical":
+		return PriorityUrgent
+	case "high", "major":
+		return PriorityHigh
+	case "low", "lowest", "minor", "trivial":
+		return PriorityLow
+	}
+	return PriorityMedium
+}
+
+var jiraDateLayouts = []string{
+	"02/Jan/06 3:04 PM", "2/Jan/06 3:04 PM", "02/Jan/06", "2/Jan/06",
+	"Mon, 2 Jan 2006 15:04:05 -0700", time.RFC1123Z, time.RFC3339,
+	"2006-01-02 15:04", "2006-01-02",
+}
+
+func parseJiraDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range jiraDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText flattens the HTML of Jira's XML export into plain text.
+func htmlToText(s string) string {
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// jiraBuilder collects issues from either export format. Lists are the
+// statuses in the order they first appear.
+type jiraBuilder struct {
+	plan     *importPlan
+	lists    map[string]string
+	taken    map[string]bool
+	labels   map[string]bool
+	position map[string]int
+}
+
+func newJiraBuilder() *jiraBuilder {
+	return &jiraBuilder{plan: &importPlan{Source: "jira", Users: map[string]string{}},
+		lists: map[string]string{}, taken: map[string]bool{}, labels: map[string]bool{}, position: map[string]int{}}
+}
+
+func (b *jiraBuilder) user(key, name string) string {
+	key = strings.TrimSpace(key)
+	if key == "" || key == "-1" || strings.EqualFold(key, "unassigned") {
+		return ""
+	}
+	if name == "" {
+		name = key
+	}
+	if b.plan.Users[key] == "" {
+		b.plan.Users[key] = name
+	}
+	return key
+}
+
+func (b *jiraBuilder) add(t importTask, issueKey, status string, labels []string) {
+	if status == "" {
+		status = "To Do"
+	}
+	listID, ok := b.lists[status]
+	if !ok {
+		listID = importListID(status, b.taken)
+		b.lists[status] = listID
+		b.plan.Lists = append(b.plan.Lists, List{ID: listID, Title: status, Position: len(b.plan.Lists), Category: guessCategory(status)})
+	}
+	t.ListID = listID
+	t.Position = b.position[listID]
+	b.position[listID]++
+	for _, l := range labels {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if !b.labels[strings.ToLower(l)] {
+			b.labels[strings.ToLower(l)] = true
+			b.plan.Labels = append(b.plan.Labels, LabelSpec{Name: l})
+		}
+		t.Labels = append(t.Labels, l)
+	}
+	if issueKey != "" {
+		t.Title = issueKey + " " + t.Title
+	}
+	b.plan.Tasks = append(b.plan.Tasks, t)
+}
+
+// parseJiraCSV reads an issue search exported as CSV ("all fields" or
+// "current fields"). Multi-valued fields such as Labels and Comment repeat
+// their column.
+func parseJiraCSV(data []byte) (*importPlan, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("not a Jira CSV export: %w", err)
+	}
+	cols := map[string][]int{}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		cols[name] = append(cols[name], i)
+	}
+	if len(cols["summary"]) == 0 {
+		return nil, fmt.Errorf("not a Jira CSV export: no Summary column")
+	}
+	b := newJiraBuilder()
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(name string) string {
+			for _, i := range cols[name] {
+				if i < len(row) && row[i] != "" {
+					return row[i]
+				}
+			}
+			return ""
+		}
+		all := func(name string) []string {
+			var values []string
+			for _, i := range cols[name] {
+				if i < len(row) && row[i] != "" {
+					values = append(values, row[i])
+				}
+			}
+			return values
+		}
+		if b.plan.Title == "" {
+			b.plan.Title = get("project name")
+		}
+		t := importTask{Key: get("issue id"), Title: get("summary"), Description: get("description"),
+			Priority: jiraPriority(get("priority")), Parent: get("parent id")}
+		if t.Parent == "" {
+			t.Parent = get("parent")
+		}
+		if t.Key == "" {
+			t.Key = get("issue key")
+		}
+		t.Assignee = b.user(firstNonEmpty(get("assignee id"), get("assignee")), get("assignee"))
+		if due, ok := parseJiraDate(get("due date")); ok {
+			t.DueDate = &due
+		}
+		// Comments look like "17/Jan/24 10:15 AM;author;text".
+		for _, c := range all("comment") {
+			parts := strings.SplitN(c, ";", 3)
+			if at, ok := parseJiraDate(parts[0]); ok && len(parts) == 3 {
+				t.Comments = append(t.Comments, importComment{Author: b.user(parts[1], ""), Text: parts[2], At: at})
+			} else {
+				t.Comments = append(t.Comments, importComment{Text: c})
+			}
+		}
+		b.add(t, get("issue key"), get("status"), all("labels"))
+	}
+	return b.plan, nil
+}
+
+type jiraRSS struct {
+	Channel struct {
+		Items []struct {
+			Key struct {
+				ID    string `xml:"id,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"key"`
+			Summary     string `xml:"summary"`
+			Description string `xml:"description"`
+			Project     string `xml:"project"`
+			Status      string `xml:"status"`
+			Priority    string `xml:"priority"`
+			Assignee    struct {
+				Username string `xml:"username,attr"`
+				Name     string `xml:",chardata"`
+			} `xml:"assignee"`
+			Due    string `xml:"due"`
+			Parent struct {
+				ID string `xml:"id,attr"`
+			} `xml:"parent"`
+			Labels   []string `xml:"labels>label"`
+			Comments []struct {
+				Author  string `xml:"author,attr"`
+				Created string `xml:"created,attr"`
+				Text    string `xml:",chardata"`
+			} `xml:"comments>comment"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// parseJiraXML reads an issue search exported as XML (RSS).
+func parseJiraXML(data []byte) (*importPlan, error) {
+	var rss jiraRSS
+	if err := xml.Unmarshal(data, &rss); err != nil {
+		return nil, fmt.Errorf("not a Jira XML export: %w", err)
+	}
+	b := newJiraBuilder()
+	for _, it := range rss.Channel.Items {
+		if b.plan.Title == "" {
+			b.plan.Title = it.Project
+		}
+		t := importTask{Key: firstNonEmpty(it.Key.ID, it.Key.Value), Title: it.Summary, Description: htmlToText(it.Description),
+			Priority: jiraPriority(it.Priority), Parent: it.Parent.ID,
+			Assignee: b.user(firstNonEmpty(it.Assignee.Username, it.Assignee.Name), it.Assignee.Name)}
+		if due, ok := parseJiraDate(it.Due); ok {
+			t.DueDate = &due
+		}
+		for _, c := range it.Comments {
+			at, _ := parseJiraDate(c.Created)
+			t.Comments = append(t.Comments, importComment{Author: b.user(c.Author, ""), Text: htmlToText(c.Text), At: at})
+		}
+		b.add(t, it.Key.Value, it.Status, it.Labels)
+	}
+	return b.plan, nil
+}
+
+// parseJira picks the CSV or XML reader by content.
+func parseJira(data []byte) (*importPlan, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return parseJiraXML(data)
+	}
+	return parseJiraCSV(data)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// memberIDFrom derives a member id from a source user key, or "" if none
+// fits the member id rules.
+func memberIDFrom(key string) string {
+	if at := strings.Index(key, "@"); at > 0 {
+		key = key[:at]
+	}
+	id := slugify(key)
+	if len(id) > 32 {
+		id = strings.TrimRight(id[:32], "-")
+	}
+	if id == "untitled" || !memberIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// resolveMembers maps the plan's users through m, checking with the server
+// which members already exist.
+func resolveMembers(client *apiClient, p *importPlan, m memberMapping) ([]ImportMember, error) {
+	mapping := map[string]string{}
+	for k, v := range m.Members {
+		mapping[strings.ToLower(k)] = v
+	}
+	keys := make([]string, 0, len(p.Users))
+	for k := range p.Users {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	members := []ImportMember{}
+	for _, key := range keys {
+		im := ImportMember{Source: key, Name: p.Users[key], Action: "unmapped"}
+		im.MemberID = firstNonEmpty(mapping[strings.ToLower(key)], mapping[strings.ToLower(im.Name)])
+		if im.MemberID == "" && m.CreateMissing {
+			im.MemberID = memberIDFrom(key)
+		}
+		if im.MemberID != "" {
+			var existing Member
+			err := client.do("GET", "/members/"+url.PathEscape(im.MemberID), nil, nil, &existing)
+			switch {
+			case err == nil:
+				im.Action = "existing"
+			case httpStatus(err) == 404:
+				im.Action = "create"
+			default:
+				return nil, err
+			}
+		}
+		members = append(members, im)
+	}
+	return members, nil
+}
+
+// runImport reports what p creates and, unless dryRun, creates it with owner
+// owning the new board. Edits are recorded as the API token's member.
+// It keeps going past failed tasks, collecting their errors in the report.
+func runImport(client *apiClient, p *importPlan, members []ImportMember, owner string, dryRun bool) ImportReport {
+	rep := ImportReport{Source: p.Source, DryRun: dryRun, Title: p.Title, Lists: p.Lists, Labels: p.Labels,
+		Counts:  map[string]int{"lists": len(p.Lists), "labels": len(p.Labels), "tasks": len(p.Tasks)},
+		Members: members, Skipped: p.Skipped, Errors: []string{}}
+	if rep.Labels == nil {
+		rep.Labels = []LabelSpec{}
+	}
+	if rep.Skipped == nil {
+		rep.Skipped = []string{}
+	}
+	for _, t := range p.Tasks {
+		rep.Counts["checklist_items"] += len(t.Checklist)
+		rep.Counts["comments"] += len(t.Comments)
+	}
+	memberIDs := map[string]string{}
+	spec := BoardSpec{Title: p.Title, Description: p.Description, Lists: p.Lists, Labels: p.Labels,
+		Members: []BoardMemberReq{{MemberID: owner, Role: RoleOwner}}}
+	for _, m := range members {
+		if m.Action == "unmapped" {
+			continue
+		}
+		if m.Action == "create" {
+			rep.Counts["members"]++
+		}
+		memberIDs[m.Source] = m.MemberID
+		if m.MemberID != owner && !containsMember(spec.Members, m.MemberID) {
+			spec.Members = append(spec.Members, BoardMemberReq{MemberID: m.MemberID, Role: RoleEditor})
+		}
+	}
+	if dryRun {
+		return rep
+	}
+
+	for _, m := range members {
+		if m.Action != "create" {
+			continue
+		}
+		if err := client.do("POST", "/members", nil, Member{ID: m.MemberID, Name: firstNonEmpty(m.Name, m.Source), Kind: MemberHuman}, nil); err != nil {
+			rep.Errors = append(rep.Errors, fmt.Sprintf("member %s: %v", m.MemberID, err))
+			return rep
+		}
+	}
+	var plan ApplyPlan
+	if err := client.do("POST", "/boards/apply", nil, spec, &plan); err != nil {
+		rep.Errors = append(rep.Errors, fmt.Sprintf("board: %v", err))
+		return rep
+	}
+	rep.BoardID = plan.BoardID
+
+	// Parents are created before their subtasks.
+	taskIDs := map[string]int{}
+	pending := p.Tasks
+	for len(pending) > 0 {
+		var next []importTask
+		for _, t := range pending {
+			if t.Parent != "" && taskIDs[t.Parent] == 0 && hasImportTask(pending, t.Parent) {
+				next = append(next, t)
+				continue
+			}
+			// A task whose labels or comments failed still exists, so its
+			// subtasks can nest under it.
+			id, err := importOneTask(client, rep.BoardID, t, taskIDs, memberIDs, owner, p.Source)
+			if err != nil {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("task %q: %v", t.Title, err))
+			}
+			if id != 0 {
+				taskIDs[t.Key] = id
+			}
+		}
+		if len(next) == len(pending) { // a parent cycle; create the rest flat
+			for i := range next {
+				next[i].Parent = ""
+			}
+		}
+		pending = next
+	}
+	return rep
+}
+
+func importOneTask(client *apiClient, boardID string, t importTask, taskIDs map[string]int, memberIDs map[string]string, owner, source string) (int, error) {
+	task := Task{BoardID: boardID, Title: t.Title, Description: t.Description, ListID: t.ListID, Position: t.Position,
+		Priority: t.Priority, DueDate: t.DueDate}
+	if id := memberIDs[t.Assignee]; id != "" {
+		task.AssigneeID = &id
+	}
+	if parent := taskIDs[t.Parent]; parent != 0 {
+		task.ParentID = &parent
+	}
+	var created Task
+	if err := client.do("POST", "/tasks", nil, task, &created); err != nil {
+		return 0, err
+	}
+	path := "/tasks/" + strconv.Itoa(created.ID)
+	for _, l := range t.Labels {
+		if err := client.do("POST", path+"/labels", nil, TaskLabelReq{Name: l}, nil); err != nil {
+			return created.ID, fmt.Errorf("label %q: %w", l, err)
+		}
+	}
+	for _, item := range t.Checklist {
+		text, done := item.Text, item.Done
+		if err := client.do("POST", path+"/checklist", nil, ChecklistItemReq{Text: &text, Done: &done}, nil); err != nil {
+			return created.ID, fmt.Errorf("checklist item %q: %w", item.Text, err)
+		}
+	}
+	// Comments are posted as the caller, so the note keeps the original author.
+	for _, c := range t.Comments {
+		note := "Imported from " + capitalize(source)
+		if c.Author != "" && memberIDs[c.Author] != owner {
+			note += ", originally by " + c.Author
+		}
+		if !c.At.IsZero() {
+			note += " on " + c.At.Format("2006-01-02 15:04")
+		}
+		if err := client.do("POST", path+"/comments", nil, map[string]string{"content": importFooter(c.Text, note)}, nil); err != nil {
+			return created.ID, fmt.Errorf("comment: %w", err)
+		}
+	}
+	return created.ID, nil
+}
+
+func hasImportTask(tasks []importTask, key string) bool {
+	for _, t := range tasks {
+		if t.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMember(members []BoardMemberReq, id string) bool {
+	for _, m := range members {
+		if m.MemberID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report for the terminal.
+func (r ImportReport) String() string {
+	var b strings.Builder
+	verb := "Created"
+	if r.DryRun {
+		verb = "Would create"
+	}
+	fmt.Fprintf(&b, "%s board %q from %s", verb, r.Title, r.Source)
+	if r.BoardID != "" {
+		fmt.Fprintf(&b, " (%s)", r.BoardID)
+	}
+	b.WriteString("\n")
+	for _, kind := range []string{"lists", "labels", "tasks", "checklist_items", "comments", "members"} {
+		fmt.Fprintf(&b, "  %-16s %d\n", strings.ReplaceAll(kind, "_", " "), r.Counts[kind])
+	}
+	if len(r.Lists) > 0 {
+		b.WriteString("Lists:\n")
+		for _, l := range r.Lists {
+			fmt.Fprintf(&b, "  %-20s %-24s %s\n", l.ID, l.Title, l.Category)
+		}
+	}
+	if len(r.Members) > 0 {
+		b.WriteString("Members:\n")
+		for _, m := range r.Members {
+			fmt.Fprintf(&b, "  %-24s -> %-16s %s\n", m.Source, firstNonEmpty(m.MemberID, "-"), m.Action)
+		}
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&b, "Skipped %d item(s):\n", len(r.Skipped))
+		for _, s := range r.Skipped {
+			b.WriteString("  " + s + "\n")
+		}
+	}
+	for _, e := range r.Errors {
+		b.WriteString("error: " + e + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	EventLabelChanged      = "label.changed"
+	EventTaskLabelAdded    = "task.label_added"
+	EventTaskLabelRemoved  = "task.label_removed"
+	defaultLabelColor      = "#9ca3af"
+	labelRoutingActivityBy = "system"
+)
+
+type Label struct {
+	ID      int    `json:"id"`
+	BoardID string `json:"board_id"`
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+}
+
+// LabelSpec is a label as it appears in templates and board specs.
+type LabelSpec struct {
+	Name  string `json:"name" yaml:"name"`
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+}
+
+// TaskLabelReq adds a label to a task by id or (case-insensitive) name.
+type TaskLabelReq struct {
+	LabelID   int    `json:"label_id"`
+	Name      string `json:"name"`
+	UpdatedBy string `json:"updated_by"`
+}
+
+// LabelRoute auto-assigns unassigned tasks that receive Label to AssigneeID.
+type LabelRoute struct {
+	Label      string `json:"label" yaml:"label"`
+	AssigneeID string `json:"assignee_id" yaml:"assignee_id"`
+}
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func normalizeLabel(l *LabelSpec) error {
+	l.Name = strings.TrimSpace(l.Name)
+	if l.Name == "" {
+		return invalidField("name", "is required")
+	}
+	if l.Color == "" {
+		l.Color = defaultLabelColor
+	}
+	if !labelColorPattern.MatchString(l.Color) {
+		return invalidField("color", "must be a hex color like #ff0000")
+	}
+	return nil
+}
+
+// normalizeLabels validates a set of label specs and rejects duplicate names.
+func normalizeLabels(labels []LabelSpec) error {
+	seen := map[string]bool{}
+	for i := range labels {
+		if err := normalizeLabel(&labels[i]); err != nil {
+			return fmt.Errorf("labels[%d]: %v", i, err)
+		}
+		key := strings.ToLower(labels[i].Name)
+		if seen[key] {
+			return fmt.Errorf("labels[%d]: %q is listed twice", i, labels[i].Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func loadLabels(q dbtx, boardID string) ([]Label, error) {
+	rows, err := q.Query(context.Background(), "SELECT id, board_id::text, name, color FROM labels WHERE board_id=$1 ORDER BY lower(name)", boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		if err := rows.Scan(&l.ID, &l.BoardID, &l.Name, &l.Color); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	if labels == nil {
+		labels = []Label{}
+	}
+	return labels, rows.Err()
+}
+
+func insertLabels(q dbtx, boardID string, labels []LabelSpec) error {
+	for _, l := range labels {
+		if _, err := q.Exec(context.Background(),
+			"INSERT INTO labels (board_id, name, color) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			boardID, l.Name, l.Color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTaskLabelByName attaches an existing board label to a task.
+func addTaskLabelByName(q dbtx, boardID string, taskID int, name string) error {
+	_, err := q.Exec(context.Background(), `
+		INSERT INTO task_labels (task_id, label_id)
+		SELECT $1, id FROM labels WHERE board_id=$2 AND lower(name)=lower($3)
+		ON CONFLICT DO NOTHING`, taskID, boardID, name)
+	return err
+}
+
+// attachLabels fills in Labels for each task with one query.
+func attachLabels(q dbtx, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int, len(tasks))
+	index := make(map[int]int, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		index[tasks[i].ID] = i
+		tasks[i].Labels = []Label{}
+	}
+	rows, err := q.Query(context.Background(), `
+		SELECT tl.task_id, l.id, l.board_id::text, l.name, l.color
+		FROM task_labels tl JOIN labels l ON l.id = tl.label_id
+		WHERE tl.task_id = ANY($1) ORDER BY lower(l.name)`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID int
+		var l Label
+		if err := rows.Scan(&taskID, &l.ID, &l.BoardID, &l.Name, &l.Color); err != nil {
+			return err
+		}
+		t := &tasks[index[taskID]]
+		t.Labels = append(t.Labels, l)
+	}
+	return rows.Err()
+}
+
+func getBoardLabels(c *fiber.Ctx) error {
+	labels, err := loadLabels(db, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(labels)
+}
+
+func createLabel(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	req := new(LabelSpec)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	if err := normalizeLabel(req); err != nil {
+		return badRequest(err)
+	}
+	l := Label{BoardID: boardID, Name: req.Name, Color: req.Color}
+	err = db.QueryRow(context.Background(),
+		"INSERT INTO labels (board_id, name, color) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING RETURNING id",
+		boardID, l.Name, l.Color).Scan(&l.ID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(409, "Label already exists")
+	}
+	if err != nil {
+		return err
+	}
+	emitEvent(boardID, EventLabelChanged, nil, caller, l)
+	return c.Status(201).JSON(l)
+}
+
+func updateLabel(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	req := new(LabelSpec)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	var l Label
+	err = db.QueryRow(context.Background(), "SELECT id, board_id::text, name, color FROM labels WHERE id=$1", id).Scan(&l.ID, &l.BoardID, &l.Name, &l.Color)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Label not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, l.BoardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	if req.Name != "" {
+		l.Name = req.Name
+	}
+	if req.Color != "" {
+		l.Color = req.Color
+	}
+	spec := LabelSpec{Name: l.Name, Color: l.Color}
+	if err := normalizeLabel(&spec); err != nil {
+		return badRequest(err)
+	}
+	l.Name, l.Color = spec.Name, spec.Color
+	if _, err := db.Exec(context.Background(), "UPDATE labels SET name=$1, color=$2 WHERE id=$3", l.Name, l.Color, id); err != nil {
+		return err
+	}
+	emitEvent(l.BoardID, EventLabelChanged, nil, caller, l)
+	return c.JSON(l)
+}
+
+func deleteLabel(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	var boardID string
+	err = db.QueryRow(context.Background(), "SELECT board_id::text FROM labels WHERE id=$1", id).Scan(&boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Label not found")
+	}
+	if err != nil {
+		return err
+	}
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(context.Background(), "DELETE FROM labels WHERE id=$1", id); err != nil {
+		return err
+	}
+	emitEvent(boardID, EventLabelChanged, nil, caller, fiber.Map{"id": id, "deleted": true})
+	return c.SendStatus(200)
+}
+
+func addTaskLabel(c *fiber.Ctx) error {
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	t, err := loadTask(taskID)
+	if err != nil {
+		return fiber.NewError(404, "Task not found")
+	}
+	req := new(TaskLabelReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	userID, err := actorOr(c, req.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	var l Label
+	err = db.QueryRow(context.Background(),
+		"SELECT id, board_id::text, name, color FROM labels WHERE board_id=$1 AND (id=$2 OR lower(name)=lower($3))",
+		t.BoardID, req.LabelID, req.Name).Scan(&l.ID, &l.BoardID, &l.Name, &l.Color)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Label not found on this board")
+	}
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(context.Background(), "INSERT INTO task_labels (task_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", taskID, l.ID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() > 0 {
+		go logActivity(taskID, userID, "labeled", fmt.Sprintf("Added label %s", l.Name))
+		emitEvent(t.BoardID, EventTaskLabelAdded, &taskID, userID, l)
+		routeByLabel(t, l.Name)
+	}
+	return c.JSON(l)
+}
+
+func removeTaskLabel(c *fiber.Ctx) error {
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	labelID, err := paramInt(c, "lid")
+	if err != nil {
+		return err
+	}
+	userID, err := actorOr(c, c.Query("updated_by"))
+	if err != nil {
+		return err
+	}
+	var name, boardID string
+	err = db.QueryRow(context.Background(), `
+		DELETE FROM task_labels tl USING labels l
+		WHERE tl.label_id = l.id AND tl.task_id=$1 AND tl.label_id=$2
+		RETURNING l.name, l.board_id::text`, taskID, labelID).Scan(&name, &boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Task does not have this label")
+	}
+	if err != nil {
+		return err
+	}
+	go logActivity(taskID, userID, "unlabeled", fmt.Sprintf("Removed label %s", name))
+	emitEvent(boardID, EventTaskLabelRemoved, &taskID, userID, fiber.Map{"id": labelID, "name": name})
+	return c.SendStatus(200)
+}
+
+// routeByLabel applies the board's label routing rules: an unassigned task
+// that receives a routed label is assigned to the configured member.
+func routeByLabel(t Task, label string) {
+	if t.AssigneeID != nil && *t.AssigneeID != "" {
+		return
+	}
+	b, err := loadBoard(t.BoardID)
+	if err != nil {
+		return
+	}
+	for _, r := range b.Settings.LabelRouting {
+		if !strings.EqualFold(r.Label, label) {
+			continue
+		}
+		result, err := db.Exec(context.Background(), `
+			UPDATE tasks SET assignee_id=$1
+			WHERE id=$2 AND assignee_id IS NULL AND EXISTS (SELECT 1 FROM members WHERE id=$1 AND active)`,
+			r.AssigneeID, t.ID)
+		if err != nil || result.RowsAffected() == 0 {
+			return
+		}
+		assignee := r.AssigneeID
+		t.AssigneeID = &assignee
+		go logActivity(t.ID, labelRoutingActivityBy, "assigned", fmt.Sprintf("Assigned to %s (label %s)", assignee, label))
+		id := t.ID
+		emitEvent(t.BoardID, EventTaskUpdated, &id, labelRoutingActivityBy, t)
+		return
+	}
+}
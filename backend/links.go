@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	EventTaskLinked    = "task.linked"
+	EventTaskUnlinked  = "task.unlinked"
+	EventTaskUnblocked = "task.unblocked"
+)
+
+// Link types. A blocked_by request is stored as the inverse blocks link.
+const (
+	LinkBlocks     = "blocks"
+	LinkBlockedBy  = "blocked_by"
+	LinkRelatesTo  = "relates_to"
+	LinkDuplicates = "duplicates"
+)
+
+// TaskLink is a directed relationship: TaskID <type> TargetID, e.g. task 3
+// blocks task 7.
+type TaskLink struct {
+	ID        int    `json:"id"`
+	TaskID    int    `json:"task_id"`
+	TargetID  int    `json:"target_id"`
+	Type      string `json:"type"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+type TaskLinkReq struct {
+	Type      string `json:"type"`
+	TargetID  int    `json:"target_id"`
+	UpdatedBy string `json:"updated_by"`
+}
+
+// DependencyNode and DependencyEdge make up a board's dependency graph.
+type DependencyNode struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	ListID    string `json:"list_id"`
+	Completed bool   `json:"completed"`
+	Blocked   bool   `json:"blocked"`
+}
+
+type DependencyEdge struct {
+	ID   int    `json:"id"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Type string `json:"type"`
+}
+
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// openBlockersSQL is true for tasks aliased t that have an unfinished blocker.
+const openBlockersSQL = `EXISTS (SELECT 1 FROM task_links tk JOIN tasks b ON b.id = tk.task_id
+	WHERE tk.target_id = t.id AND tk.type = 'blocks' AND b.completed_at IS NULL)`
+
+func scanLinks(rows pgx.Rows) ([]TaskLink, error) {
+	defer rows.Close()
+	links := []TaskLink{}
+	for rows.Next() {
+		var l TaskLink
+		if err := rows.Scan(&l.ID, &l.TaskID, &l.TargetID, &l.Type, &l.CreatedBy); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// attachBlockers fills in BlockedBy with the IDs of unfinished blockers.
+func attachBlockers(q dbtx, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int, len(tasks))
+	index := make(map[int]int, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		index[tasks[i].ID] = i
+	}
+	rows, err := q.Query(context.Background(), `
+		SELECT tk.target_id, tk.task_id FROM task_links tk JOIN tasks b ON b.id = tk.task_id
+		WHERE tk.target_id = ANY($1) AND tk.type = 'blocks' AND b.completed_at IS NULL
+		ORDER BY tk.task_id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID, blockerID int
+		if err := rows.Scan(&taskID, &blockerID); err != nil {
+			return err
+		}
+		t := &tasks[index[taskID]]
+		t.BlockedBy = append(t.BlockedBy, blockerID)
+	}
+	return rows.Err()
+}
+
+// wouldCycle reports whether adding "from blocks to" closes a loop, i.e.
+// whether to already (transitively) blocks from.
+func wouldCycle(from, to int) (bool, error) {
+	var cycle bool
+	err := db.QueryRow(context.Background(), `
+		WITH RECURSIVE downstream(id) AS (
+			SELECT target_id FROM task_links WHERE task_id=$1 AND type='blocks'
+			UNION
+			SELECT tk.target_id FROM task_links tk JOIN downstream d ON tk.task_id = d.id WHERE tk.type='blocks'
+		)
+		SELECT EXISTS (SELECT 1 FROM downstream WHERE id=$2)`, to, from).Scan(&cycle)
+	return cycle, err
+}
+
+func getTaskLinks(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(context.Background(),
+		"SELECT id, task_id, target_id, type, COALESCE(created_by, '') FROM task_links WHERE task_id=$1 OR target_id=$1 ORDER BY id", id)
+	if err != nil {
+		return err
+	}
+	links, err := scanLinks(rows)
+	if err != nil {
+		return err
+	}
+	return c.JSON(links)
+}
+
+func createTaskLink(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	req := new(TaskLinkReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	userID, err := actorOr(c, req.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	from, to, linkType := id, req.TargetID, req.Type
+	switch linkType {
+	case LinkBlocks, LinkRelatesTo, LinkDuplicates:
+	case LinkBlockedBy:
+		from, to, linkType = to, from, LinkBlocks
+	default:
+		return invalidField("type", "must be blocks, blocked_by, relates_to or duplicates")
+	}
+	if from == to {
+		return invalidField("target_id", "cannot be the task itself")
+	}
+	fromTask, err := loadTask(from)
+	if err != nil {
+		return fiber.NewError(404, fmt.Sprintf("Task %d not found", from))
+	}
+	toTask, err := loadTask(to)
+	if err != nil {
+		return fiber.NewError(404, fmt.Sprintf("Task %d not found", to))
+	}
+	if linkType == LinkBlocks {
+		cycle, err := wouldCycle(from, to)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fiber.NewError(409, fmt.Sprintf("Task %d already depends on task %d; this link would create a cycle", from, to))
+		}
+	}
+
+	l := TaskLink{TaskID: from, TargetID: to, Type: linkType, CreatedBy: userID}
+	err = db.QueryRow(context.Background(), `
+		INSERT INTO task_links (task_id, target_id, type, created_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING RETURNING id`, from, to, linkType, userID).Scan(&l.ID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(409, "Link already exists")
+	}
+	if err != nil {
+		return err
+	}
+	go logActivity(from, userID, "linked", fmt.Sprintf("%s #%d %s", linkType, to, toTask.Title))
+	go logActivity(to, userID, "linked", fmt.Sprintf("%s by #%d %s", linkType, from, fromTask.Title))
+	emitEvent(fromTask.BoardID, EventTaskLinked, &from, userID, l)
+	if toTask.BoardID != fromTask.BoardID {
+		emitEvent(toTask.BoardID, EventTaskLinked, &to, userID, l)
+	}
+	return c.Status(201).JSON(l)
+}
+
+func deleteTaskLink(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	userID, err := actorOr(c, c.Query("updated_by"))
+	if err != nil {
+		return err
+	}
+	var l TaskLink
+	err = db.QueryRow(context.Background(),
+		"DELETE FROM task_links WHERE id=$1 RETURNING id, task_id, target_id, type, COALESCE(created_by, '')", id).
+		Scan(&l.ID, &l.TaskID, &l.TargetID, &l.Type, &l.CreatedBy)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Link not found")
+	}
+	if err != nil {
+		return err
+	}
+	go logActivity(l.TaskID, userID, "unlinked", fmt.Sprintf("Removed %s link to #%d", l.Type, l.TargetID))
+	go logActivity(l.TargetID, userID, "unlinked", fmt.Sprintf("Removed %s link from #%d", l.Type, l.TaskID))
+	if boardID, err := taskBoardID(l.TaskID); err == nil {
+		emitEvent(boardID, EventTaskUnlinked, &l.TaskID, userID, l)
+	}
+	if l.Type == LinkBlocks {
+		notifyUnblocked([]int{l.TargetID}, l.TaskID, userID)
+	}
+	return c.SendStatus(200)
+}
+
+// notifyUnblocked emits task.unblocked for each open task in candidates that
+// no longer has unfinished blockers. cause is the task whose completion (or
+// unlinking) triggered the check.
+func notifyUnblocked(candidates []int, cause int, actor string) {
+	if len(candidates) == 0 {
+		return
+	}
+	rows, err := db.Query(context.Background(),
+		"SELECT "+taskColumns+" FROM tasks t WHERE t.id = ANY($1) AND t.completed_at IS NULL AND NOT "+openBlockersSQL, candidates)
+	if err != nil {
+		return
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return
+	}
+	for _, t := range tasks {
+		id := t.ID
+		go logActivity(id, actor, "unblocked", fmt.Sprintf("Unblocked by #%d", cause))
+		emitEvent(t.BoardID, EventTaskUnblocked, &id, actor, fiber.Map{"task": t, "unblocked_by": cause})
+	}
+}
+
+// dependentsOf returns the tasks that taskID blocks.
+func dependentsOf(taskID int) []int {
+	rows, err := db.Query(context.Background(), "SELECT target_id FROM task_links WHERE task_id=$1 AND type='blocks'", taskID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// getDependencyGraph returns a board's tasks and the links between them.
+// Links to tasks on other boards are included as edges only.
+func getDependencyGraph(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	ctx := context.Background()
+	rows, err := db.Query(ctx, `
+		SELECT t.id, t.title, t.list_id, t.completed_at IS NOT NULL, `+openBlockersSQL+`
+		FROM tasks t WHERE t.board_id=$1 ORDER BY t.id`, boardID)
+	if err != nil {
+		return err
+	}
+	graph := DependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}
+	for rows.Next() {
+		var n DependencyNode
+		if err := rows.Scan(&n.ID, &n.Title, &n.ListID, &n.Completed, &n.Blocked); err != nil {
+			rows.Close()
+			return err
+		}
+		graph.Nodes = append(graph.Nodes, n)
+	}
+	rows.Close()
+
+	rows, err = db.Query(ctx, `
+		SELECT tk.id, tk.task_id, tk.target_id, tk.type FROM task_links tk
+		WHERE tk.task_id IN (SELECT id FROM tasks WHERE board_id=$1)
+			OR tk.target_id IN (SELECT id FROM tasks WHERE board_id=$1)
+		ORDER BY tk.id`, boardID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e DependencyEdge
+		if err := rows.Scan(&e.ID, &e.From, &e.To, &e.Type); err != nil {
+			return err
+		}
+		graph.Edges = append(graph.Edges, e)
+	}
+	return c.JSON(graph)
+}
@@ -8,13 +8,14 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"github.com/sashabaranov/go-openai"
@@ -24,34 +25,8 @@ var (
 	db           *pgxpool.Pool
 	rdb          *redis.Client
 	openaiClient *openai.Client
-	clients      = make(map[*websocket.Conn]bool)
-	clientsMu    sync.Mutex
 )
 
-type Board struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-}
-
-type Task struct {
-	ID          int     `json:"id"`
-	BoardID     string  `json:"board_id"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	ListID      string  `json:"list_id"`
-	Position    int     `json:"position"`
-	AssigneeID  *string `json:"assignee_id"`
-	UpdatedBy   string  `json:"updated_by,omitempty"`
-}
-
-type Member struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Role   string `json:"role"`
-	Avatar string `json:"avatar"`
-}
-
 type Activity struct {
 	ID        int       `json:"id"`
 	TaskID    int       `json:"task_id"`
@@ -62,8 +37,8 @@ type Activity struct {
 }
 
 type BoardMemberReq struct {
-	MemberID string `json:"member_id"`
-	Role     string `json:"role"`
+	MemberID string `json:"member_id" yaml:"member_id"`
+	Role     string `json:"role" yaml:"role"`
 }
 
 type Document struct {
@@ -83,24 +58,22 @@ type Comment struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+func (cm *Comment) Validate() error {
+	if cm.UserID == "" {
+		return invalidField("user_id", "is required")
+	}
+	if strings.TrimSpace(cm.Content) == "" {
+		return invalidField("content", "is required")
+	}
+	return nil
+}
+
 type GeminiEmbeddingResponse struct {
 	Embedding struct {
 		Values []float32 `json:"values"`
 	} `json:"embedding"`
 }
 
-func broadcastUpdate(msg string) {
-	clientsMu.Lock()
-	defer clientsMu.Unlock()
-	for client := range clients {
-		if err := client.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
-			log.Println("WS write error:", err)
-			client.Close()
-			delete(clients, client)
-		}
-	}
-}
-
 func initDB() {
 	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
 		os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"),
@@ -195,61 +168,195 @@ func initDB() {
 		CONSTRAINT fk_comment_task FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
 	);`)
 
-	seedMembers()
-	seedBoardMembers()
+	db.Exec(context.Background(), "ALTER TABLE boards ADD COLUMN IF NOT EXISTS event_seq BIGINT NOT NULL DEFAULT 0")
 
-	fmt.Println("✅ Database migrated!")
-}
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS board_events (
+		board_id UUID NOT NULL,
+		seq BIGINT NOT NULL,
+		type TEXT NOT NULL,
+		task_id INT,
+		actor_id TEXT,
+		payload JSONB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (board_id, seq),
+		CONSTRAINT fk_ev_board FOREIGN KEY(board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);`)
 
-func seedMembers() {
-	members := []Member{
-		{ID: "mirza", Name: "Mirza", Role: "human", Avatar: "👤"},
-		{ID: "devo", Name: "Devo", Role: "agent", Avatar: "🛡️"},
-		{ID: "kodinger", Name: "Kodinger", Role: "agent", Avatar: "👨‍💻"},
-		{ID: "mimin", Name: "Mimin", Role: "agent", Avatar: "📢"},
-		{ID: "antigravity", Name: "Antigravity", Role: "agent", Avatar: "🌌"},
-	}
-	for _, m := range members {
-		db.Exec(context.Background(),
-			"INSERT INTO members (id, name, role, avatar) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET name=$2, role=$3, avatar=$4",
-			m.ID, m.Name, m.Role, m.Avatar)
-	}
-}
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS kind TEXT")
+	db.Exec(context.Background(), "UPDATE members SET kind = role WHERE kind IS NULL")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS email TEXT")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS title TEXT")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS bio TEXT")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP")
+	db.Exec(context.Background(), "ALTER TABLE members ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP")
 
-func seedBoardMembers() {
-	rows, err := db.Query(context.Background(), "SELECT id FROM boards")
-	if err != nil {
-		fmt.Println("seedBoardMembers: failed to query boards:", err)
-		return
-	}
-	defer rows.Close()
-	var boardIDs []string
-	for rows.Next() {
-		var id string
-		rows.Scan(&id)
-		boardIDs = append(boardIDs, id)
-	}
+	db.Exec(context.Background(), "ALTER TABLE boards ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}'")
+	db.Exec(context.Background(), "ALTER TABLE boards ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP")
 
-	mRows, err := db.Query(context.Background(), "SELECT id FROM members")
-	if err != nil {
-		fmt.Println("seedBoardMembers: failed to query members:", err)
-		return
-	}
-	defer mRows.Close()
-	var memberIDs []string
-	for mRows.Next() {
-		var id string
-		mRows.Scan(&id)
-		memberIDs = append(memberIDs, id)
-	}
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS lists (
+		board_id UUID NOT NULL,
+		id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		position INT DEFAULT 0,
+		category TEXT NOT NULL DEFAULT 'todo',
+		PRIMARY KEY (board_id, id),
+		CONSTRAINT fk_list_board FOREIGN KEY(board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);`)
 
-	for _, bid := range boardIDs {
-		for _, mid := range memberIDs {
-			db.Exec(context.Background(),
-				"INSERT INTO board_members (board_id, member_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-				bid, mid)
-		}
-	}
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS board_templates (
+		name TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		description TEXT,
+		spec JSONB NOT NULL,
+		created_by TEXT REFERENCES members(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`)
+
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'medium'")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS start_date TIMESTAMPTZ")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_date TIMESTAMPTZ")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS estimate DOUBLE PRECISION")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS estimate_unit TEXT")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ")
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS overdue_notified_at TIMESTAMPTZ")
+	db.Exec(context.Background(), "CREATE INDEX IF NOT EXISTS idx_tasks_due_open ON tasks (due_date) WHERE completed_at IS NULL")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS labels (
+		id SERIAL PRIMARY KEY,
+		board_id UUID NOT NULL,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '#9ca3af',
+		CONSTRAINT fk_label_board FOREIGN KEY(board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);`)
+	db.Exec(context.Background(), "CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_board_name ON labels (board_id, lower(name))")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS task_labels (
+		task_id INT NOT NULL,
+		label_id INT NOT NULL,
+		PRIMARY KEY (task_id, label_id),
+		CONSTRAINT fk_tl_task FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		CONSTRAINT fk_tl_label FOREIGN KEY(label_id) REFERENCES labels(id) ON DELETE CASCADE
+	);`)
+
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES tasks(id) ON DELETE SET NULL")
+	db.Exec(context.Background(), "CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks (parent_id) WHERE parent_id IS NOT NULL")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS checklist_items (
+		id SERIAL PRIMARY KEY,
+		task_id INT NOT NULL,
+		position INT NOT NULL DEFAULT 0,
+		text TEXT NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		assignee_id TEXT,
+		completed_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_checklist_task FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		CONSTRAINT fk_checklist_assignee FOREIGN KEY(assignee_id) REFERENCES members(id)
+	);`)
+	// Older tables lack the assignee key; unknown assignees are cleared first.
+	// Adding the constraint fails harmlessly once it exists.
+	db.Exec(context.Background(), "UPDATE checklist_items SET assignee_id=NULL WHERE assignee_id NOT IN (SELECT id FROM members)")
+	db.Exec(context.Background(), "ALTER TABLE checklist_items ADD CONSTRAINT fk_checklist_assignee FOREIGN KEY(assignee_id) REFERENCES members(id)")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS task_links (
+		id SERIAL PRIMARY KEY,
+		task_id INT NOT NULL,
+		target_id INT NOT NULL,
+		type TEXT NOT NULL,
+		created_by TEXT,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (task_id, target_id, type),
+		CONSTRAINT fk_link_task FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		CONSTRAINT fk_link_target FOREIGN KEY(target_id) REFERENCES tasks(id) ON DELETE CASCADE
+	);`)
+	db.Exec(context.Background(), "CREATE INDEX IF NOT EXISTS idx_task_links_target ON task_links (target_id)")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS custom_fields (
+		id SERIAL PRIMARY KEY,
+		board_id UUID NOT NULL,
+		key TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		options TEXT[],
+		required BOOLEAN NOT NULL DEFAULT FALSE,
+		position INT NOT NULL DEFAULT 0,
+		display JSONB NOT NULL DEFAULT '{}',
+		UNIQUE (board_id, key),
+		CONSTRAINT fk_field_board FOREIGN KEY(board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);`)
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}'")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS saved_views (
+		id SERIAL PRIMARY KEY,
+		member_id TEXT NOT NULL,
+		board_id UUID,
+		name TEXT NOT NULL,
+		query TEXT NOT NULL DEFAULT '',
+		sort TEXT NOT NULL DEFAULT '',
+		shared BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_view_member FOREIGN KEY(member_id) REFERENCES members(id) ON DELETE CASCADE,
+		CONSTRAINT fk_view_board FOREIGN KEY(board_id) REFERENCES boards(id) ON DELETE CASCADE
+	);`)
+	db.Exec(context.Background(), "CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_views_name ON saved_views (member_id, COALESCE(board_id::text, ''), lower(name))")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		subject TEXT NOT NULL,
+		key TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		status INT,
+		content_type TEXT,
+		body BYTEA,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (subject, key)
+	);`)
+	db.Exec(context.Background(), "CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys (created_at)")
+
+	db.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS member_tokens (
+		id SERIAL PRIMARY KEY,
+		member_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMPTZ,
+		revoked_at TIMESTAMPTZ,
+		CONSTRAINT fk_token_member FOREIGN KEY(member_id) REFERENCES members(id) ON DELETE CASCADE
+	);`)
+
+	// Tasks created before created_at existed take the time of their first activity.
+	db.Exec(context.Background(), "ALTER TABLE tasks ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ")
+	db.Exec(context.Background(), "ALTER TABLE tasks ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP")
+	db.Exec(context.Background(), `
+	UPDATE tasks t SET created_at = (SELECT MIN(a.created_at) FROM activities a WHERE a.task_id = t.id)
+	WHERE t.created_at IS NULL AND EXISTS (SELECT 1 FROM activities a WHERE a.task_id = t.id)`)
+
+	seedMembers(defaultBoardID)
+	seedBoardLists()
+	db.Exec(context.Background(), `
+		UPDATE tasks t SET completed_at = CURRENT_TIMESTAMP FROM lists l
+		WHERE l.board_id = t.board_id AND l.id = t.list_id AND l.category = 'done' AND t.completed_at IS NULL`)
+	seedTemplates()
+
+	log.Println("✅ Database migrated!")
+}
+
+// initRedis creates the cache client. CLI commands that write through the
+// database call it too, so the server's cached reads are invalidated.
+func initRedis() {
+	rdb = redis.NewClient(&redis.Options{Addr: os.Getenv("REDIS_ADDR"), Password: os.Getenv("REDIS_PASSWORD"), DB: 0})
 }
 
 func initAI() {
@@ -308,15 +415,39 @@ func generateEmbedding(text string) ([]float32, error) {
 }
 
 func main() {
+	if runCLI(os.Args[1:]) {
+		return
+	}
 	initDB()
 	initAI()
+	// Background workers invalidate the cache, so Redis comes first.
+	initRedis()
 
-	rdb = redis.NewClient(&redis.Options{Addr: os.Getenv("REDIS_ADDR"), Password: os.Getenv("REDIS_PASSWORD"), DB: 0})
+	startOverdueWatcher()
+	startIdempotencyJanitor()
 
-	app := fiber.New()
-	app.Use(cors.New(cors.Config{AllowOrigins: "*", AllowHeaders: "Origin, Content-Type, Accept"}))
+	app := newApp()
+	log.Fatal(app.Listen(":8080"))
+}
+
+// newApp builds the HTTP server: middleware, the websocket endpoint and the
+// API routes.
+func newApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	app.Use(requestid.New())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:  "*",
+		AllowHeaders:  "Origin, Content-Type, Accept, " + fiber.HeaderAuthorization + ", " + cacheBypassHeader + ", " + idempotencyHeader,
+		ExposeHeaders: "RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Cache, Idempotent-Replayed, X-Request-ID",
+	}))
+	app.Use(tokenAuth)
+	app.Use(rateLimit)
+	app.Use(idempotency)
 
 	app.Use("/ws", func(c *fiber.Ctx) error {
+		if _, err := requireCaller(c); err != nil {
+			return err
+		}
 		if websocket.IsWebSocketUpgrade(c) {
 			c.Locals("allowed", true)
 			return c.Next()
@@ -324,331 +455,188 @@ func main() {
 		return fiber.ErrUpgradeRequired
 	})
 
-	app.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		clientsMu.Lock()
-		clients[c] = true
-		clientsMu.Unlock()
-		defer func() { clientsMu.Lock(); delete(clients, c); clientsMu.Unlock(); c.Close() }()
-		for {
-			if _, _, err := c.ReadMessage(); err != nil {
-				break
-			}
-		}
-	}))
-
-	app.Get("/api/health", func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"status": "ok"}) })
-
-	app.Get("/api/boards", getBoards)
-	app.Post("/api/boards", createBoard)
-	app.Get("/api/boards/:id/tasks", getBoardTasks)
-	app.Get("/api/boards/:id/members", getBoardMembers)
-	app.Post("/api/boards/:id/members", addBoardMember)
-	app.Delete("/api/boards/:id/members/:mid", removeBoardMember)
-
-	app.Post("/api/tasks", createTask)
-	app.Put("/api/tasks/:id", updateTask)
-	app.Get("/api/tasks/:id/activities", getTaskActivities)
-	app.Get("/api/search", searchTasks)
-	app.Get("/api/members", getMembers)
-
-	// Knowledge Base / Documents
-	app.Get("/api/boards/:id/docs", getBoardDocs)
-	app.Post("/api/boards/:id/docs", createDoc)
-	app.Put("/api/docs/:id", updateDoc)
-	app.Delete("/api/docs/:id", deleteDoc)
-	app.Get("/api/docs/search", searchDocs)
+	// Clients may pass ?board_id=&since= or send {"type":"subscribe","board_id":"...","since":N}
+	// to receive the missed events before live ones. Only members of the
+	// board may subscribe; the connection is closed otherwise.
+	app.Get("/ws", websocket.New(serveWS))
 
-	// Comments
-	app.Get("/api/tasks/:id/comments", getTaskComments)
-	app.Post("/api/tasks/:id/comments", createComment)
+	// Model Context Protocol (streamable HTTP) for agents; needs an API token.
+	app.All(mcpPath, handleMCP)
 
-	log.Fatal(app.Listen(":8080"))
-}
-
-func getBoards(c *fiber.Ctx) error {
-	rows, err := db.Query(context.Background(), "SELECT id::text, title, description FROM boards ORDER BY created_at ASC")
-	if err != nil {
-		return c.Status(500).SendString(err.Error())
-	}
-	defer rows.Close()
-	var boards []Board
-	for rows.Next() {
-		var b Board
-		if err := rows.Scan(&b.ID, &b.Title, &b.Description); err != nil {
-			return c.Status(500).SendString(err.Error())
-		}
-		boards = append(boards, b)
-	}
-	if boards == nil {
-		boards = []Board{}
-	}
-	return c.JSON(boards)
-}
-
-func createBoard(c *fiber.Ctx) error {
-	b := new(Board)
-	if err := c.BodyParser(b); err != nil {
-		return c.Status(400).SendString(err.Error())
-	}
-	err := db.QueryRow(context.Background(), "INSERT INTO boards (title, description) VALUES ($1, $2) RETURNING id::text", b.Title, b.Description).Scan(&b.ID)
-	if err != nil {
-		return c.Status(500).SendString(err.Error())
-	}
-	db.Exec(context.Background(), "INSERT INTO board_members (board_id, member_id, role) VALUES ($1, 'mirza', 'owner')", b.ID)
-	return c.JSON(b)
+	registerRoutes(app)
+	return app
 }
 
-func getBoardTasks(c *fiber.Ctx) error {
+// addBoardMember adds a member to a board or changes their role. Only owners
+// may manage membership, and the last owner cannot be demoted.
+func addBoardMember(c *fiber.Ctx) error {
 	boardID := c.Params("id")
-	rows, err := db.Query(context.Background(), "SELECT id, board_id::text, title, description, list_id, position, assignee_id FROM tasks WHERE board_id=$1 ORDER BY position ASC", boardID)
+	caller, err := requireBoardRole(c, boardID, RoleOwner)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
-	}
-	defer rows.Close()
-	var tasks []Task
-	for rows.Next() {
-		var t Task
-		if err := rows.Scan(&t.ID, &t.BoardID, &t.Title, &t.Description, &t.ListID, &t.Position, &t.AssigneeID); err != nil {
-			return c.Status(500).SendString(err.Error())
-		}
-		tasks = append(tasks, t)
-	}
-	if tasks == nil {
-		tasks = []Task{}
+		return err
 	}
-	return c.JSON(tasks)
-}
-
-func getMembers(c *fiber.Ctx) error {
-	rows, err := db.Query(context.Background(), "SELECT id, name, role, avatar FROM members")
-	if err != nil {
-		return c.Status(500).SendString(err.Error())
+	req := new(BoardMemberReq)
+	if err := parseBody(c, req); err != nil {
+		return err
 	}
-	defer rows.Close()
-	var members []Member
-	for rows.Next() {
-		var m Member
-		if err := rows.Scan(&m.ID, &m.Name, &m.Role, &m.Avatar); err != nil {
-			return c.Status(500).SendString(err.Error())
-		}
-		members = append(members, m)
+	if req.Role == "" {
+		req.Role = "editor"
 	}
-	if members == nil {
-		members = []Member{}
+	if !validRole(req.Role) {
+		return invalidField("role", "must be owner, editor or viewer")
 	}
-	return c.JSON(members)
-}
 
-func getBoardMembers(c *fiber.Ctx) error {
-	boardID := c.Params("id")
-	query := `
-		SELECT m.id, m.name, m.role, m.avatar 
-		FROM members m 
-		JOIN board_members bm ON m.id = bm.member_id 
-		WHERE bm.board_id = $1
-	`
-	rows, err := db.Query(context.Background(), query, boardID)
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
-	defer rows.Close()
-	var members []Member
-	for rows.Next() {
-		var m Member
-		if err := rows.Scan(&m.ID, &m.Name, &m.Role, &m.Avatar); err != nil {
-			return c.Status(500).SendString(err.Error())
+	defer tx.Rollback(ctx)
+	if req.Role != RoleOwner {
+		if err := keepAnOwner(tx, boardID, req.MemberID); err != nil {
+			return err
 		}
-		members = append(members, m)
-	}
-	if members == nil {
-		members = []Member{}
-	}
-	return c.JSON(members)
-}
-
-func addBoardMember(c *fiber.Ctx) error {
-	boardID := c.Params("id")
-	req := new(BoardMemberReq)
-	if err := c.BodyParser(req); err != nil {
-		return c.Status(400).SendString(err.Error())
 	}
-	if req.Role == "" {
-		req.Role = "editor"
+	// Upsert so repeating the call (or changing the role) is idempotent.
+	if _, err := tx.Exec(ctx,
+		"INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3) ON CONFLICT (board_id, member_id) DO UPDATE SET role=EXCLUDED.role",
+		boardID, req.MemberID, req.Role); err != nil {
+		return err
 	}
-	_, err := db.Exec(context.Background(),
-		"INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
-		boardID, req.MemberID, req.Role)
-	if err != nil {
-		return c.Status(500).SendString(err.Error())
+	if err := tx.Commit(ctx); err != nil {
+		return err
 	}
+	emitEvent(boardID, EventBoardMemberAdded, nil, caller, req)
 	return c.SendStatus(200)
 }
 
+// removeBoardMember takes a member off a board. Owners may remove anyone;
+// other members may only leave. The last owner cannot be removed.
 func removeBoardMember(c *fiber.Ctx) error {
 	boardID := c.Params("id")
 	memberID := c.Params("mid")
-	_, err := db.Exec(context.Background(),
-		"DELETE FROM board_members WHERE board_id=$1 AND member_id=$2",
-		boardID, memberID)
+	caller, err := requireCaller(c)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
-	return c.SendStatus(200)
-}
-
-func createTask(c *fiber.Ctx) error {
-	t := new(Task)
-	if err := c.BodyParser(t); err != nil {
-		return c.Status(400).SendString(err.Error())
-	}
-	if t.BoardID == "" {
-		var defaultID string
-		db.QueryRow(context.Background(), "SELECT id::text FROM boards LIMIT 1").Scan(&defaultID)
-		if defaultID == "" {
-			return c.Status(500).SendString("No board found")
+	if caller != memberID {
+		if _, err := requireBoardRole(c, boardID, RoleOwner); err != nil {
+			return err
 		}
-		t.BoardID = defaultID
-	}
-	if t.Title == "" {
-		return c.Status(400).SendString("Title is required")
-	}
-	if t.BoardID == "" {
-		return c.Status(400).SendString("Board ID is required")
-	}
-	if t.ListID == "" {
-		t.ListID = "todo"
-	}
-
-	var id int
-	err := db.QueryRow(context.Background(),
-		"INSERT INTO tasks (board_id, title, description, list_id, position, assignee_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		t.BoardID, t.Title, t.Description, t.ListID, t.Position, t.AssigneeID).Scan(&id)
-	if err != nil {
-		return c.Status(500).SendString(err.Error())
 	}
-	t.ID = id
-	go updateEmbedding(id, t.Title+" "+t.Description)
-	go broadcastUpdate("UPDATE")
-	return c.JSON(t)
-}
-
-func updateTask(c *fiber.Ctx) error {
-	id, _ := strconv.Atoi(c.Params("id"))
 
-	var oldTask Task
-	err := db.QueryRow(context.Background(),
-		"SELECT id, board_id::text, title, description, list_id, position, assignee_id FROM tasks WHERE id=$1",
-		id).Scan(&oldTask.ID, &oldTask.BoardID, &oldTask.Title, &oldTask.Description, &oldTask.ListID, &oldTask.Position, &oldTask.AssigneeID)
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		return c.Status(404).SendString("Task not found")
+		return err
 	}
-
-	newTask := new(Task)
-	if err := c.BodyParser(newTask); err != nil {
-		return c.Status(400).SendString(err.Error())
+	defer tx.Rollback(ctx)
+	if err := keepAnOwner(tx, boardID, memberID); err != nil {
+		return err
 	}
-
-	// Preserve existing values if fields are empty/missing
-	if newTask.BoardID == "" {
-		newTask.BoardID = oldTask.BoardID
-	}
-	if newTask.Title == "" {
-		newTask.Title = oldTask.Title
-	}
-	if newTask.Description == "" {
-		newTask.Description = oldTask.Description
-	}
-	if newTask.ListID == "" {
-		newTask.ListID = oldTask.ListID
-	}
-	if newTask.AssigneeID == nil {
-		newTask.AssigneeID = oldTask.AssigneeID
-	}
-	// We keep position as is if it's 0 (might be intentional move to top),
-	// but generally frontend sends it. Let's assume if it's 0 and not explicitly set, keep old?
-	// No, 0 is valid. Let's trust frontend or keep logic simple.
-	// Actually, Go structs default to 0/empty.
-	// To truly distinguish "unset" vs "empty", we'd need pointer fields.
-	// For MVP, if Title is empty, assume we keep old one.
-
-	_, err = db.Exec(context.Background(),
-		"UPDATE tasks SET title=$1, description=$2, list_id=$3, position=$4, assignee_id=$5, board_id=$6 WHERE id=$7",
-		newTask.Title, newTask.Description, newTask.ListID, newTask.Position, newTask.AssigneeID, newTask.BoardID, id)
+	tag, err := tx.Exec(ctx,
+		"DELETE FROM board_members WHERE board_id=$1 AND member_id=$2",
+		boardID, memberID)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
-
-	userID := "mirza" // Default
-	if newTask.UpdatedBy != "" {
-		userID = newTask.UpdatedBy
+	if tag.RowsAffected() == 0 {
+		return fiber.NewError(404, "Board member not found")
 	}
-
-	if newTask.ListID != oldTask.ListID {
-		go logActivity(id, userID, "moved", fmt.Sprintf("Moved to list %s", newTask.ListID))
+	if err := tx.Commit(ctx); err != nil {
+		return err
 	}
+	emitEvent(boardID, EventBoardMemberRemoved, nil, caller, fiber.Map{"member_id": memberID})
+	return c.SendStatus(200)
+}
 
-	newAssignee, oldAssignee := "", ""
-	if newTask.AssigneeID != nil {
-		newAssignee = *newTask.AssigneeID
-	}
-	if oldTask.AssigneeID != nil {
-		oldAssignee = *oldTask.AssigneeID
+// keepAnOwner returns a 409 error if memberID is the board's only owner. It
+// locks the owner rows so concurrent demotions cannot both pass.
+func keepAnOwner(tx pgx.Tx, boardID, memberID string) error {
+	rows, err := tx.Query(context.Background(),
+		"SELECT member_id FROM board_members WHERE board_id=$1 AND role=$2 FOR UPDATE",
+		boardID, RoleOwner)
+	if err != nil {
+		return err
 	}
-
-	if newAssignee != oldAssignee {
-		if newAssignee != "" {
-			go logActivity(id, userID, "assigned", fmt.Sprintf("Assigned to %s", newAssignee))
-		} else {
-			go logActivity(id, userID, "unassigned", "Removed assignee")
+	var owners []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
 		}
+		owners = append(owners, id)
 	}
-
-	if newTask.Description != oldTask.Description {
-		go logActivity(id, userID, "updated", "Updated task description")
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
-
-	go updateEmbedding(id, newTask.Title+" "+newTask.Description)
-	go broadcastUpdate("UPDATE")
-	return c.JSON(newTask)
+	if len(owners) == 1 && owners[0] == memberID {
+		return fiber.NewError(409, "A board must keep at least one owner")
+	}
+	return nil
 }
 
 func logActivity(taskID int, userID, action, details string) {
-	db.Exec(context.Background(),
-		"INSERT INTO activities (task_id, user_id, action, details) VALUES ($1, $2, $3, $4)",
-		taskID, userID, action, details)
+	var boardID string
+	db.QueryRow(context.Background(),
+		"INSERT INTO activities (task_id, user_id, action, details) VALUES ($1, $2, $3, $4) RETURNING (SELECT board_id::text FROM tasks WHERE id=$1)",
+		taskID, userID, action, details).Scan(&boardID)
+	// Snapshots report each task's last activity time.
+	invalidateCache(boardID, cacheSnapshot)
 }
 
+// getTaskActivities lists a task's activity newest first.
 func getTaskActivities(c *fiber.Ctx) error {
-	taskID := c.Params("id")
-	rows, err := db.Query(context.Background(), "SELECT id, task_id, user_id, action, details, created_at FROM activities WHERE task_id=$1 ORDER BY created_at DESC", taskID)
+	page, err := parsePage(c, "-created")
+	if err != nil {
+		return err
+	}
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	query := "SELECT id, task_id, user_id, action, details, created_at FROM activities WHERE task_id=$1"
+	args := []interface{}{taskID}
+	var after time.Time
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		query += " AND (created_at, id) < ($2, $3)"
+		args = append(args, after, page.Cursor.ID)
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY created_at DESC, id DESC"+page.limitSQL(), args...)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 	defer rows.Close()
 	var activities []Activity
 	for rows.Next() {
 		var a Activity
 		if err := rows.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Action, &a.Details, &a.CreatedAt); err != nil {
-			return c.Status(500).SendString(err.Error())
+			return err
 		}
 		activities = append(activities, a)
 	}
-	if activities == nil {
-		activities = []Activity{}
-	}
-	return c.JSON(activities)
+	return sendPage(c, page, activities, func(a Activity) pageCursor { return cursorAt("-created", a.CreatedAt, a.ID, "") })
 }
 
 func updateEmbedding(id int, text string) {
-	emb, err := generateEmbedding(text)
-	if err != nil {
+	if err := storeEmbedding("tasks", id, text); err != nil {
 		log.Printf("Emb err: %v", err)
-		return
 	}
-	_, err = db.Exec(context.Background(), "UPDATE tasks SET embedding = $1 WHERE id = $2", pgvector(emb), id)
+}
+
+// storeEmbedding embeds text and saves it on row id of table (tasks or
+// documents).
+func storeEmbedding(table string, id int, text string) error {
+	emb, err := generateEmbedding(text)
 	if err != nil {
-		log.Printf("Db emb err: %v", err)
+		return err
 	}
+	_, err = db.Exec(context.Background(), "UPDATE "+table+" SET embedding = $1 WHERE id = $2", pgvector(emb), id)
+	return err
 }
 
 func pgvector(v []float32) string { b, _ := json.Marshal(v); return string(b) }
@@ -656,66 +644,73 @@ func pgvector(v []float32) string { b, _ := json.Marshal(v); return string(b) }
 func searchTasks(c *fiber.Ctx) error {
 	query := c.Query("q")
 	if query == "" {
-		return c.Status(400).SendString("Query required")
+		return invalidField("q", "is required")
 	}
 	emb, err := generateEmbedding(query)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
+	}
+	where, args, err := taskFilter(c, []string{"TRUE"}, []interface{}{pgvector(emb)})
+	if err != nil {
+		return badRequest(err)
 	}
 	rows, err := db.Query(context.Background(),
-		"SELECT id, board_id::text, title, description, list_id, position, assignee_id FROM tasks ORDER BY embedding <=> $1 LIMIT 5",
-		pgvector(emb))
+		"SELECT "+taskColumns+" FROM tasks t WHERE "+strings.Join(where, " AND ")+" ORDER BY t.embedding <=> $1 LIMIT 5",
+		args...)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
-	defer rows.Close()
-	var tasks []Task
-	for rows.Next() {
-		var t Task
-		if err := rows.Scan(&t.ID, &t.BoardID, &t.Title, &t.Description, &t.ListID, &t.Position, &t.AssigneeID); err != nil {
-			return c.Status(500).SendString(err.Error())
-		}
-		tasks = append(tasks, t)
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return err
 	}
-	if tasks == nil {
-		tasks = []Task{}
+	if err := decorateTasks(db, tasks); err != nil {
+		return err
 	}
 	return c.JSON(tasks)
 }
 
 // --- Knowledge Base / Documents ---
 
+// getBoardDocs lists a board's documents, most recently updated first.
 func getBoardDocs(c *fiber.Ctx) error {
-	boardID := c.Params("id")
-	rows, err := db.Query(context.Background(),
-		"SELECT id, board_id::text, title, content, created_at, updated_at FROM documents WHERE board_id=$1 ORDER BY updated_at DESC",
-		boardID)
+	page, err := parsePage(c, "-updated")
+	if err != nil {
+		return err
+	}
+	query := "SELECT id, board_id::text, title, content, created_at, updated_at FROM documents WHERE board_id=$1"
+	args := []interface{}{c.Params("id")}
+	var after time.Time
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		query += " AND (updated_at, id) < ($2, $3)"
+		args = append(args, after, page.Cursor.ID)
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY updated_at DESC, id DESC"+page.limitSQL(), args...)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 	defer rows.Close()
 	var docs []Document
 	for rows.Next() {
 		var d Document
 		if err := rows.Scan(&d.ID, &d.BoardID, &d.Title, &d.Content, &d.CreatedAt, &d.UpdatedAt); err != nil {
-			return c.Status(500).SendString(err.Error())
+			return err
 		}
 		docs = append(docs, d)
 	}
-	if docs == nil {
-		docs = []Document{}
-	}
-	return c.JSON(docs)
+	return sendPage(c, page, docs, func(d Document) pageCursor { return cursorAt("-updated", d.UpdatedAt, d.ID, "") })
 }
 
 func createDoc(c *fiber.Ctx) error {
 	boardID := c.Params("id")
 	d := new(Document)
-	if err := c.BodyParser(d); err != nil {
-		return c.Status(400).SendString(err.Error())
+	if err := parseBody(c, d); err != nil {
+		return err
 	}
 	if d.Title == "" {
-		return c.Status(400).SendString("Title is required")
+		return invalidField("title", "is required")
 	}
 	var id int
 	var createdAt, updatedAt time.Time
@@ -723,29 +718,53 @@ func createDoc(c *fiber.Ctx) error {
 		"INSERT INTO documents (board_id, title, content) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at",
 		boardID, d.Title, d.Content).Scan(&id, &createdAt, &updatedAt)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 	d.ID = id
 	d.BoardID = boardID
 	d.CreatedAt = createdAt
 	d.UpdatedAt = updatedAt
-	go updateDocEmbedding(id, d.Title+" "+d.Content)
+	if embedFor(c, 1) {
+		go updateDocEmbedding(id, d.Title+" "+d.Content)
+	}
+	emitEvent(boardID, EventDocCreated, nil, callerID(c), d)
+	return c.JSON(d)
+}
+
+func getDoc(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	var d Document
+	err = db.QueryRow(context.Background(),
+		"SELECT id, board_id::text, title, content, created_at, updated_at FROM documents WHERE id=$1", id).Scan(
+		&d.ID, &d.BoardID, &d.Title, &d.Content, &d.CreatedAt, &d.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Document not found")
+	}
+	if err != nil {
+		return err
+	}
 	return c.JSON(d)
 }
 
 func updateDoc(c *fiber.Ctx) error {
-	id, _ := strconv.Atoi(c.Params("id"))
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
 	d := new(Document)
-	if err := c.BodyParser(d); err != nil {
-		return c.Status(400).SendString(err.Error())
+	if err := parseBody(c, d); err != nil {
+		return err
 	}
 
 	var existing Document
-	err := db.QueryRow(context.Background(),
+	err = db.QueryRow(context.Background(),
 		"SELECT id, board_id::text, title, content FROM documents WHERE id=$1", id).Scan(
 		&existing.ID, &existing.BoardID, &existing.Title, &existing.Content)
 	if err != nil {
-		return c.Status(404).SendString("Document not found")
+		return fiber.NewError(404, "Document not found")
 	}
 
 	if d.Title != "" {
@@ -759,40 +778,42 @@ func updateDoc(c *fiber.Ctx) error {
 		"UPDATE documents SET title=$1, content=$2, updated_at=CURRENT_TIMESTAMP WHERE id=$3",
 		existing.Title, existing.Content, id)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 
 	err = db.QueryRow(context.Background(),
 		"SELECT id, board_id::text, title, content, created_at, updated_at FROM documents WHERE id=$1", id).Scan(
 		&existing.ID, &existing.BoardID, &existing.Title, &existing.Content, &existing.CreatedAt, &existing.UpdatedAt)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
+	}
+	if embedFor(c, 1) {
+		go updateDocEmbedding(id, existing.Title+" "+existing.Content)
 	}
-	go updateDocEmbedding(id, existing.Title+" "+existing.Content)
+	emitEvent(existing.BoardID, EventDocUpdated, nil, callerID(c), existing)
 	return c.JSON(existing)
 }
 
 func deleteDoc(c *fiber.Ctx) error {
-	id, _ := strconv.Atoi(c.Params("id"))
-	result, err := db.Exec(context.Background(), "DELETE FROM documents WHERE id=$1", id)
+	id, err := paramInt(c, "id")
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
-	if result.RowsAffected() == 0 {
-		return c.Status(404).SendString("Document not found")
+	var boardID string
+	err = db.QueryRow(context.Background(), "DELETE FROM documents WHERE id=$1 RETURNING board_id::text", id).Scan(&boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Document not found")
+	}
+	if err != nil {
+		return err
 	}
+	emitEvent(boardID, EventDocDeleted, nil, callerID(c), fiber.Map{"id": id})
 	return c.SendStatus(200)
 }
 
 func updateDocEmbedding(id int, text string) {
-	emb, err := generateEmbedding(text)
-	if err != nil {
+	if err := storeEmbedding("documents", id, text); err != nil {
 		log.Printf("Doc emb err: %v", err)
-		return
-	}
-	_, err = db.Exec(context.Background(), "UPDATE documents SET embedding = $1 WHERE id = $2", pgvector(emb), id)
-	if err != nil {
-		log.Printf("Doc db emb err: %v", err)
 	}
 }
 
@@ -800,11 +821,11 @@ func searchDocs(c *fiber.Ctx) error {
 	query := c.Query("q")
 	boardID := c.Query("board_id")
 	if query == "" {
-		return c.Status(400).SendString("Query required")
+		return invalidField("q", "is required")
 	}
 	emb, err := generateEmbedding(query)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 
 	var sqlQuery string
@@ -819,14 +840,14 @@ func searchDocs(c *fiber.Ctx) error {
 
 	rows, err := db.Query(context.Background(), sqlQuery, args...)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 	defer rows.Close()
 	var docs []Document
 	for rows.Next() {
 		var d Document
 		if err := rows.Scan(&d.ID, &d.BoardID, &d.Title, &d.Content, &d.CreatedAt, &d.UpdatedAt); err != nil {
-			return c.Status(500).SendString(err.Error())
+			return err
 		}
 		docs = append(docs, d)
 	}
@@ -837,46 +858,64 @@ func searchDocs(c *fiber.Ctx) error {
 }
 
 func getTaskComments(c *fiber.Ctx) error {
-	taskID, _ := strconv.Atoi(c.Params("id"))
-	rows, err := db.Query(context.Background(),
-		"SELECT c.id, c.task_id, c.user_id, c.content, c.created_at FROM comments c WHERE c.task_id=$1 ORDER BY c.created_at ASC", taskID)
+	page, err := parsePage(c, "created")
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
+	}
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	query := "SELECT c.id, c.task_id, c.user_id, c.content, c.created_at FROM comments c WHERE c.task_id=$1"
+	args := []interface{}{taskID}
+	var after time.Time
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		query += " AND (c.created_at, c.id) > ($2, $3)"
+		args = append(args, after, page.Cursor.ID)
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY c.created_at ASC, c.id ASC"+page.limitSQL(), args...)
+	if err != nil {
+		return err
 	}
 	defer rows.Close()
 	var comments []Comment
 	for rows.Next() {
 		var cm Comment
 		if err := rows.Scan(&cm.ID, &cm.TaskID, &cm.UserID, &cm.Content, &cm.CreatedAt); err != nil {
-			return c.Status(500).SendString(err.Error())
+			return err
 		}
 		comments = append(comments, cm)
 	}
-	if comments == nil {
-		comments = []Comment{}
-	}
-	return c.JSON(comments)
+	return sendPage(c, page, comments, func(cm Comment) pageCursor { return cursorAt("created", cm.CreatedAt, cm.ID, "") })
 }
 
 func createComment(c *fiber.Ctx) error {
-	taskID, _ := strconv.Atoi(c.Params("id"))
-	cm := new(Comment)
-	if err := c.BodyParser(cm); err != nil {
-		return c.Status(400).SendString(err.Error())
+	taskID, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	cm := &Comment{UserID: callerID(c)} // only anonymous requests may name the author
+	if err := parseBody(c, cm); err != nil {
+		return err
 	}
-	if cm.UserID == "" || cm.Content == "" {
-		return c.Status(400).SendString("user_id and content are required")
+	if cm.UserID, err = actorOr(c, cm.UserID); err != nil {
+		return err
 	}
 	var id int
 	var createdAt time.Time
-	err := db.QueryRow(context.Background(),
+	err = db.QueryRow(context.Background(),
 		"INSERT INTO comments (task_id, user_id, content) VALUES ($1, $2, $3) RETURNING id, created_at",
 		taskID, cm.UserID, cm.Content).Scan(&id, &createdAt)
 	if err != nil {
-		return c.Status(500).SendString(err.Error())
+		return err
 	}
 	cm.ID = id
 	cm.TaskID = taskID
 	cm.CreatedAt = createdAt
+	if boardID, err := taskBoardID(taskID); err == nil {
+		emitEvent(boardID, EventCommentCreated, &taskID, cm.UserID, cm)
+	}
 	return c.JSON(cm)
 }
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// The backend speaks the Model Context Protocol at /mcp over the streamable
+// HTTP transport (JSON responses, no server-initiated streams). Every tool is
+// an API route: its input schema is generated from the route's path, query
+// and body types, and a call runs through the router as the member who owns
+// the bearer token, so tools share the REST handlers, validation and
+// permissions. Board documents are exposed as resources.
+const (
+	mcpPath            = "/mcp"
+	mcpProtocolVersion = "2025-06-18"
+	mcpDocURIPrefix    = "moziboard://docs/"
+	mcpResourcePage    = 100
+)
+
+var mcpProtocolVersions = map[string]bool{"2025-06-18": true, "2025-03-26": true, "2024-11-05": true}
+
+// mcpTool exposes the route Method Path as a tool. Path parameters become
+// arguments named by Args (default: the parameter name); query parameters
+// and body fields keep their names. Actor names the body or query field
+// that is set to the calling member instead of being offered to the model.
+type mcpTool struct {
+	Name        string
+	Description string
+	Method      string
+	Path        string
+	Args        map[string]string
+	Omit        []string
+	Required    []string
+	Actor       string
+}
+
+var mcpTools = []mcpTool{
+	{Name: "list_boards", Description: "List boards", Method: "GET", Path: "/boards"},
+	{Name: "get_board_snapshot", Description: "Get a board with its lists, tasks, members and document titles in one call", Method: "GET", Path: "/boards/:id/snapshot", Args: map[string]string{"id": "board_id"}},
+	{Name: "list_tasks", Description: "List tasks on a board, optionally filtered", Method: "GET", Path: "/boards/:id/tasks", Args: map[string]string{"id": "board_id"}},
+	{Name: "query_tasks", Description: `Find tasks across all boards with the task query language, e.g. assignee:kodinger list:doing label:bug due<7d priority>=high "free text"`, Method: "GET", Path: "/tasks", Required: []string{"q"}},
+	{Name: "search_tasks", Description: "Semantic search over tasks", Method: "GET", Path: "/search", Required: []string{"q"}},
+	{Name: "get_task", Description: "Get a task with its checklist, subtasks and progress", Method: "GET", Path: "/tasks/:id"},
+	{Name: "create_task", Description: "Create a task; board_id defaults to the parent's board or the first board", Method: "POST", Path: "/tasks",
+		Omit: []string{"id", "position", "completed_at", "labels", "progress", "blocked_by"}, Required: []string{"title"}, Actor: "updated_by"},
+	{Name: "update_task", Description: "Update a task; null clears dates, estimate and parent", Method: "PUT", Path: "/tasks/:id",
+		Omit: []string{"board_id", "completed_at", "labels", "progress", "blocked_by"}, Actor: "updated_by"},
+	{Name: "link_tasks", Description: "Link two tasks, e.g. mark a task as blocked by another", Method: "POST", Path: "/tasks/:id/links", Args: map[string]string{"id": "task_id"},
+		Required: []string{"type", "target_id"}, Actor: "updated_by"},
+	{Name: "add_checklist_item", Description: "Add a step to a task's checklist", Method: "POST", Path: "/tasks/:id/checklist", Args: map[string]string{"id": "task_id"},
+		Required: []string{"text"}, Actor: "updated_by"},
+	{Name: "update_checklist_item", Description: "Edit, reorder, assign or check off a checklist item", Method: "PUT", Path: "/checklist/:id", Actor: "updated_by"},
+	{Name: "toggle_checklist_item", Description: "Flip a checklist item between done and not done", Method: "POST", Path: "/checklist/:id/toggle",
+		Omit: []string{"text", "done", "assignee_id", "position"}, Actor: "updated_by"},
+	{Name: "list_docs", Description: "List the documents in a board's knowledge base", Method: "GET", Path: "/boards/:id/docs", Args: map[string]string{"id": "board_id"}},
+	{Name: "get_doc", Description: "Get the full content of a document", Method: "GET", Path: "/docs/:id"},
+	{Name: "create_doc", Description: "Create a document (Markdown) in a board's knowledge base", Method: "POST", Path: "/boards/:id/docs", Args: map[string]string{"id": "board_id"},
+		Required: []string{"title"}},
+	{Name: "update_doc", Description: "Update a document's title or content", Method: "PUT", Path: "/docs/:id", Omit: []string{"board_id"}},
+	{Name: "search_docs", Description: "Semantic search across knowledge base documents for specs and project context", Method: "GET", Path: "/docs/search", Required: []string{"q"}},
+	{Name: "list_comments", Description: "List the comments on a task", Method: "GET", Path: "/tasks/:id/comments", Args: map[string]string{"id": "task_id"}},
+	{Name: "post_comment", Description: "Comment on a task", Method: "POST", Path: "/tasks/:id/comments", Args: map[string]string{"id": "task_id"},
+		Required: []string{"content"}, Actor: "user_id"},
+}
+
+func (t mcpTool) arg(param string) string {
+	if name, ok := t.Args[param]; ok {
+		return name
+	}
+	return param
+}
+
+func (t mcpTool) route() (route, bool) {
+	for _, r := range apiRoutes() {
+		if r.Method == t.Method && r.Path == t.Path {
+			return r, true
+		}
+	}
+	return route{}, false
+}
+
+// pathArgSchema types a path parameter: board ids are UUIDs and member ids
+// and template names are text; everything else is a serial id.
+func pathArgSchema(path, param string) map[string]interface{} {
+	if param == "name" || strings.HasPrefix(path, "/members/") || (param == "id" && strings.HasPrefix(path, "/boards/")) {
+		return map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{"type": "integer"}
+}
+
+func (t mcpTool) inputSchema(r route) map[string]interface{} {
+	b := newSchemaBuilder("#/$defs/")
+	props := map[string]interface{}{}
+	required := []string{}
+	_, params := openAPIPath(r.Path)
+	for _, p := range params {
+		props[t.arg(p)] = pathArgSchema(r.Path, p)
+		required = append(required, t.arg(p))
+	}
+	for _, q := range r.Query {
+		props[q] = queryParamSchema(q)
+	}
+	if r.Body != nil {
+		omit := map[string]bool{"created_at": true, "updated_at": true, t.Actor: true}
+		for _, name := range t.Omit {
+			omit[name] = true
+		}
+		for _, f := range jsonFields(reflect.TypeOf(r.Body)) {
+			if _, taken := props[f.Name]; !taken && !omit[f.Name] {
+				props[f.Name] = b.schema(f.Type)
+			}
+		}
+	}
+	delete(props, t.Actor)
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if required = append(required, t.Required...); len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(b.components) > 0 {
+		schema["$defs"] = b.components
+	}
+	return schema
+}
+
+// dispatch runs the tool's route in-process with the caller's credentials
+// and returns the response status and body.
+func (t mcpTool) dispatch(c *fiber.Ctx, r route, args map[string]interface{}) (int, []byte, error) {
+	path := r.Path
+	_, params := openAPIPath(r.Path)
+	used := map[string]bool{}
+	for _, p := range params {
+		v, ok := args[t.arg(p)]
+		if !ok || v == nil {
+			return 0, nil, fmt.Errorf("%s is required", t.arg(p))
+		}
+		path = strings.Replace(path, ":"+p, url.PathEscape(fmt.Sprint(v)), 1)
+		used[t.arg(p)] = true
+	}
+	query := url.Values{}
+	for _, q := range r.Query {
+		if v, ok := args[q]; ok && v != nil {
+			query.Set(q, fmt.Sprint(v))
+			used[q] = true
+		}
+	}
+	if t.Actor != "" && contains(r.Query, t.Actor) {
+		query.Set(t.Actor, callerID(c))
+	}
+	uri := apiPrefixes[0] + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	req := new(fasthttp.RequestCtx)
+	req.Request.Header.SetMethod(r.Method)
+	req.Request.SetRequestURI(uri)
+	req.Request.Header.Set(fiber.HeaderAuthorization, c.Get(fiber.HeaderAuthorization))
+	if r.Body != nil {
+		body := map[string]interface{}{}
+		for k, v := range args {
+			if !used[k] {
+				body[k] = v
+			}
+		}
+		if t.Actor != "" {
+			body[t.Actor] = callerID(c)
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Request.Header.SetContentType(fiber.MIMEApplicationJSON)
+		req.Request.SetBody(data)
+	}
+	c.App().Handler()(req)
+	return req.Response.StatusCode(), append([]byte(nil), req.Response.Body()...), nil
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	mcpNotFound       = -32002
+)
+
+// handleMCP handles /mcp. It requires a member API token; POST carries one
+// JSON-RPC message or a batch, and GET and DELETE are not supported because
+// the server keeps no sessions or streams.
+func handleMCP(c *fiber.Ctx) error {
+	if _, ok := c.Locals(callerLocal).(string); !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="moziboard"`)
+		return fiber.NewError(401, "An API token is required (Authorization: Bearer <token>)")
+	}
+	if c.Method() != fiber.MethodPost {
+		c.Set(fiber.HeaderAllow, fiber.MethodPost)
+		return fiber.NewError(405, "Only POST is supported")
+	}
+
+	body := bytes.TrimSpace(c.Body())
+	batch := len(body) > 0 && body[0] == '['
+	var msgs []json.RawMessage
+	if batch {
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			return c.Status(400).JSON(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, "Parse error"}})
+		}
+	} else {
+		msgs = []json.RawMessage{body}
+	}
+
+	responses := []rpcResponse{}
+	for _, raw := range msgs {
+		var req rpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses = append(responses, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, "Parse error"}})
+			continue
+		}
+		if len(req.ID) == 0 {
+			continue // notification or response; nothing to answer
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			resp.Error = &rpcError{rpcInvalidRequest, "Invalid request"}
+		} else if result, err := mcpCall(c, req.Method, req.Params); err != nil {
+			if rerr, ok := err.(*rpcError); ok {
+				resp.Error = rerr
+			} else {
+				resp.Error = &rpcError{rpcInternalError, err.Error()}
+			}
+		} else {
+			resp.Result = result
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(responses) == 0 {
+		return c.SendStatus(202)
+	}
+	if batch {
+		return c.JSON(responses)
+	}
+	return c.JSON(responses[0])
+}
+
+func mcpCall(c *fiber.Ctx, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := mcpProtocolVersion
+		if mcpProtocolVersions[p.ProtocolVersion] {
+			version = p.ProtocolVersion
+		}
+		return fiber.Map{
+			"protocolVersion": version,
+			"capabilities":    fiber.Map{"tools": fiber.Map{}, "resources": fiber.Map{}},
+			"serverInfo":      fiber.Map{"name": "moziboard", "version": apiVersion},
+			"instructions":    "Tools act as the member who owns the API token. Board documents are available as resources.",
+		}, nil
+	case "ping":
+		return fiber.Map{}, nil
+	case "tools/list":
+		tools := []fiber.Map{}
+		for _, t := range mcpTools {
+			r, ok := t.route()
+			if !ok {
+				continue
+			}
+			tools = append(tools, fiber.Map{"name": t.Name, "description": t.Description, "inputSchema": t.inputSchema(r)})
+		}
+		return fiber.Map{"tools": tools}, nil
+	case "tools/call":
+		return mcpCallTool(c, params)
+	case "resources/list":
+		return mcpListResources(params)
+	case "resources/templates/list":
+		return fiber.Map{"resourceTemplates": []fiber.Map{{
+			"uriTemplate": mcpDocURIPrefix + "{id}",
+			"name":        "document",
+			"description": "A board knowledge base document",
+			"mimeType":    "text/markdown",
+		}}}, nil
+	case "resources/read":
+		return mcpReadResource(c, params)
+	}
+	return nil, &rpcError{rpcMethodNotFound, "Method not found: " + method}
+}
+
+func mcpCallTool(c *fiber.Ctx, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{rpcInvalidParams, "Invalid params"}
+	}
+	args := map[string]interface{}{}
+	if len(p.Arguments) > 0 && string(p.Arguments) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(p.Arguments))
+		dec.UseNumber()
+		if err := dec.Decode(&args); err != nil {
+			return nil, &rpcError{rpcInvalidParams, "arguments must be an object"}
+		}
+	}
+	for _, t := range mcpTools {
+		if t.Name != p.Name {
+			continue
+		}
+		r, ok := t.route()
+		if !ok {
+			break
+		}
+		status, body, err := t.dispatch(c, r, args)
+		if err != nil {
+			return fiber.Map{"content": []fiber.Map{{"type": "text", "text": err.Error()}}, "isError": true}, nil
+		}
+		return fiber.Map{"content": []fiber.Map{{"type": "text", "text": string(body)}}, "isError": status >= 400}, nil
+	}
+	return nil, &rpcError{rpcInvalidParams, "Unknown tool: " + p.Name}
+}
+
+// mcpListResources lists documents on non-archived boards, most recently
+// updated first, mcpResourcePage at a time.
+func mcpListResources(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(params, &p)
+	query := `
+		SELECT d.id, d.title, d.updated_at, b.title FROM documents d JOIN boards b ON b.id = d.board_id
+		WHERE b.archived_at IS NULL`
+	args := []interface{}{}
+	if p.Cursor != "" {
+		cur, err := decodeCursor(p.Cursor)
+		var after time.Time
+		if err != nil || cur.Sort != "-updated" || json.Unmarshal(cur.Value, &after) != nil {
+			return nil, &rpcError{rpcInvalidParams, "Invalid cursor"}
+		}
+		query += " AND (d.updated_at, d.id) < ($1, $2)"
+		args = append(args, after, cur.ID)
+	}
+	rows, err := db.Query(context.Background(), query+fmt.Sprintf(" ORDER BY d.updated_at DESC, d.id DESC LIMIT %d", mcpResourcePage+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	resources := []fiber.Map{}
+	result := fiber.Map{"resources": resources}
+	var lastID int
+	var lastUpdated time.Time
+	for rows.Next() {
+		if len(resources) == mcpResourcePage {
+			result["nextCursor"] = encodeCursor(cursorAt("-updated", lastUpdated, lastID, ""))
+			break
+		}
+		var title, boardTitle string
+		if err := rows.Scan(&lastID, &title, &lastUpdated, &boardTitle); err != nil {
+			return nil, err
+		}
+		resources = append(resources, fiber.Map{
+			"uri":         fmt.Sprintf("%s%d", mcpDocURIPrefix, lastID),
+			"name":        title,
+			"description": "Document on " + boardTitle,
+			"mimeType":    "text/markdown",
+		})
+	}
+	result["resources"] = resources
+	return result, nil
+}
+
+// mcpReadResource reads a document through GET /docs/:id.
+func mcpReadResource(c *fiber.Ctx, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	json.Unmarshal(params, &p)
+	id, ok := strings.CutPrefix(p.URI, mcpDocURIPrefix)
+	if !ok || id == "" {
+		return nil, &rpcError{mcpNotFound, "Resource not found: " + p.URI}
+	}
+	tool := mcpTool{Method: "GET", Path: "/docs/:id"}
+	r, _ := tool.route()
+	status, body, err := tool.dispatch(c, r, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	if status == 404 || status == 400 {
+		return nil, &rpcError{mcpNotFound, "Resource not found: " + p.URI}
+	}
+	if status >= 400 {
+		return nil, &rpcError{rpcInternalError, string(body)}
+	}
+	var d Document
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, err
+	}
+	return fiber.Map{"contents": []fiber.Map{{"uri": p.URI, "mimeType": "text/markdown", "text": "# " + d.Title + "\n\n" + d.Content}}}, nil
+}
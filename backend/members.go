@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+type MemberKind string
+
+const (
+	MemberHuman   MemberKind = "human"
+	MemberAgent   MemberKind = "agent"
+	MemberService MemberKind = "service"
+)
+
+func (k MemberKind) Valid() bool {
+	switch k {
+	case MemberHuman, MemberAgent, MemberService:
+		return true
+	}
+	return false
+}
+
+type Member struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Kind   MemberKind `json:"kind"`
+	Role   string     `json:"role"` // Deprecated: mirrors Kind for older clients.
+	Avatar string     `json:"avatar"`
+	Email  string     `json:"email,omitempty"`
+	Title  string     `json:"title,omitempty"`
+	Bio    string     `json:"bio,omitempty"`
+	Active bool       `json:"active"`
+
+	CreatedAt     time.Time  `json:"created_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+// MemberUpdate carries the fields of PUT /api/members/:id; nil means unchanged.
+type MemberUpdate struct {
+	Name   *string     `json:"name"`
+	Kind   *MemberKind `json:"kind"`
+	Avatar *string     `json:"avatar"`
+	Email  *string     `json:"email"`
+	Title  *string     `json:"title"`
+	Bio    *string     `json:"bio"`
+	Active *bool       `json:"active"`
+}
+
+const memberColumns = "m.id, m.name, m.kind, m.avatar, m.email, m.title, m.bio, m.active, m.created_at, m.deactivated_at"
+
+var memberIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+func scanMember(row pgx.Row) (Member, error) {
+	var m Member
+	var avatar, email, title, bio *string
+	err := row.Scan(&m.ID, &m.Name, &m.Kind, &avatar, &email, &title, &bio, &m.Active, &m.CreatedAt, &m.DeactivatedAt)
+	if avatar != nil {
+		m.Avatar = *avatar
+	}
+	if email != nil {
+		m.Email = *email
+	}
+	if title != nil {
+		m.Title = *title
+	}
+	if bio != nil {
+		m.Bio = *bio
+	}
+	m.Role = string(m.Kind)
+	return m, err
+}
+
+func scanMembers(rows pgx.Rows) ([]Member, error) {
+	defer rows.Close()
+	var members []Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	if members == nil {
+		members = []Member{}
+	}
+	return members, rows.Err()
+}
+
+func validateMember(m *Member) error {
+	if !memberIDPattern.MatchString(m.ID) {
+		return invalidField("id", "must be 2-32 characters of lowercase letters, digits, '-' or '_'")
+	}
+	if m.Name == "" {
+		return invalidField("name", "is required")
+	}
+	if !m.Kind.Valid() {
+		return invalidField("kind", "must be one of human, agent, service")
+	}
+	if m.Email != "" {
+		if _, err := mail.ParseAddress(m.Email); err != nil {
+			return invalidField("email", "is invalid")
+		}
+	}
+	return nil
+}
+
+func getMembers(c *fiber.Ctx) error {
+	page, err := parsePage(c, "created")
+	if err != nil {
+		return err
+	}
+	where := []string{"TRUE"}
+	var args []interface{}
+	if !c.QueryBool("include_inactive") {
+		where = append(where, "m.active")
+	}
+	var after time.Time
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		args = append(args, after, page.Cursor.Key)
+		where = append(where, "(m.created_at, m.id) > ($1, $2)")
+	}
+	rows, err := db.Query(context.Background(),
+		"SELECT "+memberColumns+" FROM members m WHERE "+strings.Join(where, " AND ")+" ORDER BY m.created_at ASC, m.id ASC"+page.limitSQL(), args...)
+	if err != nil {
+		return err
+	}
+	members, err := scanMembers(rows)
+	if err != nil {
+		return err
+	}
+	return sendPage(c, page, members, func(m Member) pageCursor { return cursorAt("created", m.CreatedAt, 0, m.ID) })
+}
+
+func getMember(c *fiber.Ctx) error {
+	m, err := scanMember(db.QueryRow(context.Background(), "SELECT "+memberColumns+" FROM members m WHERE m.id=$1", c.Params("id")))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Member not found")
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(m)
+}
+
+func insertMember(m *Member) error {
+	return db.QueryRow(context.Background(),
+		"INSERT INTO members (id, name, kind, role, avatar, email, title, bio) VALUES ($1, $2, $3, $3, $4, $5, $6, $7) RETURNING active, created_at",
+		m.ID, m.Name, m.Kind, m.Avatar, m.Email, m.Title, m.Bio).Scan(&m.Active, &m.CreatedAt)
+}
+
+func createMember(c *fiber.Ctx) error {
+	if _, err := requireCaller(c); err != nil {
+		return err
+	}
+	m := new(Member)
+	if err := parseBody(c, m); err != nil {
+		return err
+	}
+	if m.Kind == "" {
+		// Accept the legacy field name.
+		m.Kind = MemberKind(m.Role)
+	}
+	if err := validateMember(m); err != nil {
+		return badRequest(err)
+	}
+	var exists bool
+	db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM members WHERE id=$1)", m.ID).Scan(&exists)
+	if exists {
+		return fiber.NewError(409, "Member already exists")
+	}
+	if err := insertMember(m); err != nil {
+		return err
+	}
+	m.Role = string(m.Kind)
+	return c.Status(201).JSON(m)
+}
+
+// updateMember edits a profile. Members may edit their own; only admins may
+// edit others or change whether a member is active.
+func updateMember(c *fiber.Ctx) error {
+	id := c.Params("id")
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	if caller != id && !isAdmin(caller) {
+		return fiber.NewError(403, "Members can only edit their own profile")
+	}
+	m, err := scanMember(db.QueryRow(context.Background(), "SELECT "+memberColumns+" FROM members m WHERE m.id=$1", id))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Member not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	req := new(MemberUpdate)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	if req.Name != nil {
+		m.Name = *req.Name
+	}
+	if req.Kind != nil {
+		m.Kind = *req.Kind
+	}
+	if req.Avatar != nil {
+		m.Avatar = *req.Avatar
+	}
+	if req.Email != nil {
+		m.Email = *req.Email
+	}
+	if req.Title != nil {
+		m.Title = *req.Title
+	}
+	if req.Bio != nil {
+		m.Bio = *req.Bio
+	}
+	if err := validateMember(&m); err != nil {
+		return badRequest(err)
+	}
+	active := m.Active
+	if req.Active != nil && *req.Active != m.Active {
+		if !isAdmin(caller) {
+			return fiber.NewError(403, "Only admins can activate or deactivate members")
+		}
+		active = *req.Active
+	}
+
+	err = db.QueryRow(context.Background(), `
+		UPDATE members SET name=$1, kind=$2, role=$2, avatar=$3, email=$4, title=$5, bio=$6, active=$7,
+			deactivated_at = CASE WHEN $7 THEN NULL WHEN active THEN CURRENT_TIMESTAMP ELSE deactivated_at END
+		WHERE id=$8 RETURNING active, deactivated_at`,
+		m.Name, m.Kind, m.Avatar, m.Email, m.Title, m.Bio, active, id).Scan(&m.Active, &m.DeactivatedAt)
+	if err != nil {
+		return err
+	}
+	m.Role = string(m.Kind)
+	invalidateMemberBoards(id)
+	return c.JSON(m)
+}
+
+// deleteMember deactivates rather than deletes, so tasks, comments and
+// activities keep pointing at a real member. Only admins may do it, since it
+// also disables the member's API tokens.
+func deleteMember(c *fiber.Ctx) error {
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+	result, err := db.Exec(context.Background(),
+		"UPDATE members SET active=FALSE, deactivated_at=COALESCE(deactivated_at, CURRENT_TIMESTAMP) WHERE id=$1",
+		c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "Member not found")
+	}
+	invalidateMemberBoards(c.Params("id"))
+	return c.SendStatus(200)
+}
+
+func getBoardMembers(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	query := `
+		SELECT ` + memberColumns + `
+		FROM members m
+		JOIN board_members bm ON m.id = bm.member_id
+		WHERE bm.board_id = $1
+	`
+	rows, err := db.Query(context.Background(), query, boardID)
+	if err != nil {
+		return err
+	}
+	members, err := scanMembers(rows)
+	if err != nil {
+		return err
+	}
+	return c.JSON(members)
+}
+
+// seedMembers creates the members listed in MEMBERS_SEED_FILE (a JSON array
+// of members) the first time the server starts against an empty members
+// table, and adds them to boardID. Existing members are never touched.
+func seedMembers(boardID string) {
+	path := os.Getenv("MEMBERS_SEED_FILE")
+	if path == "" {
+		return
+	}
+	var count int
+	if err := db.QueryRow(context.Background(), "SELECT COUNT(*) FROM members").Scan(&count); err != nil || count > 0 {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("seedMembers: %v", err)
+		return
+	}
+	var members []Member
+	if err := json.Unmarshal(data, &members); err != nil {
+		log.Printf("seedMembers: invalid %s: %v", path, err)
+		return
+	}
+	seeded := 0
+	for i := range members {
+		m := &members[i]
+		if m.Kind == "" {
+			m.Kind = MemberKind(m.Role)
+		}
+		if err := validateMember(m); err != nil {
+			log.Printf("seedMembers: skipping %q: %v", m.ID, err)
+			continue
+		}
+		if err := insertMember(m); err != nil {
+			log.Printf("seedMembers: %q: %v", m.ID, err)
+			continue
+		}
+		if boardID != "" {
+			db.Exec(context.Background(),
+				"INSERT INTO board_members (board_id, member_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+				boardID, m.ID)
+		}
+		seeded++
+	}
+	fmt.Printf("✅ Seeded %d members from %s\n", seeded, path)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The OpenAPI document is generated from the route table and the Go types
+// the handlers decode and return, so it cannot drift from the code the way a
+// hand-written one would. checkOpenAPI verifies that the router serves
+// exactly the documented operations; openapi_test.go checks each route's
+// query parameters, body and response against its handler.
+
+// enumValues lists the allowed values of the string enum types.
+var enumValues = map[reflect.Type][]string{
+	reflect.TypeOf(Priority("")):     {string(PriorityLow), string(PriorityMedium), string(PriorityHigh), string(PriorityUrgent)},
+	reflect.TypeOf(ListCategory("")): {string(ListTodo), string(ListDoing), string(ListDone)},
+	reflect.TypeOf(MemberKind("")):   {string(MemberHuman), string(MemberAgent), string(MemberService)},
+	reflect.TypeOf(FieldType("")): {string(FieldText), string(FieldNumber), string(FieldDate), string(FieldSelect),
+		string(FieldMultiSelect), string(FieldURL), string(FieldMember)},
+}
+
+var queryParamDocs = map[string]string{
+	"limit":            "Page size (max 500). Giving limit or cursor returns a {data, next_cursor} page instead of an array.",
+	"cursor":           "next_cursor from the previous page",
+	"q":                "Search text",
+	"sort":             "Sort key, e.g. priority, -due_date or cf.<key>",
+	"list_id":          "Comma-separated list ids, e.g. backlog,todo",
+	"assignee":         "Comma-separated member ids",
+	"priority":         "Comma-separated priorities: low, medium, high, urgent",
+	"due_before":       "RFC 3339 timestamp",
+	"due_after":        "RFC 3339 timestamp",
+	"label":            "Comma-separated label names; tasks with any of them",
+	"blocked":          "true for tasks with open blockers, false for the rest",
+	"overdue":          "true for tasks past their due date that are not done",
+	"include_archived": "Include archived boards",
+	"include_inactive": "Include deactivated members",
+	"template":         "Template to create the board from",
+	"confirm":          "Confirmation token from the first DELETE",
+	"format":           "Response format: yaml for specs and templates, zip for board exports",
+	"dry_run":          "Return the plan without applying it",
+	"since":            "Return events after this sequence number",
+	"board_id":         "Restrict to one board",
+	"start":            "Schedule start date (YYYY-MM-DD)",
+	"hours_per_day":    "Working hours per day for hour estimates",
+	"days_per_point":   "Days per story point",
+	"default_days":     "Duration of tasks without an estimate",
+	"updated_by":       "Member id recorded in the activity log; with an API token it must be the caller",
+	"title":            "Title of the imported board (default: the archived title)",
+	"members":          "Existing members whose profile differs: keep (default) or fail",
+	"reembed":          "Generate embeddings for the imported tasks and documents",
+	"columns":          "Comma-separated columns, e.g. id,title,list,assignee,created_at,cf.<key>; default: the task fields and every custom field",
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+)
+
+// getOpenAPI handles GET /api/openapi.json.
+func getOpenAPI(c *fiber.Ctx) error {
+	openAPIOnce.Do(func() {
+		openAPIJSON, _ = json.MarshalIndent(buildOpenAPI(), "", "  ")
+	})
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(openAPIJSON)
+}
+
+// queryParamTypes gives the JSON Schema type of query parameters that are
+// not plain strings.
+var queryParamTypes = map[string]string{
+	"limit":            "integer",
+	"blocked":          "boolean",
+	"overdue":          "boolean",
+	"include_archived": "boolean",
+	"include_inactive": "boolean",
+	"dry_run":          "boolean",
+	"reembed":          "boolean",
+	"hours_per_day":    "number",
+	"days_per_point":   "number",
+	"default_days":     "number",
+}
+
+func queryParamSchema(name string) map[string]interface{} {
+	s := map[string]interface{}{"type": "string"}
+	if t, ok := queryParamTypes[name]; ok {
+		s["type"] = t
+	}
+	if doc := queryParamDocs[name]; doc != "" {
+		s["description"] = doc
+	}
+	return s
+}
+
+// schemaBuilder collects the named types it meets as components, referenced
+// as refPrefix + type name.
+type schemaBuilder struct {
+	refPrefix  string
+	components map[string]interface{}
+}
+
+func newSchemaBuilder(refPrefix string) *schemaBuilder {
+	return &schemaBuilder{refPrefix: refPrefix, components: map[string]interface{}{}}
+}
+
+// schema returns the JSON Schema for t. Named structs are added to the
+// components and referenced.
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return map[string]interface{}{}
+	}
+	if values, ok := enumValues[t]; ok {
+		return b.component(t, func() map[string]interface{} {
+			return map[string]interface{}{"type": "string", "enum": values}
+		})
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schema(t.Elem())
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+			return s
+		}
+		return map[string]interface{}{"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}}}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.component(t, func() map[string]interface{} { return b.object(t) })
+	}
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) component(t reflect.Type, build func() map[string]interface{}) map[string]interface{} {
+	ref := map[string]interface{}{"$ref": b.refPrefix + t.Name()}
+	if _, ok := b.components[t.Name()]; !ok {
+		b.components[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+		b.components[t.Name()] = build()
+	}
+	return ref
+}
+
+type jsonField struct {
+	Name string
+	Type reflect.Type
+}
+
+// jsonFields lists the fields of a struct as encoding/json sees them,
+// flattening embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name, f.Type})
+	}
+	return fields
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for _, f := range jsonFields(t) {
+		props[f.Name] = b.schema(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+// operation describes one route.
+func (b *schemaBuilder) operation(r route, params []string) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(r.Method, r.Path),
+		"summary":     r.Summary,
+		"tags":        []string{r.Tag},
+	}
+
+	var parameters []interface{}
+	for _, p := range params {
+		parameters = append(parameters, map[string]interface{}{
+			"name": p, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range r.Query {
+		schema := queryParamSchema(q)
+		param := map[string]interface{}{"name": q, "in": "query", "schema": schema}
+		if doc, ok := schema["description"]; ok {
+			param["description"] = doc
+			delete(schema, "description")
+		}
+		parameters = append(parameters, param)
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if r.Body != nil {
+		content := map[string]interface{}{"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(r.Body))}}
+		switch r.Body.(type) {
+		case BoardSpec, BoardTemplate:
+			content["application/yaml"] = content["application/json"]
+		}
+		op["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = 200
+	}
+	ok := map[string]interface{}{"description": "OK"}
+	switch {
+	case r.Produces != "":
+		ok["content"] = map[string]interface{}{r.Produces: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	case r.Response != nil:
+		var s map[string]interface{}
+		if p, isPaged := r.Response.(paged); isPaged {
+			item := b.schema(reflect.TypeOf(p.Item))
+			s = map[string]interface{}{"oneOf": []interface{}{
+				map[string]interface{}{"type": "array", "items": item},
+				map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+					"data":        map[string]interface{}{"type": "array", "items": item},
+					"next_cursor": map[string]interface{}{"type": []string{"string", "null"}},
+				}},
+			}}
+		} else {
+			s = b.schema(reflect.TypeOf(r.Response))
+		}
+		ok["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": s}}
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(status): ok,
+		"default":          map[string]interface{}{"$ref": "#/components/responses/Error"},
+	}
+	return op
+}
+
+// operationID turns GET /boards/:id/tasks into getBoardsIdTasks.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '.' || r == '_' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func buildOpenAPI() map[string]interface{} {
+	b := newSchemaBuilder("#/components/schemas/")
+	paths := map[string]map[string]interface{}{}
+	for _, r := range apiRoutes() {
+		path, params := openAPIPath(r.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(r.Method)] = b.operation(r, params)
+	}
+	b.schema(reflect.TypeOf(APIError{}))
+
+	servers := []interface{}{}
+	for _, prefix := range apiPrefixes {
+		servers = append(servers, map[string]interface{}{"url": prefix})
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "Moziboard API",
+			"version": apiVersion,
+		},
+		"servers":  servers,
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"token": []string{}}},
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/APIError"},
+					}},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Member API token"},
+			},
+		},
+	}
+}
+
+// checkOpenAPI compares the routes registered on app with the OpenAPI
+// document and returns one line per disagreement: an API route the spec does
+// not describe, a documented operation that is not served under every
+// prefix, or an operation documented twice.
+func checkOpenAPI(app *fiber.App) []string {
+	var problems []string
+	documented := map[string]bool{}
+	for _, r := range apiRoutes() {
+		path, _ := openAPIPath(r.Path)
+		op := r.Method + " " + path
+		if documented[op] {
+			problems = append(problems, "documented twice: "+op)
+		}
+		documented[op] = true
+	}
+
+	served := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead || !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+		path, _ := openAPIPath(r.Path)
+		served[r.Method+" "+path] = true
+		rel := ""
+		for _, prefix := range apiPrefixes {
+			if strings.HasPrefix(path, prefix+"/") {
+				rel = strings.TrimPrefix(path, prefix)
+				break
+			}
+		}
+		if !documented[r.Method+" "+rel] {
+			problems = append(problems, "not in the OpenAPI spec: "+r.Method+" "+r.Path)
+		}
+	}
+	for op := range documented {
+		method, path, _ := strings.Cut(op, " ")
+		for _, prefix := range apiPrefixes {
+			if !served[method+" "+prefix+path] {
+				problems = append(problems, "documented but not served: "+method+" "+prefix+path)
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+// checkOpenAPI compares the router with the spec, but both come from
+// apiRoutes, so it cannot notice a route whose Query, Body or Response no
+// longer matches its handler. These tests type-check the handlers and hold
+// each route to what its handler really reads and writes.
+
+// sourceFacts is what a function, or anything it calls in this package,
+// reads from and writes to the request.
+type sourceFacts struct {
+	query     map[string]bool // query parameter names
+	bodies    map[string]bool // types decoded from the request body
+	responses map[string]bool // types written with c.JSON
+}
+
+// sourceIndex is the type-checked package.
+type sourceIndex struct {
+	info   *types.Info
+	funcs  map[*types.Func]*ast.FuncDecl
+	vars   map[*types.Var]ast.Expr // package variable initializers
+	routes []ast.Expr              // apiRoutes elements, in order
+	sinks  map[*types.Func]map[int]string
+	facts  map[*types.Func]*sourceFacts
+}
+
+func loadSourceIndex(t *testing.T) *sourceIndex {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*ast.File
+	for _, f := range pkgs["main"].Files {
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("main", fset, files, info); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := &sourceIndex{
+		info:  info,
+		funcs: map[*types.Func]*ast.FuncDecl{},
+		vars:  map[*types.Var]ast.Expr{},
+		sinks: map[*types.Func]map[int]string{},
+		facts: map[*types.Func]*sourceFacts{},
+	}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				fn := info.Defs[d.Name].(*types.Func)
+				idx.funcs[fn] = d
+				if d.Name.Name == "apiRoutes" && d.Recv == nil {
+					ast.Inspect(d.Body, func(n ast.Node) bool {
+						if lit, ok := n.(*ast.CompositeLit); ok && len(idx.routes) == 0 {
+							if _, ok := lit.Type.(*ast.ArrayType); ok {
+								idx.routes = lit.Elts
+								return false
+							}
+						}
+						return true
+					})
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					vs, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for i, name := range vs.Names {
+						if v, ok := info.Defs[name].(*types.Var); ok && i < len(vs.Values) {
+							idx.vars[v] = vs.Values[i]
+						}
+					}
+				}
+			}
+		}
+	}
+	idx.findSinks()
+	return idx
+}
+
+func isFiberCtx(t types.Type) bool {
+	return t != nil && t.String() == "*github.com/gofiber/fiber/v2.Ctx"
+}
+
+// ctxMethod reports whether call is c.<name>(...) on a *fiber.Ctx.
+func (idx *sourceIndex) ctxMethod(call *ast.CallExpr, names ...string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isFiberCtx(idx.info.TypeOf(sel.X)) {
+		return false
+	}
+	for _, n := range names {
+		if sel.Sel.Name == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *sourceIndex) isBody(e ast.Expr) bool {
+	call, ok := e.(*ast.CallExpr)
+	return ok && idx.ctxMethod(call, "Body")
+}
+
+// callee returns the package function call invokes, if any.
+func (idx *sourceIndex) callee(call *ast.CallExpr) *types.Func {
+	fun := call.Fun
+	if ix, ok := fun.(*ast.IndexExpr); ok {
+		fun = ix.X
+	}
+	var id *ast.Ident
+	switch f := fun.(type) {
+	case *ast.Ident:
+		id = f
+	case *ast.SelectorExpr:
+		id = f.Sel
+	default:
+		return nil
+	}
+	fn, _ := idx.info.Uses[id].(*types.Func)
+	if fn == nil {
+		return nil
+	}
+	if _, ok := idx.funcs[fn.Origin()]; !ok {
+		return nil
+	}
+	return fn.Origin()
+}
+
+// sink classifies a call that decodes the request body into one of its
+// arguments ("body") or writes one as the JSON response ("response").
+func (idx *sourceIndex) sink(call *ast.CallExpr) (int, string, bool) {
+	switch {
+	case idx.ctxMethod(call, "BodyParser"):
+		return 0, "body", true
+	case idx.ctxMethod(call, "JSON"):
+		return 0, "response", true
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Unmarshal" && len(call.Args) == 2 && idx.isBody(call.Args[0]) {
+		return 1, "body", true
+	}
+	if fn := idx.callee(call); fn != nil {
+		for i, kind := range idx.sinks[fn] {
+			return i, kind, true
+		}
+	}
+	return 0, "", false
+}
+
+// findSinks marks the helpers, such as parseBody and sendSpec, that pass a
+// parameter of interface type on to a sink.
+func (idx *sourceIndex) findSinks() {
+	for changed := true; changed; {
+		changed = false
+		for fn, decl := range idx.funcs {
+			if decl.Body == nil || idx.sinks[fn] != nil {
+				continue
+			}
+			params := map[types.Object]int{}
+			i := 0
+			for _, field := range decl.Type.Params.List {
+				for _, name := range field.Names {
+					params[idx.info.Defs[name]] = i
+					i++
+				}
+			}
+			ast.Inspect(decl.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				arg, kind, ok := idx.sink(call)
+				if !ok || arg >= len(call.Args) {
+					return true
+				}
+				id, ok := call.Args[arg].(*ast.Ident)
+				if !ok {
+					return true
+				}
+				if p, ok := params[idx.info.Uses[id]]; ok && types.IsInterface(idx.info.TypeOf(id)) {
+					if idx.sinks[fn] == nil {
+						idx.sinks[fn] = map[int]string{}
+					}
+					idx.sinks[fn][p] = kind
+					changed = true
+				}
+				return true
+			})
+		}
+	}
+}
+
+// shape names a type the way the route table and the spec see it: pointers
+// do not matter, maps are free-form objects and slices keep their element.
+func shape(t types.Type) string {
+	for {
+		p, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		if named.Obj().Pkg() != nil && named.Obj().Pkg().Name() == "main" {
+			name := named.Obj().Name()
+			if args := named.TypeArgs(); args != nil && args.Len() == 1 && name == "Page" {
+				return "paged " + shape(args.At(0))
+			}
+			return name
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		return "object"
+	case *types.Slice:
+		return "[]" + shape(u.Elem())
+	case *types.Interface:
+		return "any"
+	}
+	return t.String()
+}
+
+// routeShape is shape for the values in the route table.
+func routeShape(v interface{}) string {
+	if p, ok := v.(paged); ok {
+		return "paged " + routeShape(p.Item)
+	}
+	return reflectShape(reflect.TypeOf(v))
+}
+
+func reflectShape(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Map:
+		return "object"
+	case t.Kind() == reflect.Slice:
+		return "[]" + reflectShape(t.Elem())
+	case t.Kind() == reflect.Interface:
+		return "any"
+	case t.Name() != "" && t.PkgPath() == reflect.TypeOf(route{}).PkgPath():
+		return t.Name()
+	}
+	return t.String()
+}
+
+// stringsOf returns the string constants e stands for: a constant, or the
+// elements (for maps, the keys) of a literal, possibly through a package
+// variable or append.
+func (idx *sourceIndex) stringsOf(e ast.Expr) []string {
+	if tv, ok := idx.info.Types[e]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+		return []string{constant.StringVal(tv.Value)}
+	}
+	var out []string
+	switch x := e.(type) {
+	case *ast.Ident:
+		if v, ok := idx.info.Uses[x].(*types.Var); ok {
+			if init, ok := idx.vars[v]; ok {
+				return idx.stringsOf(init)
+			}
+		}
+	case *ast.CallExpr:
+		if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "append" {
+			for _, a := range x.Args {
+				out = append(out, idx.stringsOf(a)...)
+			}
+		}
+	case *ast.CompositeLit:
+		for _, elt := range x.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				out = append(out, idx.stringsOf(kv.Key)...)
+			} else {
+				out = append(out, idx.stringsOf(elt)...)
+			}
+		}
+	}
+	return out
+}
+
+// queryNames returns the parameter names a c.Query call may read. A name
+// held in a variable is resolved through the range statement declaring it.
+func (idx *sourceIndex) queryNames(decl *ast.FuncDecl, arg ast.Expr) []string {
+	if names := idx.stringsOf(arg); len(names) > 0 {
+		return names
+	}
+	id, ok := arg.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := idx.info.Uses[id]
+	var names []string
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		rs, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		for _, v := range []ast.Expr{rs.Key, rs.Value} {
+			if vid, ok := v.(*ast.Ident); ok && idx.info.Defs[vid] == obj {
+				names = idx.stringsOf(rs.X)
+				return false
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// factsOf collects the facts of fn and everything it calls.
+func (idx *sourceIndex) factsOf(fn *types.Func) *sourceFacts {
+	if f, ok := idx.facts[fn]; ok {
+		return f
+	}
+	f := &sourceFacts{query: map[string]bool{}, bodies: map[string]bool{}, responses: map[string]bool{}}
+	idx.facts[fn] = f
+	decl := idx.funcs[fn]
+	if decl == nil || decl.Body == nil {
+		return f
+	}
+	generic := decl.Type.TypeParams != nil
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if idx.ctxMethod(call, "Query", "QueryBool", "QueryInt", "QueryFloat") && len(call.Args) > 0 {
+			for _, name := range idx.queryNames(decl, call.Args[0]) {
+				f.query[name] = true
+			}
+		}
+		if arg, kind, ok := idx.sink(call); ok && arg < len(call.Args) && !generic {
+			if t := idx.info.TypeOf(call.Args[arg]); !types.IsInterface(t) && !isTypeParam(t) {
+				if kind == "body" {
+					f.bodies[shape(t)] = true
+				} else {
+					f.responses[shape(t)] = true
+				}
+			}
+		}
+		callee := idx.callee(call)
+		if callee == nil {
+			return true
+		}
+		if callee.Name() == "sendPage" && len(call.Args) > 2 {
+			f.responses["paged "+shape(idx.info.TypeOf(call.Args[2]).(*types.Slice).Elem())] = true
+		}
+		// A helper handed the raw body, such as parseArchive, decodes it
+		// into what it returns.
+		for _, a := range call.Args {
+			if idx.isBody(a) {
+				if sig, ok := callee.Type().(*types.Signature); ok && sig.Results().Len() > 0 {
+					f.bodies[shape(sig.Results().At(0).Type())] = true
+				}
+			}
+		}
+		sub := idx.factsOf(callee)
+		for k := range sub.query {
+			f.query[k] = true
+		}
+		for k := range sub.bodies {
+			f.bodies[k] = true
+		}
+		for k := range sub.responses {
+			f.responses[k] = true
+		}
+		return true
+	})
+	return f
+}
+
+func isTypeParam(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.TypeParam:
+			return true
+		case *types.Pointer:
+			t = x.Elem()
+		case *types.Slice:
+			t = x.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// routeHandler finds the handler function of a route literal. Wrapped
+// handlers such as cached(cacheTasks, getBoardTasks) resolve to the
+// wrapped function.
+func (idx *sourceIndex) routeHandler(elt ast.Expr) (method, path string, fn *types.Func) {
+	lit, ok := elt.(*ast.CompositeLit)
+	if !ok {
+		return "", "", nil
+	}
+	for _, e := range lit.Elts {
+		kv := e.(*ast.KeyValueExpr)
+		switch kv.Key.(*ast.Ident).Name {
+		case "Method":
+			method = idx.stringsOf(kv.Value)[0]
+		case "Path":
+			path = idx.stringsOf(kv.Value)[0]
+		case "Handler":
+			expr := kv.Value
+			if call, ok := expr.(*ast.CallExpr); ok {
+				expr = call.Args[len(call.Args)-1]
+			}
+			if id, ok := expr.(*ast.Ident); ok {
+				fn, _ = idx.info.Uses[id].(*types.Func)
+			}
+		}
+	}
+	return method, path, fn
+}
+
+// rawJSONHandlers write pre-encoded JSON instead of calling c.JSON.
+var rawJSONHandlers = map[string]bool{"getOpenAPI": true}
+
+func TestRoutesMatchHandlers(t *testing.T) {
+	idx := loadSourceIndex(t)
+	routes := apiRoutes()
+	if len(idx.routes) != len(routes) {
+		t.Fatalf("found %d route literals in apiRoutes, want %d", len(idx.routes), len(routes))
+	}
+	for i, r := range routes {
+		method, path, fn := idx.routeHandler(idx.routes[i])
+		if method != r.Method || path != r.Path || fn == nil {
+			t.Fatalf("route literal %d is %s %s, want %s %s with a named handler", i, method, path, r.Method, r.Path)
+		}
+		facts := idx.factsOf(fn)
+		op := r.Method + " " + r.Path
+
+		documented := map[string]bool{}
+		for _, q := range r.Query {
+			documented[q] = true
+			if !facts.query[q] {
+				t.Errorf("%s: documents ?%s, which %s does not read", op, q, fn.Name())
+			}
+		}
+		for q := range facts.query {
+			if !documented[q] {
+				t.Errorf("%s: %s reads ?%s, which the route does not document", op, fn.Name(), q)
+			}
+		}
+
+		// Free-form objects are status payloads or, for bodies, a second
+		// decode to see which fields were sent.
+		body := ""
+		if r.Body != nil {
+			body = routeShape(r.Body)
+			if !facts.bodies[body] {
+				t.Errorf("%s: documents a %s body, but %s decodes %v", op, body, fn.Name(), keys(facts.bodies))
+			}
+		}
+		for b := range facts.bodies {
+			if b != body && b != "object" {
+				t.Errorf("%s: %s decodes a %s body, but the route documents %q", op, fn.Name(), b, body)
+			}
+		}
+
+		resp := ""
+		if r.Response != nil && r.Produces == "" {
+			resp = routeShape(r.Response)
+			if !facts.responses[resp] && !rawJSONHandlers[fn.Name()] {
+				t.Errorf("%s: documents a %s response, but %s writes %v", op, resp, fn.Name(), keys(facts.responses))
+			}
+		}
+		for w := range facts.responses {
+			if w != resp && w != "object" {
+				t.Errorf("%s: %s writes a %s response, but the route documents %q", op, fn.Name(), w, resp)
+			}
+		}
+	}
+}
+
+func keys(m map[string]bool) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TestServedSpec boots the app and checks the served document against the
+// router.
+func TestServedSpec(t *testing.T) {
+	app := newApp()
+	if problems := checkOpenAPI(app); len(problems) > 0 {
+		t.Fatalf("spec and router disagree:\n%s", strings.Join(problems, "\n"))
+	}
+	for _, prefix := range apiPrefixes {
+		resp, err := app.Test(httptest.NewRequest("GET", prefix+"/openapi.json", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Paths map[string]map[string]json.RawMessage `json:"paths"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("GET %s/openapi.json: %d %v", prefix, resp.StatusCode, err)
+		}
+		n := 0
+		for _, ops := range doc.Paths {
+			n += len(ops)
+		}
+		if n != len(apiRoutes()) {
+			t.Errorf("GET %s/openapi.json has %d operations, want %d", prefix, n, len(apiRoutes()))
+		}
+	}
+}
+
+// TestTaskFilterParams checks that each documented task filter narrows the
+// query and that typed ones reject malformed values.
+func TestTaskFilterParams(t *testing.T) {
+	valid := map[string]string{
+		"list_id": "todo", "assignee": "alice", "priority": "high", "due_before": "2024-01-02T00:00:00Z",
+		"due_after": "2024-01-02T00:00:00Z", "label": "bug", "blocked": "true", "overdue": "true",
+	}
+	invalid := map[string]string{"priority": "soon", "due_before": "tomorrow", "due_after": "yesterday"}
+
+	app := fiber.New()
+	filter := func(query string) ([]string, error) {
+		fctx := &fasthttp.RequestCtx{}
+		fctx.Request.SetRequestURI("/?" + query)
+		c := app.AcquireCtx(fctx)
+		defer app.ReleaseCtx(c)
+		where, _, err := taskFilter(c, nil, nil)
+		return where, err
+	}
+	for _, name := range taskFilterQuery {
+		v, ok := valid[name]
+		if !ok {
+			t.Errorf("no sample value for ?%s", name)
+			continue
+		}
+		where, err := filter(url.Values{name: {v}}.Encode())
+		if err != nil || len(where) != 1 {
+			t.Errorf("?%s=%s: got %d clauses, %v; want one", name, v, len(where), err)
+		}
+		if bad, ok := invalid[name]; ok {
+			if _, err := filter(url.Values{name: {bad}}.Encode()); err == nil {
+				t.Errorf("?%s=%s: accepted", name, bad)
+			}
+		}
+	}
+}
+
+// TestDocumentedBodyFields decodes each documented body property into the
+// route's body type, as JSON and, where the spec offers it, YAML.
+func TestDocumentedBodyFields(t *testing.T) {
+	raw, err := json.Marshal(buildOpenAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths      map[string]map[string]specOperation `json:"paths"`
+		Components struct {
+			Schemas map[string]specSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range apiRoutes() {
+		if r.Body == nil {
+			continue
+		}
+		path, _ := openAPIPath(r.Path)
+		op := doc.Paths[path][strings.ToLower(r.Method)]
+		for mime, content := range op.RequestBody.Content {
+			name := strings.TrimPrefix(content.Schema.Ref, "#/components/schemas/")
+			schema, ok := doc.Components.Schemas[name]
+			if !ok {
+				t.Errorf("%s %s: %s body is not a component", r.Method, r.Path, mime)
+				continue
+			}
+			for prop, ps := range schema.Properties {
+				sample := ps.sample()
+				if sample == nil {
+					continue
+				}
+				v := reflect.New(reflect.TypeOf(r.Body))
+				var err error
+				if mime == "application/yaml" {
+					var data []byte
+					if data, err = yaml.Marshal(map[string]interface{}{prop: sample}); err == nil {
+						err = yaml.Unmarshal(data, v.Interface())
+					}
+				} else {
+					data, _ := json.Marshal(map[string]interface{}{prop: sample})
+					err = json.Unmarshal(data, v.Interface())
+				}
+				if err != nil || v.Elem().IsZero() {
+					t.Errorf("%s %s: %s property %q is not decoded into %s (%v)", r.Method, r.Path, mime, prop, name, err)
+				}
+			}
+		}
+	}
+}
+
+type specOperation struct {
+	RequestBody struct {
+		Content map[string]struct {
+			Schema struct {
+				Ref string `json:"$ref"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type specSchema struct {
+	Properties map[string]specProperty `json:"properties"`
+}
+
+type specProperty struct {
+	Type   json.RawMessage `json:"type"`
+	Format string          `json:"format"`
+}
+
+// sample returns a non-zero value for a property, or nil when its schema
+// has no type, as for references.
+func (p specProperty) sample() interface{} {
+	var typ string
+	if err := json.Unmarshal(p.Type, &typ); err != nil {
+		var types []string
+		if json.Unmarshal(p.Type, &types) != nil || len(types) == 0 {
+			return nil
+		}
+		typ = types[0]
+	}
+	switch typ {
+	case "string":
+		if p.Format == "date-time" {
+			return "2024-01-02T15:04:05Z"
+		}
+		return "x"
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	case "array":
+		return []interface{}{}
+	case "object":
+		return map[string]interface{}{}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Collection endpoints page with an opaque cursor. A request that sends
+// ?limit= or ?cursor= gets a Page envelope; one that sends neither keeps the
+// old bare array so existing clients are unaffected.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Page is the response envelope of a paged collection. NextCursor is null on
+// the last page.
+type Page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// pageCursor is the decoded form of a cursor: the ordering key of the last
+// row returned. Value is the sort value (absent when it was null); rows are
+// tie-broken by ID or, for text keys, Key. Sort records the sort it was
+// issued for so it cannot be replayed against another ordering.
+type pageCursor struct {
+	Sort  string          `json:"s,omitempty"`
+	Value json.RawMessage `json:"v,omitempty"`
+	ID    int             `json:"i,omitempty"`
+	Key   string          `json:"k,omitempty"`
+}
+
+// pageRequest is a parsed ?limit=&cursor= pair. Paged is false for clients
+// that sent neither; Capped marks an unpaged request limited by capUnpaged.
+type pageRequest struct {
+	Paged  bool
+	Capped bool
+	Limit  int
+	Cursor *pageCursor
+}
+
+// capUnpaged limits an unpaged request to max rows. If more rows match,
+// sendPage answers with a page and next_cursor rather than a cut-off array.
+func (p *pageRequest) capUnpaged(max int) {
+	if !p.Paged {
+		p.Capped = true
+		p.Limit = max
+	}
+}
+
+// cursorAt builds the cursor for a row; value nil means a null sort value.
+func cursorAt(sort string, value interface{}, id int, key string) pageCursor {
+	cur := pageCursor{Sort: sort, ID: id, Key: key}
+	if value != nil {
+		cur.Value, _ = json.Marshal(value)
+	}
+	return cur
+}
+
+func encodeCursor(cur pageCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	cur := new(pageCursor)
+	if err := json.Unmarshal(b, cur); err != nil {
+		return nil, err
+	}
+	return cur, nil
+}
+
+// parsePage reads ?limit= and ?cursor= for a collection ordered by sort.
+func parsePage(c *fiber.Ctx, sort string) (pageRequest, error) {
+	var p pageRequest
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, invalidField("limit", "must be a positive integer")
+		}
+		p.Paged, p.Limit = true, min(n, maxPageSize)
+	}
+	if v := c.Query("cursor"); v != "" {
+		cur, err := decodeCursor(v)
+		if err != nil {
+			return p, fiber.NewError(400, "Invalid cursor")
+		}
+		if cur.Sort != sort {
+			return p, fiber.NewError(400, "Cursor was issued for a different sort")
+		}
+		p.Paged, p.Cursor = true, cur
+	}
+	if p.Paged && p.Limit == 0 {
+		p.Limit = defaultPageSize
+	}
+	return p, nil
+}
+
+// after decodes the cursor's sort value into dst and reports whether the
+// request continues from a cursor at all. Cursor values with a null sort
+// value leave dst untouched.
+func (p pageRequest) after(dst interface{}) (bool, error) {
+	if p.Cursor == nil {
+		return false, nil
+	}
+	if dst != nil && p.Cursor.Value != nil {
+		if err := json.Unmarshal(p.Cursor.Value, dst); err != nil {
+			return false, fiber.NewError(400, "Invalid cursor")
+		}
+	}
+	return true, nil
+}
+
+// limitSQL fetches one row more than the page so sendPage can tell whether
+// another page follows. Unpaged requests are limited only by capUnpaged.
+func (p pageRequest) limitSQL() string {
+	if p.Paged || p.Capped {
+		return " LIMIT " + strconv.Itoa(p.Limit+1)
+	}
+	return ""
+}
+
+// sendPage writes items as a Page, trimming the look-ahead row and deriving
+// next_cursor from the last row kept, or as a bare array when unpaged and
+// not cut short by capUnpaged.
+func sendPage[T any](c *fiber.Ctx, p pageRequest, items []T, key func(T) pageCursor) error {
+	if items == nil {
+		items = []T{}
+	}
+	if !p.Paged && !(p.Capped && len(items) > p.Limit) {
+		return c.JSON(items)
+	}
+	page := Page[T]{Data: items}
+	if len(items) > p.Limit {
+		page.Data = items[:p.Limit]
+		next := encodeCursor(key(page.Data[p.Limit-1]))
+		page.NextCursor = &next
+	}
+	return c.JSON(page)
+}
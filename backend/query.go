@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Task query language
+//
+// A query is a whitespace-separated list of terms that must all match:
+//
+//	assignee:kodinger list:doing label:bug due<7d priority>=high "free text"
+//
+// A term is either free text (a word or "quoted phrase", matched against
+// title and description) or field, operator and value. Operators are ':'
+// (equals; comma-separated values mean any of them), '!=', '<', '<=', '>'
+// and '>='. Values may be quoted. A leading '-' negates a term.
+//
+// Fields:
+//
+//	assignee  member id, "me" (the caller) or "none"
+//	list      list id
+//	board     board id
+//	label     label name (case-insensitive)
+//	priority  low, medium, high, urgent; comparable
+//	due       date (YYYY-MM-DD), RFC 3339 time, today, or an offset from
+//	start     now such as 7d, -2w, 12h; "none"; comparable
+//	estimate  number or "none"; comparable
+//	parent    task id or "none"
+//	is        open, done, overdue, blocked, unassigned
+//	cf.<key>  custom field value; comparable
+
+// queryTerm is one parsed term of a task query.
+type queryTerm struct {
+	Negate bool
+	Field  string // "" for free text
+	Op     string
+	Value  string
+}
+
+var queryFieldPattern = regexp.MustCompile(`^([a-z][a-z0-9_.]*)(:|!=|<=|>=|<|>)(.*)$`)
+
+// tokenizeQuery splits q on whitespace outside double quotes.
+func tokenizeQuery(q string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+func parseQuery(q string) ([]queryTerm, error) {
+	tokens, err := tokenizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]queryTerm, 0, len(tokens))
+	for _, tok := range tokens {
+		var t queryTerm
+		if strings.HasPrefix(tok, "-") && len(tok) > 1 {
+			t.Negate, tok = true, tok[1:]
+		}
+		if m := queryFieldPattern.FindStringSubmatch(tok); m != nil {
+			t.Field, t.Op, t.Value = m[1], m[2], strings.Trim(m[3], `"`)
+			if t.Value == "" {
+				return nil, fmt.Errorf("%s%s needs a value", t.Field, t.Op)
+			}
+		} else {
+			t.Value = strings.Trim(tok, `"`)
+			if t.Value == "" {
+				continue
+			}
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+var sqlComparators = map[string]string{":": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+var relativeTimePattern = regexp.MustCompile(`^(-?\d+)([hdw])$`)
+
+// parseQueryTime resolves a due/start value. Values naming a whole day
+// return the start of that day (UTC) and wholeDay=true.
+func parseQueryTime(v string, now time.Time) (t time.Time, wholeDay bool, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch v {
+	case "today":
+		return today, true, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true, nil
+	}
+	if m := relativeTimePattern.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+		return now.Add(time.Duration(n) * unit), false, nil
+	}
+	if d, err := time.Parse("2006-01-02", v); err == nil {
+		return d, true, nil
+	}
+	if ts, err := time.Parse(time.RFC3339, v); err == nil {
+		return ts, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", v)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// compileQuery turns q into WHERE clauses over tasks aliased t, appending
+// values to args as placeholders. caller resolves assignee:me.
+func compileQuery(q, caller string, where []string, args []interface{}) ([]string, []interface{}, error) {
+	terms, err := parseQuery(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	now := time.Now().UTC()
+	for _, t := range terms {
+		clause, err := compileTerm(t, caller, now, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.Negate {
+			clause = "NOT COALESCE((" + clause + "), FALSE)"
+		}
+		where = append(where, clause)
+	}
+	return where, args, nil
+}
+
+func compileTerm(t queryTerm, caller string, now time.Time, arg func(interface{}) string) (string, error) {
+	values := strings.Split(t.Value, ",")
+	equality := func(column string) (string, error) {
+		switch t.Op {
+		case ":":
+			return column + " = ANY(" + arg(values) + ")", nil
+		case "!=":
+			return "NOT (" + column + " = ANY(" + arg(values) + "))", nil
+		}
+		return "", fmt.Errorf("%s only supports ':' and '!='", t.Field)
+	}
+
+	switch {
+	case t.Field == "":
+		p := arg("%" + escapeLike(t.Value) + "%")
+		return "(t.title ILIKE " + p + " OR t.description ILIKE " + p + ")", nil
+
+	case t.Field == "assignee":
+		if t.Value == "none" {
+			return "t.assignee_id IS NULL", nil
+		}
+		for i, v := range values {
+			if v == "me" {
+				if caller == "" {
+					return "", fmt.Errorf("assignee:me needs an API token")
+				}
+				values[i] = caller
+			}
+		}
+		return equality("t.assignee_id")
+
+	case t.Field == "list":
+		return equality("t.list_id")
+
+	case t.Field == "board":
+		return equality("t.board_id::text")
+
+	case t.Field == "label":
+		if t.Op != ":" {
+			return "", fmt.Errorf("label only supports ':'")
+		}
+		for i := range values {
+			values[i] = strings.ToLower(values[i])
+		}
+		return "EXISTS (SELECT 1 FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE tl.task_id = t.id AND lower(l.name) = ANY(" + arg(values) + "))", nil
+
+	case t.Field == "priority":
+		ranks := make([]int, len(values))
+		for i, v := range values {
+			if ranks[i] = priorityRank[Priority(v)]; ranks[i] == 0 {
+				return "", fmt.Errorf("invalid priority %q", v)
+			}
+		}
+		if t.Op == ":" || t.Op == "!=" {
+			return equality("t.priority")
+		}
+		if len(ranks) != 1 {
+			return "", fmt.Errorf("priority%s takes a single value", t.Op)
+		}
+		return priorityRankSQL + " " + sqlComparators[t.Op] + " " + arg(ranks[0]), nil
+
+	case t.Field == "due" || t.Field == "start":
+		column := map[string]string{"due": "t.due_date", "start": "t.start_date"}[t.Field]
+		if t.Value == "none" {
+			return column + " IS NULL", nil
+		}
+		ts, wholeDay, err := parseQueryTime(t.Value, now)
+		if err != nil {
+			return "", err
+		}
+		if t.Op == ":" {
+			if !wholeDay {
+				return "", fmt.Errorf("%s: needs a day (YYYY-MM-DD, today); use < or > for times", t.Field)
+			}
+			return "(" + column + " >= " + arg(ts) + " AND " + column + " < " + arg(ts.AddDate(0, 0, 1)) + ")", nil
+		}
+		if t.Op == "!=" {
+			return "", fmt.Errorf("%s does not support '!='", t.Field)
+		}
+		return column + " " + sqlComparators[t.Op] + " " + arg(ts), nil
+
+	case t.Field == "estimate":
+		if t.Value == "none" {
+			return "t.estimate IS NULL", nil
+		}
+		n, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			return "", fmt.Errorf("estimate must be a number")
+		}
+		return "t.estimate " + sqlComparators[t.Op] + " " + arg(n), nil
+
+	case t.Field == "parent":
+		if t.Value == "none" {
+			return "t.parent_id IS NULL", nil
+		}
+		id, err := strconv.Atoi(t.Value)
+		if err != nil || t.Op != ":" {
+			return "", fmt.Errorf("parent takes a task id")
+		}
+		return "t.parent_id = " + arg(id), nil
+
+	case t.Field == "is":
+		if t.Op != ":" {
+			return "", fmt.Errorf("is only supports ':'")
+		}
+		switch t.Value {
+		case "open":
+			return "t.completed_at IS NULL", nil
+		case "done":
+			return "t.completed_at IS NOT NULL", nil
+		case "overdue":
+			return "(t.due_date < CURRENT_TIMESTAMP AND t.completed_at IS NULL)", nil
+		case "blocked":
+			return openBlockersSQL, nil
+		case "unassigned":
+			return "t.assignee_id IS NULL", nil
+		}
+		return "", fmt.Errorf("is: must be open, done, overdue, blocked or unassigned")
+
+	case strings.HasPrefix(t.Field, "cf."):
+		key := strings.TrimPrefix(t.Field, "cf.")
+		if !fieldKeyPattern.MatchString(key) {
+			return "", fmt.Errorf("invalid custom field %q", key)
+		}
+		k := arg(key)
+		switch t.Op {
+		case ":", "!=":
+			v := arg(t.Value)
+			clause := "(t.custom_fields->>" + k + " = " + v + " OR t.custom_fields->" + k + " @> jsonb_build_array(" + v + "::text))"
+			if t.Op == "!=" {
+				clause = "NOT COALESCE(" + clause + ", FALSE)"
+			}
+			return clause, nil
+		}
+		if n, err := strconv.ParseFloat(t.Value, 64); err == nil {
+			return "(CASE WHEN jsonb_typeof(t.custom_fields->" + k + ") = 'number' THEN (t.custom_fields->>" + k + ")::numeric END) " + sqlComparators[t.Op] + " " + arg(n), nil
+		}
+		return "t.custom_fields->>" + k + " " + sqlComparators[t.Op] + " " + arg(t.Value), nil
+	}
+	return "", fmt.Errorf("unknown field %q", t.Field)
+}
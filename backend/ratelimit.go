@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Requests are rate limited per caller (token member, else client IP) and
+// route class with a token bucket kept in Redis, so every replica draws from
+// the same bucket. Each class allows RATE_LIMIT_<CLASS> requests per minute
+// (0 disables it) with bursts up to that size. AI-backed calls also count
+// against a daily per-member quota, AI_DAILY_QUOTA.
+const (
+	rateRead  = "read"
+	rateWrite = "write"
+	rateAI    = "ai"
+
+	rateLimitPrefix = "moziboard:ratelimit:"
+	aiQuotaPrefix   = "moziboard:quota:ai:"
+)
+
+var rateLimitDefaults = map[string]int{rateRead: 600, rateWrite: 120, rateAI: 20}
+
+const defaultAIDailyQuota = 500
+
+// aiRoutes embed their query on every call.
+var aiRoutes = map[string]bool{"/api/search": true, "/api/docs/search": true}
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n >= 0 {
+		return n
+	}
+	return def
+}
+
+func rateLimitPerMinute(class string) int {
+	return envInt("RATE_LIMIT_"+map[string]string{rateRead: "READ", rateWrite: "WRITE", rateAI: "AI"}[class], rateLimitDefaults[class])
+}
+
+func routeClass(c *fiber.Ctx) string {
+	switch {
+	case aiRoutes[unversionedPath(c.Path())]:
+		return rateAI
+	case c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead:
+		return rateRead
+	}
+	return rateWrite
+}
+
+func rateLimitSubject(c *fiber.Ctx) string {
+	if id := callerID(c); id != "" {
+		return "member:" + id
+	}
+	return "ip:" + c.IP()
+}
+
+// tokenBucketScript refills the bucket for the time since it was last used
+// and takes one token if there is one. It returns whether the request is
+// allowed and the tokens left. Time comes from Redis so replicas agree.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or capacity
+local ts = tonumber(b[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, tostring(tokens)}
+`)
+
+// rateLimit is the middleware enforcing the per-class buckets. It sets the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers (reset is
+// seconds until the bucket is full again) and answers 429 with Retry-After
+// once the bucket is empty. Redis errors let the request through.
+func rateLimit(c *fiber.Ctx) error {
+	// MCP requests are counted per tool call, when they reach the API route.
+	if !redisEnabled() || c.Method() == fiber.MethodOptions || unversionedPath(c.Path()) == "/api/health" || c.Path() == mcpPath {
+		return c.Next()
+	}
+	class := routeClass(c)
+	perMinute := rateLimitPerMinute(class)
+	if perMinute == 0 {
+		return c.Next()
+	}
+	rate := float64(perMinute) / 60000 // tokens per millisecond
+	res, err := tokenBucketScript.Run(context.Background(), rdb,
+		[]string{rateLimitPrefix + class + ":" + rateLimitSubject(c)}, perMinute, rate).Slice()
+	if err != nil {
+		log.Printf("Rate limit err: %v", err)
+		return c.Next()
+	}
+	allowed := res[0].(int64) == 1
+	tokens, _ := strconv.ParseFloat(res[1].(string), 64)
+
+	c.Set("RateLimit-Policy", strconv.Itoa(perMinute)+";w=60")
+	c.Set("RateLimit-Limit", strconv.Itoa(perMinute))
+	c.Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+	c.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(perMinute)-tokens)/rate/1000))))
+	if !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil((1-tokens)/rate/1000))))
+		return fiber.NewError(429, "Rate limit exceeded for "+class+" requests")
+	}
+	if class == rateAI {
+		if !reserveAIQuota(rateLimitSubject(c), 1) {
+			return fiber.NewError(429, "Daily AI quota exceeded")
+		}
+	}
+	return c.Next()
+}
+
+// reserveAIQuota takes n embedding/LLM calls from subject's quota for the
+// current UTC day and reports whether they fit. Calls are always allowed
+// when Redis is unavailable.
+func reserveAIQuota(subject string, n int) bool {
+	quota := envInt("AI_DAILY_QUOTA", defaultAIDailyQuota)
+	if !redisEnabled() || quota == 0 || n == 0 {
+		return true
+	}
+	ctx := context.Background()
+	key := aiQuotaPrefix + subject + ":" + time.Now().UTC().Format("2006-01-02")
+	used, err := rdb.IncrBy(ctx, key, int64(n)).Result()
+	if err != nil {
+		log.Printf("AI quota err: %v", err)
+		return true
+	}
+	if used == int64(n) {
+		rdb.Expire(ctx, key, 48*time.Hour)
+	}
+	if used > int64(quota) {
+		rdb.DecrBy(ctx, key, int64(n))
+		return false
+	}
+	return true
+}
+
+// embedFor reports whether the caller of c may spend n embedding calls on
+// background indexing. Over quota, the content is saved but not indexed.
+func embedFor(c *fiber.Ctx, n int) bool {
+	if reserveAIQuota(rateLimitSubject(c), n) {
+		return true
+	}
+	log.Printf("AI quota exhausted for %s; skipping %d embedding(s)", rateLimitSubject(c), n)
+	return false
+}
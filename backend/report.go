@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// reportLinkLabels phrase a task link from either end.
+var reportLinkLabels = map[string][2]string{
+	LinkBlocks:     {"blocks", "blocked by"},
+	LinkRelatesTo:  {"relates to", "relates to"},
+	LinkDuplicates: {"duplicates", "duplicated by"},
+}
+
+// reportLink is a task link as seen from one of its tasks.
+type reportLink struct {
+	Label  string
+	Target int
+	Title  string
+	Done   bool
+}
+
+// loadReportLinks returns the links of tasks, from each task's side.
+func loadReportLinks(tasks []Task) (map[int][]reportLink, error) {
+	ids := make([]int, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	rows, err := db.Query(context.Background(), `
+		SELECT l.task_id, l.target_id, l.type, s.title, s.completed_at IS NOT NULL, d.title, d.completed_at IS NOT NULL
+		FROM task_links l JOIN tasks s ON s.id = l.task_id JOIN tasks d ON d.id = l.target_id
+		WHERE l.task_id = ANY($1) OR l.target_id = ANY($1) ORDER BY l.id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	links := map[int][]reportLink{}
+	for rows.Next() {
+		var from, to int
+		var typ, fromTitle, toTitle string
+		var fromDone, toDone bool
+		if err := rows.Scan(&from, &to, &typ, &fromTitle, &fromDone, &toTitle, &toDone); err != nil {
+			return nil, err
+		}
+		labels, ok := reportLinkLabels[typ]
+		if !ok {
+			labels = [2]string{typ, typ}
+		}
+		if want[from] {
+			links[from] = append(links[from], reportLink{Label: labels[0], Target: to, Title: toTitle, Done: toDone})
+		}
+		if want[to] {
+			links[to] = append(links[to], reportLink{Label: labels[1], Target: from, Title: fromTitle, Done: fromDone})
+		}
+	}
+	return links, rows.Err()
+}
+
+// writeReportTask renders one task as a Markdown list item.
+func writeReportTask(w *bufio.Writer, t *Task, members map[string]string, links []reportLink) {
+	box := " "
+	if t.CompletedAt != nil {
+		box = "x"
+	}
+	fmt.Fprintf(w, "- [%s] **#%d %s**", box, t.ID, markdownInline(t.Title))
+	var meta []string
+	if t.AssigneeID != nil {
+		name := members[*t.AssigneeID]
+		if name == "" {
+			name = *t.AssigneeID
+		}
+		meta = append(meta, "@"+name)
+	} else {
+		meta = append(meta, "unassigned")
+	}
+	if t.Priority != "" && t.Priority != PriorityMedium {
+		meta = append(meta, string(t.Priority))
+	}
+	if t.DueDate != nil {
+		due := "due " + t.DueDate.Format("2006-01-02")
+		if t.CompletedAt == nil && t.DueDate.Before(time.Now()) {
+			due += " (overdue)"
+		}
+		meta = append(meta, due)
+	}
+	if t.Progress != nil && (t.Progress.ChecklistTotal > 0 || t.Progress.SubtasksTotal > 0) {
+		meta = append(meta, strconv.Itoa(t.Progress.Percent)+"% done")
+	}
+	for _, l := range t.Labels {
+		meta = append(meta, "`"+l.Name+"`")
+	}
+	fmt.Fprintf(w, " · %s\n", strings.Join(meta, " · "))
+	for _, l := range links {
+		done := ""
+		if l.Done {
+			done = " ✓"
+		}
+		fmt.Fprintf(w, "  - %s #%d %s%s\n", l.Label, l.Target, markdownInline(l.Title), done)
+	}
+}
+
+// markdownInline keeps a title on one line and stops it from starting
+// emphasis or links.
+func markdownInline(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "`", "\\`").Replace(s)
+}
+
+// exportBoardReport handles GET /api/boards/:id/report.md: a Markdown
+// status report with one section per list, in list order. It accepts the
+// same filters, query and sort as getBoardTasks, and streams one list
+// section at a time.
+func exportBoardReport(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	board, err := loadBoard(boardID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	tq, err := compileTaskQuery(c, []string{"t.board_id = $1"}, []interface{}{boardID}, c.Query("q"), c.Query("sort"))
+	if err != nil {
+		return err
+	}
+	lists, err := loadLists(db, boardID)
+	if err != nil {
+		return err
+	}
+	_, members, err := exportLookups(boardID)
+	if err != nil {
+		return err
+	}
+	filtered := c.Query("q") != ""
+	for _, name := range taskFilterQuery {
+		filtered = filtered || c.Query(name) != ""
+	}
+
+	c.Set("Content-Type", "text/markdown; charset=utf-8")
+	c.Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.md"`, slugify(board.Title)))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "# %s — status report\n\n", board.Title)
+		note := "Generated " + time.Now().UTC().Format("2006-01-02 15:04") + " UTC"
+		if filtered {
+			note += " · filtered"
+		}
+		fmt.Fprintf(w, "_%s_\n\n", note)
+		if board.Description != "" {
+			fmt.Fprintf(w, "%s\n\n", board.Description)
+		}
+		for _, l := range lists {
+			if err := writeReportList(w, tq.and("t.list_id = %s", l.ID), l, members); err != nil {
+				log.Printf("Report export of board %s: %v", boardID, err)
+				break
+			}
+			w.Flush()
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+func writeReportList(w *bufio.Writer, tq taskQuery, l List, members map[string]string) error {
+	var count int
+	if err := db.QueryRow(context.Background(),
+		"SELECT COUNT(*) FROM tasks t WHERE "+strings.Join(tq.where, " AND "), tq.args...).Scan(&count); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "## %s (%d)\n\n", l.Title, count)
+	if count == 0 {
+		w.WriteString("_No tasks._\n\n")
+		return nil
+	}
+	first, err := tq.fetch(pageRequest{Paged: true, Limit: exportPageSize})
+	if err != nil {
+		return err
+	}
+	err = tq.pages(first, func(tasks []Task) error {
+		links, err := loadReportLinks(tasks)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			writeReportTask(w, &tasks[i], members, links[tasks[i].ID])
+		}
+		return w.Flush()
+	})
+	w.WriteString("\n")
+	return err
+}
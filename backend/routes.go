@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiVersion is the current API version. Every route is served under
+// /api/v1 and, for existing clients, under the unversioned /api alias.
+const apiVersion = "v1"
+
+var apiPrefixes = []string{"/api/" + apiVersion, "/api"}
+
+// route describes one API endpoint. The table in apiRoutes is the single
+// source for both the router and the OpenAPI document.
+type route struct {
+	Method   string
+	Path     string // fiber syntax, relative to the API prefix
+	Handler  fiber.Handler
+	Tag      string
+	Summary  string
+	Query    []string    // query parameter names
+	Body     interface{} // request body type, nil when there is none
+	Response interface{} // response body type, nil for an empty 200
+	Status   int         // success status, 200 when zero
+	Produces string      // content type when the response is not JSON
+}
+
+// paged marks a collection response that returns a bare array by default
+// and a Page envelope when ?limit= or ?cursor= is given.
+type paged struct{ Item interface{} }
+
+var (
+	pageQuery       = []string{"limit", "cursor"}
+	taskFilterQuery = []string{"list_id", "assignee", "priority", "due_before", "due_after", "label", "blocked", "overdue"}
+	taskListQuery   = append(append([]string{"q", "sort"}, taskFilterQuery...), pageQuery...)
+	specFormatQuery = []string{"format"}
+)
+
+func apiRoutes() []route {
+	return []route{
+		{Method: "GET", Path: "/health", Handler: getHealth, Tag: "meta", Summary: "Health check", Response: map[string]string{}},
+		{Method: "GET", Path: "/openapi.json", Handler: getOpenAPI, Tag: "meta", Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+		{Method: "GET", Path: "/cache/stats", Handler: getCacheStats, Tag: "meta", Summary: "Read cache hit and miss counts", Response: map[string]interface{}{}},
+
+		{Method: "GET", Path: "/boards", Handler: getBoards, Tag: "boards", Summary: "List boards", Query: append([]string{"include_archived"}, pageQuery...), Response: paged{Board{}}},
+		{Method: "POST", Path: "/boards", Handler: createBoard, Tag: "boards", Summary: "Create a board, optionally from ?template=", Query: []string{"template"}, Body: Board{}, Response: Board{}},
+		{Method: "GET", Path: "/boards/:id", Handler: getBoard, Tag: "boards", Summary: "Get a board", Response: Board{}},
+		{Method: "PATCH", Path: "/boards/:id", Handler: updateBoard, Tag: "boards", Summary: "Update a board's title, description or settings", Body: BoardPatch{}, Response: Board{}},
+		{Method: "DELETE", Path: "/boards/:id", Handler: deleteBoard, Tag: "boards", Summary: "Delete a board; without ?confirm= returns a confirmation token (202)", Query: []string{"confirm"}},
+		{Method: "POST", Path: "/boards/:id/archive", Handler: archiveBoard, Tag: "boards", Summary: "Archive a board", Response: Board{}},
+		{Method: "POST", Path: "/boards/:id/unarchive", Handler: unarchiveBoard, Tag: "boards", Summary: "Unarchive a board", Response: Board{}},
+		{Method: "POST", Path: "/boards/:id/clone", Handler: cloneBoard, Tag: "boards", Summary: "Clone a board", Body: CloneBoardReq{}, Response: Board{}},
+		{Method: "GET", Path: "/boards/:id/lists", Handler: getBoardLists, Tag: "boards", Summary: "List a board's lists", Response: []List{}},
+		{Method: "GET", Path: "/boards/:id/snapshot", Handler: cached(cacheSnapshot, getBoardSnapshot), Tag: "boards", Summary: "Board, lists, tasks, members and docs in one response", Response: BoardSnapshot{}},
+		{Method: "POST", Path: "/boards/:id/template", Handler: createTemplateFromBoard, Tag: "templates", Summary: "Save a board as a template", Body: TemplateFromBoardReq{}, Response: BoardTemplate{}, Status: 201},
+		{Method: "GET", Path: "/boards/:id/dependencies", Handler: getDependencyGraph, Tag: "links", Summary: "Task dependency graph", Response: DependencyGraph{}},
+		{Method: "GET", Path: "/boards/:id/schedule", Handler: getBoardSchedule, Tag: "boards", Summary: "Critical-path schedule", Query: []string{"start", "hours_per_day", "days_per_point", "default_days"}, Response: Schedule{}},
+		{Method: "GET", Path: "/boards/:id/fields", Handler: getBoardFields, Tag: "fields", Summary: "List custom fields", Response: []CustomField{}},
+		{Method: "POST", Path: "/boards/:id/fields", Handler: createField, Tag: "fields", Summary: "Create a custom field", Body: CustomFieldSpec{}, Response: CustomField{}, Status: 201},
+		{Method: "PUT", Path: "/fields/:id", Handler: updateField, Tag: "fields", Summary: "Update a custom field", Body: CustomFieldSpec{}, Response: CustomField{}},
+		{Method: "DELETE", Path: "/fields/:id", Handler: deleteField, Tag: "fields", Summary: "Delete a custom field"},
+		{Method: "GET", Path: "/boards/:id/labels", Handler: getBoardLabels, Tag: "labels", Summary: "List labels", Response: []Label{}},
+		{Method: "POST", Path: "/boards/:id/labels", Handler: createLabel, Tag: "labels", Summary: "Create a label", Body: LabelSpec{}, Response: Label{}, Status: 201},
+		{Method: "PUT", Path: "/labels/:id", Handler: updateLabel, Tag: "labels", Summary: "Update a label", Body: LabelSpec{}, Response: Label{}},
+		{Method: "DELETE", Path: "/labels/:id", Handler: deleteLabel, Tag: "labels", Summary: "Delete a label"},
+
+		// Board-as-code (JSON, or YAML via Content-Type / ?format=yaml)
+		{Method: "POST", Path: "/boards/apply", Handler: applyBoard, Tag: "spec", Summary: "Create a board from a spec", Query: []string{"dry_run", "format"}, Body: BoardSpec{}, Response: ApplyPlan{}},
+		{Method: "GET", Path: "/boards/:id/spec", Handler: getBoardSpec, Tag: "spec", Summary: "Export a board as a spec", Query: specFormatQuery, Response: BoardSpec{}},
+		{Method: "POST", Path: "/boards/:id/apply", Handler: applyBoard, Tag: "spec", Summary: "Reconcile a board with a spec", Query: []string{"dry_run", "format"}, Body: BoardSpec{}, Response: ApplyPlan{}},
+
+		// Portable board archives (JSON, or zip with Markdown documents)
+		{Method: "GET", Path: "/boards/:id/export", Handler: exportBoard, Tag: "archive", Summary: "Export a board with its tasks, comments, activities and documents", Query: []string{"format"}, Response: BoardArchive{}},
+		{Method: "POST", Path: "/boards/import", Handler: importBoard, Tag: "archive", Summary: "Create a board from an export archive (JSON or zip body)", Query: []string{"title", "members", "reembed"}, Body: BoardArchive{}, Response: ArchiveImportResult{}, Status: 201},
+
+		// Board templates (JSON, or YAML via Content-Type / ?format=yaml)
+		{Method: "GET", Path: "/templates", Handler: getTemplates, Tag: "templates", Summary: "List templates", Query: pageQuery, Response: paged{TemplateSummary{}}},
+		{Method: "POST", Path: "/templates", Handler: createTemplate, Tag: "templates", Summary: "Create a template", Body: BoardTemplate{}, Response: BoardTemplate{}, Status: 201},
+		{Method: "GET", Path: "/templates/:name", Handler: getTemplate, Tag: "templates", Summary: "Get a template", Query: specFormatQuery, Response: BoardTemplate{}},
+		{Method: "PUT", Path: "/templates/:name", Handler: updateTemplate, Tag: "templates", Summary: "Replace a template", Body: BoardTemplate{}, Response: BoardTemplate{}},
+		{Method: "DELETE", Path: "/templates/:name", Handler: deleteTemplate, Tag: "templates", Summary: "Delete a template"},
+
+		{Method: "GET", Path: "/boards/:id/tasks", Handler: cached(cacheTasks, getBoardTasks), Tag: "tasks", Summary: "List a board's tasks", Query: taskListQuery, Response: paged{Task{}}},
+		{Method: "GET", Path: "/boards/:id/tasks.csv", Handler: exportTasksCSV, Tag: "tasks", Summary: "Export a board's tasks as CSV", Query: append([]string{"q", "sort", "columns"}, taskFilterQuery...), Produces: "text/csv"},
+		{Method: "GET", Path: "/boards/:id/report.md", Handler: exportBoardReport, Tag: "tasks", Summary: "Markdown status report grouped by list", Query: append([]string{"q", "sort"}, taskFilterQuery...), Produces: "text/markdown"},
+		{Method: "GET", Path: "/boards/:id/events", Handler: getBoardEvents, Tag: "events", Summary: "Board events after ?since=", Query: []string{"since", "limit"}, Response: []BoardEvent{}},
+		{Method: "GET", Path: "/boards/:id/stream", Handler: streamBoardEvents, Tag: "events", Summary: "Server-sent board events; browsers may authenticate with ?access_token=", Query: []string{"since"}, Produces: "text/event-stream"},
+		{Method: "GET", Path: "/boards/:id/members", Handler: cached(cacheMembers, getBoardMembers), Tag: "members", Summary: "List a board's members", Response: []Member{}},
+		{Method: "POST", Path: "/boards/:id/members", Handler: addBoardMember, Tag: "members", Summary: "Add a member to a board or change their role (owners only)", Body: BoardMemberReq{}},
+		{Method: "DELETE", Path: "/boards/:id/members/:mid", Handler: removeBoardMember, Tag: "members", Summary: "Remove a member from a board (owners, or the member themselves)"},
+
+		{Method: "GET", Path: "/tasks", Handler: queryTasks, Tag: "tasks", Summary: "Query tasks across boards; without limit, more than 100 results come back as a page", Query: taskListQuery, Response: paged{Task{}}},
+		{Method: "POST", Path: "/tasks", Handler: createTask, Tag: "tasks", Summary: "Create a task", Body: Task{}, Response: Task{}},
+		{Method: "GET", Path: "/tasks/:id", Handler: getTask, Tag: "tasks", Summary: "Get a task with its checklist and subtasks", Response: TaskDetail{}},
+		{Method: "PUT", Path: "/tasks/:id", Handler: updateTask, Tag: "tasks", Summary: "Update a task", Body: Task{}, Response: Task{}},
+		{Method: "GET", Path: "/tasks/:id/subtasks", Handler: getSubtasks, Tag: "tasks", Summary: "List subtasks", Response: []Task{}},
+		{Method: "GET", Path: "/tasks/:id/checklist", Handler: getChecklist, Tag: "checklists", Summary: "List checklist items", Response: []ChecklistItem{}},
+		{Method: "POST", Path: "/tasks/:id/checklist", Handler: createChecklistItem, Tag: "checklists", Summary: "Add a checklist item", Body: ChecklistItemReq{}, Response: ChecklistItem{}, Status: 201},
+		{Method: "PUT", Path: "/checklist/:id", Handler: updateChecklistItem, Tag: "checklists", Summary: "Update a checklist item", Body: ChecklistItemReq{}, Response: ChecklistItem{}},
+		{Method: "POST", Path: "/checklist/:id/toggle", Handler: toggleChecklistItem, Tag: "checklists", Summary: "Toggle a checklist item", Body: ChecklistItemReq{}, Response: ChecklistItem{}},
+		{Method: "DELETE", Path: "/checklist/:id", Handler: deleteChecklistItem, Tag: "checklists", Summary: "Delete a checklist item", Query: []string{"updated_by"}},
+		{Method: "GET", Path: "/tasks/:id/links", Handler: getTaskLinks, Tag: "links", Summary: "List a task's links", Response: []TaskLink{}},
+		{Method: "POST", Path: "/tasks/:id/links", Handler: createTaskLink, Tag: "links", Summary: "Link two tasks", Body: TaskLinkReq{}, Response: TaskLink{}, Status: 201},
+		{Method: "DELETE", Path: "/links/:id", Handler: deleteTaskLink, Tag: "links", Summary: "Delete a task link", Query: []string{"updated_by"}},
+		{Method: "GET", Path: "/tasks/:id/activities", Handler: getTaskActivities, Tag: "tasks", Summary: "Task activity, newest first", Query: pageQuery, Response: paged{Activity{}}},
+		{Method: "POST", Path: "/tasks/:id/labels", Handler: addTaskLabel, Tag: "labels", Summary: "Label a task", Body: TaskLabelReq{}, Response: Label{}},
+		{Method: "DELETE", Path: "/tasks/:id/labels/:lid", Handler: removeTaskLabel, Tag: "labels", Summary: "Remove a label from a task", Query: []string{"updated_by"}},
+		{Method: "GET", Path: "/search", Handler: searchTasks, Tag: "search", Summary: "Semantic task search", Query: append([]string{"q"}, taskFilterQuery...), Response: []Task{}},
+		{Method: "GET", Path: "/views", Handler: getViews, Tag: "views", Summary: "List saved views", Query: append([]string{"board_id"}, pageQuery...), Response: paged{SavedView{}}},
+		{Method: "POST", Path: "/views", Handler: createView, Tag: "views", Summary: "Create a saved view", Body: SavedView{}, Response: SavedView{}, Status: 201},
+		{Method: "GET", Path: "/views/:id", Handler: getView, Tag: "views", Summary: "Get a saved view", Response: SavedView{}},
+		{Method: "PUT", Path: "/views/:id", Handler: updateView, Tag: "views", Summary: "Update a saved view", Body: SavedView{}, Response: SavedView{}},
+		{Method: "DELETE", Path: "/views/:id", Handler: deleteView, Tag: "views", Summary: "Delete a saved view"},
+		{Method: "GET", Path: "/views/:id/tasks", Handler: getViewTasks, Tag: "views", Summary: "Run a saved view; without limit, more than 100 results come back as a page", Query: append(append([]string{"sort"}, taskFilterQuery...), pageQuery...), Response: paged{Task{}}},
+		{Method: "GET", Path: "/members", Handler: getMembers, Tag: "members", Summary: "List members", Query: append([]string{"include_inactive"}, pageQuery...), Response: paged{Member{}}},
+		{Method: "POST", Path: "/members", Handler: createMember, Tag: "members", Summary: "Create a member (needs an API token)", Body: Member{}, Response: Member{}, Status: 201},
+		{Method: "GET", Path: "/members/:id", Handler: getMember, Tag: "members", Summary: "Get a member", Response: Member{}},
+		{Method: "PUT", Path: "/members/:id", Handler: updateMember, Tag: "members", Summary: "Update a member (the member themselves or an admin)", Body: MemberUpdate{}, Response: Member{}},
+		{Method: "DELETE", Path: "/members/:id", Handler: deleteMember, Tag: "members", Summary: "Deactivate a member (admins only)"},
+		{Method: "GET", Path: "/members/:id/tokens", Handler: getMemberTokens, Tag: "members", Summary: "List the caller's API tokens", Response: []MemberToken{}},
+		{Method: "POST", Path: "/members/:id/tokens", Handler: createMemberToken, Tag: "members", Summary: "Create an API token; the token is only returned here", Body: MemberTokenReq{}, Response: MemberToken{}, Status: 201},
+		{Method: "DELETE", Path: "/members/:id/tokens/:tid", Handler: revokeMemberToken, Tag: "members", Summary: "Revoke an API token"},
+
+		// Knowledge Base / Documents
+		{Method: "GET", Path: "/boards/:id/docs", Handler: getBoardDocs, Tag: "docs", Summary: "List a board's documents", Query: pageQuery, Response: paged{Document{}}},
+		{Method: "POST", Path: "/boards/:id/docs", Handler: createDoc, Tag: "docs", Summary: "Create a document", Body: Document{}, Response: Document{}},
+		{Method: "GET", Path: "/docs/:id", Handler: getDoc, Tag: "docs", Summary: "Get a document", Response: Document{}},
+		{Method: "PUT", Path: "/docs/:id", Handler: updateDoc, Tag: "docs", Summary: "Update a document", Body: Document{}, Response: Document{}},
+		{Method: "DELETE", Path: "/docs/:id", Handler: deleteDoc, Tag: "docs", Summary: "Delete a document"},
+		{Method: "GET", Path: "/docs/search", Handler: searchDocs, Tag: "search", Summary: "Semantic document search", Query: []string{"q", "board_id"}, Response: []Document{}},
+
+		// Comments
+		{Method: "GET", Path: "/tasks/:id/comments", Handler: getTaskComments, Tag: "comments", Summary: "List a task's comments", Query: pageQuery, Response: paged{Comment{}}},
+		{Method: "POST", Path: "/tasks/:id/comments", Handler: createComment, Tag: "comments", Summary: "Comment on a task", Body: Comment{}, Response: Comment{}},
+	}
+}
+
+// registerRoutes mounts every route under each API prefix.
+func registerRoutes(app *fiber.App) {
+	for _, prefix := range apiPrefixes {
+		api := app.Group(prefix)
+		for _, r := range apiRoutes() {
+			api.Add(r.Method, r.Path, r.Handler)
+		}
+	}
+}
+
+func getHealth(c *fiber.Ctx) error { return c.JSON(fiber.Map{"status": "ok"}) }
+
+// unversionedPath maps /api/v1/... to /api/..., so middleware can match a
+// route whichever prefix the client used.
+func unversionedPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, apiPrefixes[0]+"/"); ok {
+		return "/api/" + rest
+	}
+	return path
+}
+
+// openAPIPath converts a fiber path such as /boards/:id to /boards/{id}.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			name := strings.TrimPrefix(p, ":")
+			params = append(params, name)
+			parts[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Schedule is a board's projected timeline in a Gantt-friendly shape. Dates
+// are calendar-based: one day of duration is 24 hours.
+type Schedule struct {
+	BoardID      string         `json:"board_id"`
+	Start        time.Time      `json:"start"`
+	Finish       time.Time      `json:"finish"`
+	CriticalPath []int          `json:"critical_path"`
+	Tasks        []ScheduleTask `json:"tasks"`
+}
+
+type ScheduleTask struct {
+	ID             int        `json:"id"`
+	Title          string     `json:"title"`
+	ListID         string     `json:"list_id"`
+	AssigneeID     *string    `json:"assignee_id"`
+	DurationDays   float64    `json:"duration_days"`
+	Estimated      bool       `json:"estimated"`
+	Completed      bool       `json:"completed"`
+	EarliestStart  time.Time  `json:"earliest_start"`
+	EarliestFinish time.Time  `json:"earliest_finish"`
+	LatestStart    time.Time  `json:"latest_start"`
+	LatestFinish   time.Time  `json:"latest_finish"`
+	SlackDays      float64    `json:"slack_days"`
+	Critical       bool       `json:"critical"`
+	DueDate        *time.Time `json:"due_date"`
+	Late           bool       `json:"late"`
+	Dependencies   []int      `json:"dependencies"`
+}
+
+// scheduleOptions controls how estimates become durations.
+type scheduleOptions struct {
+	Start       time.Time
+	HoursPerDay float64
+	DaysPerPt   float64
+	DefaultDays float64
+}
+
+const day = 24 * time.Hour
+
+func toDays(d time.Duration) float64 { return math.Round(d.Hours()/24*100) / 100 }
+
+func fromDays(d float64) time.Duration { return time.Duration(d * float64(day)) }
+
+func parseScheduleOptions(c *fiber.Ctx) (scheduleOptions, error) {
+	now := time.Now().UTC()
+	opts := scheduleOptions{
+		Start:       time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		HoursPerDay: 8,
+		DaysPerPt:   1,
+		DefaultDays: 1,
+	}
+	if v := c.Query("start"); v != "" {
+		ts, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			if ts, err = time.Parse(time.RFC3339, v); err != nil {
+				return opts, invalidField("start", "must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+			}
+		}
+		opts.Start = ts
+	}
+	for param, dst := range map[string]*float64{"hours_per_day": &opts.HoursPerDay, "days_per_point": &opts.DaysPerPt, "default_days": &opts.DefaultDays} {
+		if v := c.Query(param); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || (param == "hours_per_day" && f == 0) {
+				return opts, invalidField(param, "must be a positive number")
+			}
+			*dst = f
+		}
+	}
+	return opts, nil
+}
+
+// computeSchedule runs the critical path method over tasks and the blocks
+// links between them. Completed tasks are pinned to their completion time;
+// open tasks start no earlier than opts.Start, their start_date and the
+// finish of every blocker. It reports false when the links contain a cycle.
+func computeSchedule(tasks []Task, deps map[int][]int, opts scheduleOptions) ([]ScheduleTask, []int, bool) {
+	index := make(map[int]int, len(tasks))
+	out := make([]ScheduleTask, len(tasks))
+	successors := map[int][]int{}
+	indegree := map[int]int{}
+	for i, t := range tasks {
+		index[t.ID] = i
+		st := ScheduleTask{ID: t.ID, Title: t.Title, ListID: t.ListID, AssigneeID: t.AssigneeID,
+			Completed: t.CompletedAt != nil, DueDate: t.DueDate, Dependencies: []int{}}
+		switch {
+		case st.Completed:
+		case t.Estimate != nil && t.EstimateUnit == "hours":
+			st.DurationDays, st.Estimated = *t.Estimate/opts.HoursPerDay, true
+		case t.Estimate != nil:
+			st.DurationDays, st.Estimated = *t.Estimate*opts.DaysPerPt, true
+		default:
+			st.DurationDays = opts.DefaultDays
+		}
+		out[i] = st
+	}
+	for id, blockers := range deps {
+		for _, b := range blockers {
+			if _, ok := index[b]; !ok {
+				continue
+			}
+			out[index[id]].Dependencies = append(out[index[id]].Dependencies, b)
+			successors[b] = append(successors[b], id)
+			indegree[id]++
+		}
+	}
+
+	// Kahn's algorithm gives a topological order for both passes.
+	var order, queue []int
+	for _, t := range tasks {
+		if indegree[t.ID] == 0 {
+			queue = append(queue, t.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+		for _, s := range successors[id] {
+			if indegree[s]--; indegree[s] == 0 {
+				queue = append(queue, s)
+			}
+		}
+	}
+	if len(order) != len(tasks) {
+		return nil, nil, false
+	}
+
+	finish := opts.Start
+	for _, id := range order {
+		st, t := &out[index[id]], tasks[index[id]]
+		if st.Completed {
+			st.EarliestStart, st.EarliestFinish = *t.CompletedAt, *t.CompletedAt
+		} else {
+			es := opts.Start
+			if t.StartDate != nil && t.StartDate.After(es) {
+				es = *t.StartDate
+			}
+			for _, b := range st.Dependencies {
+				if ef := out[index[b]].EarliestFinish; ef.After(es) {
+					es = ef
+				}
+			}
+			st.EarliestStart = es
+			st.EarliestFinish = es.Add(fromDays(st.DurationDays))
+		}
+		if st.EarliestFinish.After(finish) {
+			finish = st.EarliestFinish
+		}
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		st := &out[index[order[i]]]
+		lf := finish
+		for _, s := range successors[st.ID] {
+			if ls := out[index[s]].LatestStart; ls.Before(lf) {
+				lf = ls
+			}
+		}
+		if st.Completed {
+			lf = st.EarliestFinish
+		}
+		st.LatestFinish = lf
+		st.LatestStart = lf.Add(-fromDays(st.DurationDays))
+		st.SlackDays = toDays(st.LatestStart.Sub(st.EarliestStart))
+		st.Critical = !st.Completed && st.SlackDays <= 0
+		st.Late = st.DueDate != nil && st.EarliestFinish.After(*st.DueDate)
+		st.DurationDays = math.Round(st.DurationDays*100) / 100
+	}
+
+	// Walk back from the task that finishes last through critical blockers
+	// whose finish sets each task's start.
+	var path []int
+	var last *ScheduleTask
+	for i := range out {
+		if out[i].Critical && (last == nil || out[i].EarliestFinish.After(last.EarliestFinish)) {
+			last = &out[i]
+		}
+	}
+	for last != nil {
+		path = append([]int{last.ID}, path...)
+		var prev *ScheduleTask
+		for _, b := range last.Dependencies {
+			if p := &out[index[b]]; p.Critical && p.EarliestFinish.Equal(last.EarliestStart) {
+				prev = p
+				break
+			}
+		}
+		last = prev
+	}
+	if path == nil {
+		path = []int{}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].EarliestStart.Before(out[j].EarliestStart) })
+	return out, path, true
+}
+
+func getBoardSchedule(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	opts, err := parseScheduleOptions(c)
+	if err != nil {
+		return err
+	}
+	if _, err := loadBoard(boardID); err != nil {
+		return fiber.NewError(404, "Board not found")
+	}
+	ctx := context.Background()
+	rows, err := db.Query(ctx, "SELECT "+taskColumns+" FROM tasks t WHERE t.board_id=$1 ORDER BY t.position, t.id", boardID)
+	if err != nil {
+		return err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return err
+	}
+
+	deps := map[int][]int{}
+	rows, err = db.Query(ctx, `
+		SELECT tk.target_id, tk.task_id FROM task_links tk JOIN tasks t ON t.id = tk.target_id
+		WHERE t.board_id=$1 AND tk.type='blocks' ORDER BY tk.task_id`, boardID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, blocker int
+		if err := rows.Scan(&id, &blocker); err != nil {
+			rows.Close()
+			return err
+		}
+		deps[id] = append(deps[id], blocker)
+	}
+	rows.Close()
+
+	scheduled, path, ok := computeSchedule(tasks, deps, opts)
+	if !ok {
+		return fiber.NewError(409, "Task dependencies contain a cycle")
+	}
+	s := Schedule{BoardID: boardID, Start: opts.Start, Finish: opts.Start, CriticalPath: path, Tasks: scheduled}
+	for _, t := range scheduled {
+		if t.EarliestFinish.After(s.Finish) {
+			s.Finish = t.EarliestFinish
+		}
+	}
+	return c.JSON(s)
+}
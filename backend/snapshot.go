@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// BoardSnapshot is everything a client needs to render a board in one
+// response. Seq is the board's event sequence when the snapshot was taken;
+// subscribing with since=Seq picks up every later change.
+type BoardSnapshot struct {
+	Board   Board          `json:"board"`
+	Lists   []List         `json:"lists"`
+	Tasks   []SnapshotTask `json:"tasks"`
+	Members []Member       `json:"members"`
+	Docs    []DocSummary   `json:"docs"`
+	Seq     int64          `json:"seq"`
+}
+
+type SnapshotTask struct {
+	Task
+	CommentCount   int        `json:"comment_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+}
+
+// DocSummary is a document without its content.
+type DocSummary struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// getBoardSnapshot handles GET /api/boards/:id/snapshot. The rows are read
+// in one repeatable-read transaction so they agree with the returned Seq.
+func getBoardSnapshot(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var s BoardSnapshot
+	s.Board, err = scanBoard(tx.QueryRow(ctx, "SELECT "+boardColumns+" FROM boards WHERE id=$1", boardID))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, "SELECT event_seq FROM boards WHERE id=$1", boardID).Scan(&s.Seq); err != nil {
+		return err
+	}
+	if s.Lists, err = loadLists(tx, boardID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, "SELECT "+taskColumns+" FROM tasks t WHERE t.board_id=$1 ORDER BY t.position, t.id", boardID)
+	if err != nil {
+		return err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return err
+	}
+	if err := decorateTasks(tx, tasks); err != nil {
+		return err
+	}
+	s.Tasks = make([]SnapshotTask, len(tasks))
+	index := make(map[int]int, len(tasks))
+	for i, t := range tasks {
+		s.Tasks[i].Task = t
+		index[t.ID] = i
+	}
+	rows, err = tx.Query(ctx, `
+		SELECT t.id,
+			(SELECT COUNT(*) FROM comments cm WHERE cm.task_id = t.id),
+			(SELECT MAX(a.created_at) FROM activities a WHERE a.task_id = t.id)
+		FROM tasks t WHERE t.board_id=$1`, boardID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, count int
+		var last *time.Time
+		if err := rows.Scan(&id, &count, &last); err != nil {
+			rows.Close()
+			return err
+		}
+		if i, ok := index[id]; ok {
+			s.Tasks[i].CommentCount, s.Tasks[i].LastActivityAt = count, last
+		}
+	}
+	rows.Close()
+
+	rows, err = tx.Query(ctx, `
+		SELECT `+memberColumns+` FROM members m JOIN board_members bm ON m.id = bm.member_id
+		WHERE bm.board_id=$1 ORDER BY m.created_at, m.id`, boardID)
+	if err != nil {
+		return err
+	}
+	if s.Members, err = scanMembers(rows); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(ctx, "SELECT id, title, updated_at FROM documents WHERE board_id=$1 ORDER BY updated_at DESC, id DESC", boardID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	s.Docs = []DocSummary{}
+	for rows.Next() {
+		var d DocSummary
+		if err := rows.Scan(&d.ID, &d.Title, &d.UpdatedAt); err != nil {
+			return err
+		}
+		s.Docs = append(s.Docs, d)
+	}
+	return c.JSON(s)
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// BoardSpec is the declarative, version-controllable form of a board's
+// configuration. Applying a spec converges the board to it.
+type BoardSpec struct {
+	Version     int               `json:"version" yaml:"version"`
+	ID          string            `json:"id,omitempty" yaml:"id,omitempty"`
+	Title       string            `json:"title" yaml:"title"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Settings    BoardSettings     `json:"settings" yaml:"settings,omitempty"`
+	Lists       []List            `json:"lists" yaml:"lists"`
+	Members     []BoardMemberReq  `json:"members" yaml:"members"`
+	Labels      []LabelSpec       `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Fields      []CustomFieldSpec `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+const boardSpecVersion = 1
+
+// SpecChange is one step of an apply plan.
+type SpecChange struct {
+	Action string `json:"action"` // create, update, delete
+	Kind   string `json:"kind"`   // board, settings, list, member, label, field
+	ID     string `json:"id"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type ApplyPlan struct {
+	BoardID string       `json:"board_id"`
+	DryRun  bool         `json:"dry_run"`
+	Changes []SpecChange `json:"changes"`
+}
+
+func (p ApplyPlan) String() string {
+	if len(p.Changes) == 0 {
+		return "No changes. Board is up to date.\n"
+	}
+	var sb strings.Builder
+	for _, ch := range p.Changes {
+		sign := map[string]string{"create": "+", "update": "~", "delete": "-"}[ch.Action]
+		fmt.Fprintf(&sb, "%s %s %s", sign, ch.Kind, ch.ID)
+		if ch.Detail != "" {
+			fmt.Fprintf(&sb, " (%s)", ch.Detail)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "%d change(s)\n", len(p.Changes))
+	return sb.String()
+}
+
+func sameJSON(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func validateBoardSpec(s *BoardSpec) error {
+	if s.Version == 0 {
+		s.Version = boardSpecVersion
+	}
+	if s.Version != boardSpecVersion {
+		return fmt.Errorf("unsupported spec version %d", s.Version)
+	}
+	if strings.TrimSpace(s.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if len(s.Lists) == 0 {
+		return fmt.Errorf("at least one list is required")
+	}
+	listIDs, err := normalizeLists(s.Lists)
+	if err != nil {
+		return err
+	}
+	if err := validateSettings(s.Settings, listIDs); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	owners := 0
+	for i := range s.Members {
+		m := &s.Members[i]
+		if m.Role == "" {
+			m.Role = RoleEditor
+		}
+		if m.MemberID == "" || !validRole(m.Role) {
+			return fmt.Errorf("members[%d]: member_id is required and role must be owner, editor or viewer", i)
+		}
+		if seen[m.MemberID] {
+			return fmt.Errorf("members[%d]: %q is listed twice", i, m.MemberID)
+		}
+		seen[m.MemberID] = true
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+	if owners == 0 {
+		return fmt.Errorf("at least one member must be an owner")
+	}
+	if err := normalizeLabels(s.Labels); err != nil {
+		return err
+	}
+	return normalizeFields(s.Fields)
+}
+
+func exportBoardSpec(q dbtx, boardID string) (BoardSpec, error) {
+	ctx := context.Background()
+	b, err := scanBoard(q.QueryRow(ctx, "SELECT "+boardColumns+" FROM boards WHERE id=$1", boardID))
+	if err != nil {
+		return BoardSpec{}, err
+	}
+	s := BoardSpec{Version: boardSpecVersion, ID: b.ID, Title: b.Title, Description: b.Description, Settings: b.Settings}
+	if s.Lists, err = loadLists(q, boardID); err != nil {
+		return s, err
+	}
+	rows, err := q.Query(ctx, "SELECT member_id, role FROM board_members WHERE board_id=$1 ORDER BY member_id ASC", boardID)
+	if err != nil {
+		return s, err
+	}
+	for rows.Next() {
+		var m BoardMemberReq
+		if err := rows.Scan(&m.MemberID, &m.Role); err != nil {
+			rows.Close()
+			return s, err
+		}
+		s.Members = append(s.Members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+	labels, err := loadLabels(q, boardID)
+	if err != nil {
+		return s, err
+	}
+	for _, l := range labels {
+		s.Labels = append(s.Labels, LabelSpec{Name: l.Name, Color: l.Color})
+	}
+	fields, err := loadFields(q, boardID)
+	if err != nil {
+		return s, err
+	}
+	for _, f := range fields {
+		s.Fields = append(s.Fields, f.CustomFieldSpec)
+	}
+	return s, nil
+}
+
+// reconcileBoard diffs spec against the board in tx and, when execute is
+// true, performs the changes. The caller decides whether to commit.
+func reconcileBoard(tx pgx.Tx, spec *BoardSpec, execute bool) (ApplyPlan, error) {
+	ctx := context.Background()
+	plan := ApplyPlan{BoardID: spec.ID, DryRun: !execute, Changes: []SpecChange{}}
+	add := func(action, kind, id, detail string) {
+		plan.Changes = append(plan.Changes, SpecChange{Action: action, Kind: kind, ID: id, Detail: detail})
+	}
+
+	var actual BoardSpec
+	exists := false
+	if spec.ID != "" {
+		var err error
+		actual, err = exportBoardSpec(tx, spec.ID)
+		if err != nil && err != pgx.ErrNoRows {
+			return plan, err
+		}
+		exists = err == nil
+	}
+
+	for _, m := range spec.Members {
+		var active bool
+		err := tx.QueryRow(ctx, "SELECT active FROM members WHERE id=$1", m.MemberID).Scan(&active)
+		if err == pgx.ErrNoRows || (err == nil && !active) {
+			return plan, fiber.NewError(400, fmt.Sprintf("member %q does not exist or is inactive", m.MemberID))
+		}
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	if !exists {
+		add("create", "board", spec.Title, "")
+		if execute {
+			query := "INSERT INTO boards (title, description, settings) VALUES ($1, $2, $3) RETURNING id::text"
+			args := []interface{}{spec.Title, spec.Description, spec.Settings}
+			if spec.ID != "" {
+				query = "INSERT INTO boards (id, title, description, settings) VALUES ($4, $1, $2, $3) RETURNING id::text"
+				args = append(args, spec.ID)
+			}
+			if err := tx.QueryRow(ctx, query, args...).Scan(&plan.BoardID); err != nil {
+				return plan, err
+			}
+		}
+	} else {
+		if actual.Title != spec.Title || actual.Description != spec.Description {
+			add("update", "board", spec.ID, "title/description")
+		}
+		if !sameJSON(actual.Settings, spec.Settings) {
+			add("update", "settings", spec.ID, "")
+		}
+		if execute {
+			if _, err := tx.Exec(ctx, "UPDATE boards SET title=$1, description=$2, settings=$3 WHERE id=$4",
+				spec.Title, spec.Description, spec.Settings, spec.ID); err != nil {
+				return plan, err
+			}
+		}
+	}
+
+	// Lists
+	current := map[string]List{}
+	for _, l := range actual.Lists {
+		current[l.ID] = l
+	}
+	wanted := map[string]bool{}
+	for _, l := range spec.Lists {
+		wanted[l.ID] = true
+		cur, ok := current[l.ID]
+		switch {
+		case !ok:
+			add("create", "list", l.ID, string(l.Category))
+		case cur != l:
+			add("update", "list", l.ID, fmt.Sprintf("%s/%d/%s", l.Title, l.Position, l.Category))
+		default:
+			continue
+		}
+		if execute {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO lists (board_id, id, title, position, category) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (board_id, id) DO UPDATE SET title=EXCLUDED.title, position=EXCLUDED.position, category=EXCLUDED.category`,
+				plan.BoardID, l.ID, l.Title, l.Position, l.Category); err != nil {
+				return plan, err
+			}
+		}
+	}
+	for _, l := range actual.Lists {
+		if wanted[l.ID] {
+			continue
+		}
+		var n int
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM tasks WHERE board_id=$1 AND list_id=$2", spec.ID, l.ID).Scan(&n); err != nil {
+			return plan, err
+		}
+		if n > 0 {
+			return plan, fiber.NewError(409, fmt.Sprintf("list %q still has %d task(s); move them before removing it", l.ID, n))
+		}
+		add("delete", "list", l.ID, "")
+		if execute {
+			if _, err := tx.Exec(ctx, "DELETE FROM lists WHERE board_id=$1 AND id=$2", spec.ID, l.ID); err != nil {
+				return plan, err
+			}
+		}
+	}
+
+	// Members
+	roles := map[string]string{}
+	for _, m := range actual.Members {
+		roles[m.MemberID] = m.Role
+	}
+	wanted = map[string]bool{}
+	for _, m := range spec.Members {
+		wanted[m.MemberID] = true
+		role, ok := roles[m.MemberID]
+		switch {
+		case !ok:
+			add("create", "member", m.MemberID, m.Role)
+		case role != m.Role:
+			add("update", "member", m.MemberID, role+" -> "+m.Role)
+		default:
+			continue
+		}
+		if execute {
+			if _, err := tx.Exec(ctx,
+				"INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3) ON CONFLICT (board_id, member_id) DO UPDATE SET role=EXCLUDED.role",
+				plan.BoardID, m.MemberID, m.Role); err != nil {
+				return plan, err
+			}
+		}
+	}
+	for _, m := range actual.Members {
+		if wanted[m.MemberID] {
+			continue
+		}
+		add("delete", "member", m.MemberID, "")
+		if execute {
+			if _, err := tx.Exec(ctx, "DELETE FROM board_members WHERE board_id=$1 AND member_id=$2", spec.ID, m.MemberID); err != nil {
+				return plan, err
+			}
+		}
+	}
+
+	// Labels are matched by name, case-insensitively.
+	colors := map[string]string{}
+	for _, l := range actual.Labels {
+		colors[strings.ToLower(l.Name)] = l.Color
+	}
+	wanted = map[string]bool{}
+	for _, l := range spec.Labels {
+		key := strings.ToLower(l.Name)
+		wanted[key] = true
+		color, ok := colors[key]
+		switch {
+		case !ok:
+			add("create", "label", l.Name, l.Color)
+		case color != l.Color:
+			add("update", "label", l.Name, color+" -> "+l.Color)
+		default:
+			continue
+		}
+		if execute {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO labels (board_id, name, color) VALUES ($1, $2, $3)
+				ON CONFLICT (board_id, lower(name)) DO UPDATE SET color=EXCLUDED.color`,
+				plan.BoardID, l.Name, l.Color); err != nil {
+				return plan, err
+			}
+		}
+	}
+	for _, l := range actual.Labels {
+		if wanted[strings.ToLower(l.Name)] {
+			continue
+		}
+		add("delete", "label", l.Name, "")
+		if execute {
+			if _, err := tx.Exec(ctx, "DELETE FROM labels WHERE board_id=$1 AND lower(name)=lower($2)", spec.ID, l.Name); err != nil {
+				return plan, err
+			}
+		}
+	}
+
+	// Custom fields are matched by key. A field's type cannot change in
+	// place; remove it and add it under a new key instead.
+	fields := map[string]CustomFieldSpec{}
+	for _, f := range actual.Fields {
+		fields[f.Key] = f
+	}
+	wanted = map[string]bool{}
+	for _, f := range spec.Fields {
+		wanted[f.Key] = true
+		cur, ok := fields[f.Key]
+		switch {
+		case !ok:
+			add("create", "field", f.Key, string(f.Type))
+		case cur.Type != f.Type:
+			return plan, fiber.NewError(409, fmt.Sprintf("field %q: type cannot change from %s to %s", f.Key, cur.Type, f.Type))
+		case !sameJSON(cur, f):
+			add("update", "field", f.Key, "")
+		default:
+			continue
+		}
+		if execute {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO custom_fields (board_id, key, name, type, options, required, position, display)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (board_id, key) DO UPDATE SET name=EXCLUDED.name, options=EXCLUDED.options,
+					required=EXCLUDED.required, position=EXCLUDED.position, display=EXCLUDED.display`,
+				plan.BoardID, f.Key, f.Name, f.Type, f.Options, f.Required, f.Position, f.Display); err != nil {
+				return plan, err
+			}
+		}
+	}
+	for _, f := range actual.Fields {
+		if wanted[f.Key] {
+			continue
+		}
+		add("delete", "field", f.Key, "")
+		if execute {
+			if err := removeField(tx, spec.ID, f.Key); err != nil {
+				return plan, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// applyBoardSpec validates and reconciles spec in a transaction, committing
+// only when dryRun is false.
+func applyBoardSpec(spec *BoardSpec, dryRun bool) (ApplyPlan, error) {
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	defer tx.Rollback(ctx)
+	plan, err := reconcileBoard(tx, spec, !dryRun)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	return plan, tx.Commit(ctx)
+}
+
+func getBoardSpec(c *fiber.Ctx) error {
+	s, err := exportBoardSpec(db, c.Params("id"))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	return sendSpec(c, s)
+}
+
+// applyBoard handles POST /api/boards/apply and /api/boards/:id/apply. With
+// ?dry_run=true it only returns the plan.
+func applyBoard(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	spec := new(BoardSpec)
+	if err := parseSpec(c, spec); err != nil {
+		return badRequest(err)
+	}
+	if id := c.Params("id"); id != "" {
+		spec.ID = id
+	}
+	if err := validateBoardSpec(spec); err != nil {
+		return badRequest(err)
+	}
+	if spec.ID != "" {
+		_, err := loadBoard(spec.ID)
+		if err != nil && err != pgx.ErrNoRows {
+			return err
+		}
+		if err == nil && boardRole(spec.ID, caller) != RoleOwner {
+			return fiber.NewError(403, "Only board owners can apply a spec")
+		}
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	plan, err := applyBoardSpec(spec, dryRun)
+	if err != nil {
+		return err
+	}
+	if !dryRun && len(plan.Changes) > 0 {
+		emitEvent(plan.BoardID, EventBoardUpdated, nil, caller, plan)
+	}
+	if c.Query("format") == "text" {
+		return c.SendString(plan.String())
+	}
+	return c.JSON(plan)
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const EventTaskOverdue = "task.overdue"
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+var priorityRank = map[Priority]int{PriorityLow: 1, PriorityMedium: 2, PriorityHigh: 3, PriorityUrgent: 4}
+
+func (p Priority) Valid() bool { return priorityRank[p] > 0 }
+
+// priorityRankSQL orders priorities in SQL the same way as priorityRank.
+const priorityRankSQL = "CASE t.priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 WHEN 'high' THEN 3 WHEN 'urgent' THEN 4 ELSE 0 END"
+
+type Task struct {
+	ID           int        `json:"id"`
+	BoardID      string     `json:"board_id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	ListID       string     `json:"list_id"`
+	Position     int        `json:"position"`
+	AssigneeID   *string    `json:"assignee_id"`
+	Priority     Priority   `json:"priority"`
+	StartDate    *time.Time `json:"start_date"`
+	DueDate      *time.Time `json:"due_date"`
+	Estimate     *float64   `json:"estimate"`
+	EstimateUnit string     `json:"estimate_unit,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	ParentID     *int       `json:"parent_id"`
+	// CustomFields holds values of the board's custom fields by key.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+	Labels       []Label                `json:"labels,omitempty"`
+	Progress     *Progress              `json:"progress,omitempty"`
+	BlockedBy    []int                  `json:"blocked_by,omitempty"`
+	UpdatedBy    string                 `json:"updated_by,omitempty"`
+}
+
+const taskColumns = "t.id, t.board_id::text, t.title, COALESCE(t.description, ''), t.list_id, t.position, t.assignee_id, t.priority, t.start_date, t.due_date, t.estimate, COALESCE(t.estimate_unit, ''), t.completed_at, t.parent_id, t.custom_fields"
+
+func scanTask(row pgx.Row) (Task, error) {
+	var t Task
+	err := row.Scan(&t.ID, &t.BoardID, &t.Title, &t.Description, &t.ListID, &t.Position, &t.AssigneeID,
+		&t.Priority, &t.StartDate, &t.DueDate, &t.Estimate, &t.EstimateUnit, &t.CompletedAt, &t.ParentID, &t.CustomFields)
+	return t, err
+}
+
+func scanTasks(rows pgx.Rows) ([]Task, error) {
+	defer rows.Close()
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	return tasks, rows.Err()
+}
+
+func loadTask(id int) (Task, error) {
+	return scanTask(db.QueryRow(context.Background(), "SELECT "+taskColumns+" FROM tasks t WHERE t.id=$1", id))
+}
+
+// decorateTasks fills in the labels, progress roll-up and open blockers of
+// listed tasks, reading through q.
+func decorateTasks(q dbtx, tasks []Task) error {
+	if err := attachLabels(q, tasks); err != nil {
+		return err
+	}
+	if err := attachProgress(q, tasks); err != nil {
+		return err
+	}
+	return attachBlockers(q, tasks)
+}
+
+// taskEmbeddingText is the text embedded for semantic search.
+func taskEmbeddingText(t *Task) string {
+	text := t.Title + " " + t.Description
+	if t.Priority != "" {
+		text += "\nPriority: " + string(t.Priority)
+	}
+	if t.DueDate != nil {
+		text += "\nDue: " + t.DueDate.Format("2006-01-02")
+	}
+	return text
+}
+
+// validateAssignee rejects assigning work to a deactivated member. Unknown
+// members are left to the foreign key.
+func validateAssignee(assigneeID *string) error {
+	if assigneeID == nil || *assigneeID == "" {
+		return nil
+	}
+	var active bool
+	err := db.QueryRow(context.Background(), "SELECT active FROM members WHERE id=$1", *assigneeID).Scan(&active)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !active {
+		return invalidField("assignee_id", "member %q is deactivated", *assigneeID)
+	}
+	return nil
+}
+
+func validateTaskMeta(t *Task) error {
+	if !t.Priority.Valid() {
+		return invalidField("priority", "must be one of low, medium, high, urgent")
+	}
+	if t.Estimate != nil {
+		if *t.Estimate < 0 {
+			return invalidField("estimate", "must not be negative")
+		}
+		if t.EstimateUnit == "" {
+			t.EstimateUnit = "points"
+		}
+	}
+	if t.EstimateUnit != "" && t.EstimateUnit != "points" && t.EstimateUnit != "hours" {
+		return invalidField("estimate_unit", "must be points or hours")
+	}
+	if t.StartDate != nil && t.DueDate != nil && t.DueDate.Before(*t.StartDate) {
+		return invalidField("due_date", "must not be before start_date")
+	}
+	return nil
+}
+
+func listCategory(boardID, listID string) ListCategory {
+	var cat ListCategory
+	db.QueryRow(context.Background(), "SELECT category FROM lists WHERE board_id=$1 AND id=$2", boardID, listID).Scan(&cat)
+	return cat
+}
+
+// taskFilter turns the supported query parameters into WHERE clauses.
+// Placeholders continue from the args already present.
+func taskFilter(c *fiber.Ctx, where []string, args []interface{}) ([]string, []interface{}, error) {
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if v := c.Query("list_id"); v != "" {
+		where = append(where, "t.list_id = ANY("+arg(strings.Split(v, ","))+")")
+	}
+	if v := c.Query("assignee"); v != "" {
+		where = append(where, "t.assignee_id = ANY("+arg(strings.Split(v, ","))+")")
+	}
+	if v := c.Query("priority"); v != "" {
+		ps := strings.Split(v, ",")
+		for _, p := range ps {
+			if !Priority(p).Valid() {
+				return nil, nil, fmt.Errorf("invalid priority %q", p)
+			}
+		}
+		where = append(where, "t.priority = ANY("+arg(ps)+")")
+	}
+	for param, clause := range map[string]string{"due_before": "t.due_date < ", "due_after": "t.due_date > "} {
+		if v := c.Query(param); v != "" {
+			ts, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+			}
+			where = append(where, clause+arg(ts))
+		}
+	}
+	if v := c.Query("label"); v != "" {
+		names := strings.Split(strings.ToLower(v), ",")
+		where = append(where, "EXISTS (SELECT 1 FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE tl.task_id = t.id AND lower(l.name) = ANY("+arg(names)+"))")
+	}
+	if v := c.Query("blocked"); v != "" {
+		if c.QueryBool("blocked") {
+			where = append(where, openBlockersSQL)
+		} else {
+			where = append(where, "NOT "+openBlockersSQL)
+		}
+	}
+	if c.QueryBool("overdue") {
+		where = append(where, "t.due_date < CURRENT_TIMESTAMP AND t.completed_at IS NULL")
+	}
+	where, err := customFieldFilter(c, where, arg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return where, args, nil
+}
+
+// taskQuery is a compiled task search: request filters, query language and
+// sort. Running it does not touch the request, so streaming exports can
+// keep fetching pages after the handler returns.
+type taskQuery struct {
+	where  []string
+	args   []interface{}
+	sortBy string
+}
+
+func compileTaskQuery(c *fiber.Ctx, where []string, args []interface{}, q, sortBy string) (taskQuery, error) {
+	where, args, err := taskFilter(c, where, args)
+	if err != nil {
+		return taskQuery{}, badRequest(err)
+	}
+	if where, args, err = compileQuery(q, callerID(c), where, args); err != nil {
+		return taskQuery{}, invalidField("q", "%v", err)
+	}
+	if _, err := taskOrder(sortBy); err != nil {
+		return taskQuery{}, badRequest(err)
+	}
+	return taskQuery{where: where, args: args, sortBy: sortBy}, nil
+}
+
+// and returns a copy of tq restricted by clause, whose placeholder for value
+// is written as %s.
+func (tq taskQuery) and(clause string, value interface{}) taskQuery {
+	args := append(append([]interface{}{}, tq.args...), value)
+	where := append(append([]string{}, tq.where...), fmt.Sprintf(clause, "$"+strconv.Itoa(len(args))))
+	return taskQuery{where: where, args: args, sortBy: tq.sortBy}
+}
+
+func (tq taskQuery) fetch(page pageRequest) ([]Task, error) {
+	where, args := append([]string{}, tq.where...), append([]interface{}{}, tq.args...)
+	order, _ := taskOrder(tq.sortBy)
+	if page.Cursor != nil {
+		s, _ := parseTaskSort(tq.sortBy)
+		clause, err := s.after(page.Cursor, func(v interface{}) string {
+			args = append(args, v)
+			return "$" + strconv.Itoa(len(args))
+		})
+		if err != nil {
+			return nil, fiber.NewError(400, "Invalid cursor")
+		}
+		where = append(where, clause)
+	}
+	query := "SELECT " + taskColumns + " FROM tasks t WHERE " + strings.Join(where, " AND ") + " ORDER BY " + order + page.limitSQL()
+	rows, err := db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, decorateTasks(db, tasks)
+}
+
+// findTasks lists decorated tasks matching where plus the request's filter
+// parameters and the query q (see query.go), ordered by sortBy and limited
+// to page. Invalid filters yield a 400 error.
+func findTasks(c *fiber.Ctx, where []string, args []interface{}, q, sortBy string, page pageRequest) ([]Task, error) {
+	tq, err := compileTaskQuery(c, where, args, q, sortBy)
+	if err != nil {
+		return nil, err
+	}
+	return tq.fetch(page)
+}
+
+func getBoardTasks(c *fiber.Ctx) error {
+	sortBy := c.Query("sort")
+	page, err := parsePage(c, sortBy)
+	if err != nil {
+		return err
+	}
+	tasks, err := findTasks(c, []string{"t.board_id = $1"}, []interface{}{c.Params("id")}, c.Query("q"), sortBy, page)
+	if err != nil {
+		return err
+	}
+	return sendPage(c, page, tasks, taskCursor(sortBy))
+}
+
+// unpagedQueryResults caps cross-board queries that do not ask for a page;
+// past it they answer with a page (see capUnpaged).
+const unpagedQueryResults = 100
+
+// queryTasks handles GET /api/tasks?q=, searching tasks across all
+// non-archived boards.
+func queryTasks(c *fiber.Ctx) error {
+	sortBy := c.Query("sort")
+	page, err := parsePage(c, sortBy)
+	if err != nil {
+		return err
+	}
+	page.capUnpaged(unpagedQueryResults)
+	tasks, err := findTasks(c, []string{"t.board_id IN (SELECT id FROM boards WHERE archived_at IS NULL)"}, nil,
+		c.Query("q"), sortBy, page)
+	if err != nil {
+		return err
+	}
+	return sendPage(c, page, tasks, taskCursor(sortBy))
+}
+
+func createTask(c *fiber.Ctx) error {
+	t := new(Task)
+	if err := parseBody(c, t); err != nil {
+		return err
+	}
+	actor, err := actorOr(c, t.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	if t.BoardID == "" && t.ParentID != nil {
+		t.BoardID, _ = taskBoardID(*t.ParentID)
+	}
+	if t.BoardID == "" {
+		var defaultID string
+		db.QueryRow(context.Background(), "SELECT id::text FROM boards WHERE archived_at IS NULL ORDER BY created_at ASC LIMIT 1").Scan(&defaultID)
+		if defaultID == "" {
+			return fiber.NewError(500, "No board found")
+		}
+		t.BoardID = defaultID
+	}
+	if t.Title == "" {
+		return invalidField("title", "is required")
+	}
+	if t.BoardID == "" {
+		return invalidField("board_id", "is required")
+	}
+	if t.ListID == "" {
+		t.ListID = "todo"
+		if b, err := loadBoard(t.BoardID); err == nil && b.Settings.DefaultListID != "" {
+			t.ListID = b.Settings.DefaultListID
+		}
+	}
+	if t.Priority == "" {
+		t.Priority = PriorityMedium
+	}
+	if err := validateTaskMeta(t); err != nil {
+		return badRequest(err)
+	}
+	if err := validateAssignee(t.AssigneeID); err != nil {
+		return err
+	}
+	if t.ParentID != nil {
+		if err := validateParent(0, *t.ParentID, t.BoardID); err != nil {
+			return badRequest(err)
+		}
+	}
+	values, err := applyCustomValues(t.BoardID, nil, t.CustomFields, true)
+	if err != nil {
+		return badRequest(err)
+	}
+	t.CustomFields = values
+	t.CompletedAt = nil
+	if listCategory(t.BoardID, t.ListID) == ListDone {
+		now := time.Now()
+		t.CompletedAt = &now
+	}
+
+	var id int
+	err = db.QueryRow(context.Background(),
+		`INSERT INTO tasks (board_id, title, description, list_id, position, assignee_id, priority, start_date, due_date, estimate, estimate_unit, completed_at, parent_id, custom_fields)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14) RETURNING id`,
+		t.BoardID, t.Title, t.Description, t.ListID, t.Position, t.AssigneeID,
+		t.Priority, t.StartDate, t.DueDate, t.Estimate, t.EstimateUnit, t.CompletedAt, t.ParentID, t.CustomFields).Scan(&id)
+	if err != nil {
+		return err
+	}
+	t.ID = id
+	if embedFor(c, 1) {
+		go updateEmbedding(id, taskEmbeddingText(t))
+	}
+	emitEvent(t.BoardID, EventTaskCreated, &id, actor, t)
+	if t.ParentID != nil {
+		go logActivity(*t.ParentID, actor, "subtask_added", fmt.Sprintf("Added subtask #%d %s", id, t.Title))
+		emitProgress(*t.ParentID, actor)
+	}
+	return c.JSON(t)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return "none"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func updateTask(c *fiber.Ctx) error {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+
+	oldTask, err := loadTask(id)
+	if err != nil {
+		return fiber.NewError(404, "Task not found")
+	}
+
+	newTask := new(Task)
+	if err := parseBody(c, newTask); err != nil {
+		return err
+	}
+	userID, err := actorOr(c, newTask.UpdatedBy)
+	if err != nil {
+		return err
+	}
+	// Missing fields keep their value; metadata fields sent as explicit null
+	// are cleared.
+	var raw map[string]json.RawMessage
+	json.Unmarshal(c.Body(), &raw)
+	cleared := func(key string) bool {
+		v, ok := raw[key]
+		return ok && string(v) == "null"
+	}
+
+	// Preserve existing values if fields are empty/missing
+	if newTask.BoardID == "" {
+		newTask.BoardID = oldTask.BoardID
+	}
+	if newTask.Title == "" {
+		newTask.Title = oldTask.Title
+	}
+	if newTask.Description == "" {
+		newTask.Description = oldTask.Description
+	}
+	if newTask.ListID == "" {
+		newTask.ListID = oldTask.ListID
+	}
+	if newTask.AssigneeID == nil {
+		newTask.AssigneeID = oldTask.AssigneeID
+	}
+	if newTask.Priority == "" {
+		newTask.Priority = oldTask.Priority
+	}
+	if newTask.StartDate == nil && !cleared("start_date") {
+		newTask.StartDate = oldTask.StartDate
+	}
+	if newTask.DueDate == nil && !cleared("due_date") {
+		newTask.DueDate = oldTask.DueDate
+	}
+	if newTask.Estimate == nil && !cleared("estimate") {
+		newTask.Estimate = oldTask.Estimate
+		if newTask.EstimateUnit == "" {
+			newTask.EstimateUnit = oldTask.EstimateUnit
+		}
+	}
+	if newTask.Estimate == nil {
+		newTask.EstimateUnit = ""
+	}
+	if newTask.ParentID == nil && !cleared("parent_id") {
+		newTask.ParentID = oldTask.ParentID
+	}
+	// Custom field values are merged key by key; values from another board
+	// do not carry over.
+	current := oldTask.CustomFields
+	if newTask.BoardID != oldTask.BoardID {
+		current = nil
+	}
+	if newTask.CustomFields, err = applyCustomValues(newTask.BoardID, current, newTask.CustomFields, false); err != nil {
+		return badRequest(err)
+	}
+	if err := validateTaskMeta(newTask); err != nil {
+		return badRequest(err)
+	}
+	if stringValue(newTask.AssigneeID) != stringValue(oldTask.AssigneeID) {
+		if err := validateAssignee(newTask.AssigneeID); err != nil {
+			return err
+		}
+	}
+	if newTask.ParentID != nil && (oldTask.ParentID == nil || *newTask.ParentID != *oldTask.ParentID || newTask.BoardID != oldTask.BoardID) {
+		if err := validateParent(id, *newTask.ParentID, newTask.BoardID); err != nil {
+			return badRequest(err)
+		}
+	}
+	if newTask.ListID != oldTask.ListID || newTask.BoardID != oldTask.BoardID {
+		if b, err := loadBoard(newTask.BoardID); err == nil {
+			if newTask.BoardID == oldTask.BoardID && !b.Settings.AllowsMove(oldTask.ListID, newTask.ListID) {
+				return fiber.NewError(409, fmt.Sprintf("Moving from %s to %s is not allowed on this board", oldTask.ListID, newTask.ListID))
+			}
+			if b.Settings.RequireSubtasksDone && listCategory(newTask.BoardID, newTask.ListID) == ListDone {
+				if n := openSubtasks(id); n > 0 {
+					return fiber.NewError(409, fmt.Sprintf("Task has %d open subtask(s) and cannot be completed yet", n))
+				}
+			}
+		}
+	}
+
+	// completed_at tracks entry into a done-category list.
+	newTask.CompletedAt = oldTask.CompletedAt
+	if listCategory(newTask.BoardID, newTask.ListID) == ListDone {
+		if newTask.CompletedAt == nil {
+			now := time.Now()
+			newTask.CompletedAt = &now
+		}
+	} else {
+		newTask.CompletedAt = nil
+	}
+
+	// We keep position as is if it's 0 (might be intentional move to top),
+	// but generally frontend sends it. Let's assume if it's 0 and not explicitly set, keep old?
+	// No, 0 is valid. Let's trust frontend or keep logic simple.
+	// Actually, Go structs default to 0/empty.
+	// To truly distinguish "unset" vs "empty", we'd need pointer fields.
+	// For MVP, if Title is empty, assume we keep old one.
+
+	_, err = db.Exec(context.Background(),
+		`UPDATE tasks SET title=$1, description=$2, list_id=$3, position=$4, assignee_id=$5, board_id=$6,
+			priority=$7, start_date=$8, due_date=$9, estimate=$10, estimate_unit=NULLIF($11, ''), completed_at=$12,
+			overdue_notified_at = CASE WHEN due_date IS DISTINCT FROM $9 THEN NULL ELSE overdue_notified_at END,
+			parent_id=$14, custom_fields=$15
+		WHERE id=$13`,
+		newTask.Title, newTask.Description, newTask.ListID, newTask.Position, newTask.AssigneeID, newTask.BoardID,
+		newTask.Priority, newTask.StartDate, newTask.DueDate, newTask.Estimate, newTask.EstimateUnit, newTask.CompletedAt, id,
+		newTask.ParentID, newTask.CustomFields)
+	if err != nil {
+		return err
+	}
+
+	if newTask.ListID != oldTask.ListID {
+		go logActivity(id, userID, "moved", fmt.Sprintf("Moved to list %s", newTask.ListID))
+	}
+
+	newAssignee, oldAssignee := "", ""
+	if newTask.AssigneeID != nil {
+		newAssignee = *newTask.AssigneeID
+	}
+	if oldTask.AssigneeID != nil {
+		oldAssignee = *oldTask.AssigneeID
+	}
+
+	if newAssignee != oldAssignee {
+		if newAssignee != "" {
+			go logActivity(id, userID, "assigned", fmt.Sprintf("Assigned to %s", newAssignee))
+		} else {
+			go logActivity(id, userID, "unassigned", "Removed assignee")
+		}
+	}
+
+	if newTask.Description != oldTask.Description {
+		go logActivity(id, userID, "updated", "Updated task description")
+	}
+
+	if newTask.Priority != oldTask.Priority {
+		go logActivity(id, userID, "priority", fmt.Sprintf("Priority changed from %s to %s", oldTask.Priority, newTask.Priority))
+	}
+	if formatDate(newTask.DueDate) != formatDate(oldTask.DueDate) {
+		go logActivity(id, userID, "due_date", fmt.Sprintf("Due date set to %s", formatDate(newTask.DueDate)))
+	}
+	if formatDate(newTask.StartDate) != formatDate(oldTask.StartDate) {
+		go logActivity(id, userID, "start_date", fmt.Sprintf("Start date set to %s", formatDate(newTask.StartDate)))
+	}
+	for key, v := range newTask.CustomFields {
+		if formatFieldValue(v) != formatFieldValue(oldTask.CustomFields[key]) {
+			go logActivity(id, userID, "custom_field", fmt.Sprintf("Set %s to %s", key, formatFieldValue(v)))
+		}
+	}
+	for key := range oldTask.CustomFields {
+		if _, ok := newTask.CustomFields[key]; !ok {
+			go logActivity(id, userID, "custom_field", fmt.Sprintf("Cleared %s", key))
+		}
+	}
+
+	newTask.ID = id
+	if taskEmbeddingText(newTask) != taskEmbeddingText(&oldTask) && embedFor(c, 1) {
+		go updateEmbedding(id, taskEmbeddingText(newTask))
+	}
+	emitEvent(newTask.BoardID, EventTaskUpdated, &id, userID, newTask)
+	if newTask.BoardID != oldTask.BoardID {
+		emitEvent(oldTask.BoardID, EventTaskUpdated, &id, userID, newTask)
+	}
+
+	if newTask.CompletedAt != nil && oldTask.CompletedAt == nil {
+		notifyUnblocked(dependentsOf(id), id, userID)
+	}
+
+	// Keep parents' progress roll-up current.
+	oldParent, newParent := 0, 0
+	if oldTask.ParentID != nil {
+		oldParent = *oldTask.ParentID
+	}
+	if newTask.ParentID != nil {
+		newParent = *newTask.ParentID
+	}
+	if oldParent != newParent {
+		if oldParent != 0 {
+			go logActivity(oldParent, userID, "subtask_removed", fmt.Sprintf("Removed subtask #%d %s", id, newTask.Title))
+			emitProgress(oldParent, userID)
+		}
+		if newParent != 0 {
+			go logActivity(newParent, userID, "subtask_added", fmt.Sprintf("Added subtask #%d %s", id, newTask.Title))
+			emitProgress(newParent, userID)
+		}
+	} else if newParent != 0 && (newTask.CompletedAt == nil) != (oldTask.CompletedAt == nil) {
+		verb := "reopened"
+		if newTask.CompletedAt != nil {
+			verb = "completed"
+		}
+		go logActivity(newParent, userID, "subtask_"+verb, fmt.Sprintf("Subtask #%d %s %s", id, newTask.Title, verb))
+		emitProgress(newParent, userID)
+	}
+	return c.JSON(newTask)
+}
+
+// startOverdueWatcher periodically flags open tasks whose due date has passed,
+// logging an activity and emitting task.overdue once per due date.
+func startOverdueWatcher() {
+	interval := 5 * time.Minute
+	if v, err := time.ParseDuration(os.Getenv("OVERDUE_CHECK_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	go func() {
+		for {
+			checkOverdueTasks()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func checkOverdueTasks() {
+	rows, err := db.Query(context.Background(), `
+		UPDATE tasks t SET overdue_notified_at = CURRENT_TIMESTAMP
+		WHERE t.due_date < CURRENT_TIMESTAMP AND t.completed_at IS NULL AND t.overdue_notified_at IS NULL
+		RETURNING `+taskColumns)
+	if err != nil {
+		log.Printf("Overdue check err: %v", err)
+		return
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		log.Printf("Overdue check err: %v", err)
+		return
+	}
+	for _, t := range tasks {
+		id := t.ID
+		logActivity(id, "system", "overdue", fmt.Sprintf("Task is overdue (due %s)", formatDate(t.DueDate)))
+		emitEvent(t.BoardID, EventTaskOverdue, &id, "system", t)
+	}
+}
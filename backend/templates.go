@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates/*.yaml
+var builtinTemplates embed.FS
+
+// BoardTemplate describes a board to stamp out: its lists, settings, default
+// members and starter content. Templates are stored as JSON and may be
+// exchanged as JSON or YAML.
+type BoardTemplate struct {
+	Name        string            `json:"name" yaml:"name"`
+	Title       string            `json:"title" yaml:"title"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Settings    BoardSettings     `json:"settings" yaml:"settings,omitempty"`
+	Lists       []List            `json:"lists" yaml:"lists"`
+	Members     []BoardMemberReq  `json:"members,omitempty" yaml:"members,omitempty"`
+	Labels      []LabelSpec       `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Fields      []CustomFieldSpec `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Tasks       []TemplateTask    `json:"tasks,omitempty" yaml:"tasks,omitempty"`
+	Docs        []TemplateDoc     `json:"docs,omitempty" yaml:"docs,omitempty"`
+}
+
+type TemplateTask struct {
+	Title       string   `json:"title" yaml:"title"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	ListID      string   `json:"list_id" yaml:"list_id"`
+	Position    int      `json:"position,omitempty" yaml:"position,omitempty"`
+	AssigneeID  *string  `json:"assignee_id,omitempty" yaml:"assignee_id,omitempty"`
+	Labels      []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Checklist   []string `json:"checklist,omitempty" yaml:"checklist,omitempty"`
+}
+
+type TemplateDoc struct {
+	Title   string `json:"title" yaml:"title"`
+	Content string `json:"content" yaml:"content"`
+}
+
+type TemplateSummary struct {
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TemplateFromBoardReq struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	IncludeTasks bool   `json:"include_tasks"`
+	IncludeDocs  bool   `json:"include_docs"`
+}
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+func validRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+func validateTemplate(t *BoardTemplate) error {
+	if !templateNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, '-' or '_'")
+	}
+	if strings.TrimSpace(t.Title) == "" {
+		t.Title = t.Name
+	}
+	if len(t.Lists) == 0 {
+		t.Lists = append([]List(nil), defaultLists...)
+	}
+	listIDs, err := normalizeLists(t.Lists)
+	if err != nil {
+		return err
+	}
+	if err := validateSettings(t.Settings, listIDs); err != nil {
+		return err
+	}
+	for i := range t.Members {
+		if t.Members[i].Role == "" {
+			t.Members[i].Role = RoleEditor
+		}
+		if t.Members[i].MemberID == "" || !validRole(t.Members[i].Role) {
+			return fmt.Errorf("members[%d]: member_id is required and role must be owner, editor or viewer", i)
+		}
+	}
+	if err := normalizeLabels(t.Labels); err != nil {
+		return err
+	}
+	if err := normalizeFields(t.Fields); err != nil {
+		return err
+	}
+	labelNames := map[string]bool{}
+	for _, l := range t.Labels {
+		labelNames[strings.ToLower(l.Name)] = true
+	}
+	for i := range t.Tasks {
+		for _, name := range t.Tasks[i].Labels {
+			if !labelNames[strings.ToLower(name)] {
+				return fmt.Errorf("tasks[%d]: label %q is not defined", i, name)
+			}
+		}
+		if strings.TrimSpace(t.Tasks[i].Title) == "" {
+			return fmt.Errorf("tasks[%d]: title is required", i)
+		}
+		if t.Tasks[i].ListID == "" {
+			t.Tasks[i].ListID = t.Lists[0].ID
+		}
+		if !listIDs[t.Tasks[i].ListID] {
+			return fmt.Errorf("tasks[%d]: list %q is not defined", i, t.Tasks[i].ListID)
+		}
+	}
+	for i := range t.Docs {
+		if strings.TrimSpace(t.Docs[i].Title) == "" {
+			return fmt.Errorf("docs[%d]: title is required", i)
+		}
+	}
+	return nil
+}
+
+func wantsYAML(c *fiber.Ctx) bool {
+	return c.Query("format") == "yaml" || strings.Contains(c.Get("Accept"), "yaml")
+}
+
+// parseSpec decodes a YAML or JSON request body depending on Content-Type.
+func parseSpec(c *fiber.Ctx, v interface{}) error {
+	if strings.Contains(c.Get("Content-Type"), "yaml") {
+		return yaml.Unmarshal(c.Body(), v)
+	}
+	return json.Unmarshal(c.Body(), v)
+}
+
+// sendSpec encodes v as YAML when the client asks for it, JSON otherwise.
+func sendSpec(c *fiber.Ctx, v interface{}) error {
+	if !wantsYAML(c) {
+		return c.JSON(v)
+	}
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Set("Content-Type", "application/yaml")
+	return c.Send(out)
+}
+
+func loadTemplate(name string) (BoardTemplate, error) {
+	var t BoardTemplate
+	err := db.QueryRow(context.Background(), "SELECT spec FROM board_templates WHERE name=$1", name).Scan(&t)
+	return t, err
+}
+
+func saveTemplate(t *BoardTemplate, createdBy string, replace bool) (bool, error) {
+	query := `INSERT INTO board_templates (name, title, description, spec, created_by) VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (name) DO NOTHING`
+	if replace {
+		query = `INSERT INTO board_templates (name, title, description, spec, created_by) VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			ON CONFLICT (name) DO UPDATE SET title=$2, description=$3, spec=$4, updated_at=CURRENT_TIMESTAMP`
+	}
+	result, err := db.Exec(context.Background(), query, t.Name, t.Title, t.Description, t, createdBy)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// seedTemplates stores the built-in templates that are not already present.
+func seedTemplates() {
+	files, _ := builtinTemplates.ReadDir("templates")
+	for _, f := range files {
+		data, err := builtinTemplates.ReadFile("templates/" + f.Name())
+		if err != nil {
+			continue
+		}
+		var t BoardTemplate
+		if err := yaml.Unmarshal(data, &t); err != nil {
+			log.Printf("seedTemplates: %s: %v", f.Name(), err)
+			continue
+		}
+		if err := validateTemplate(&t); err != nil {
+			log.Printf("seedTemplates: %s: %v", f.Name(), err)
+			continue
+		}
+		if _, err := saveTemplate(&t, "", false); err != nil {
+			log.Printf("seedTemplates: %s: %v", f.Name(), err)
+		}
+	}
+}
+
+func getTemplates(c *fiber.Ctx) error {
+	page, err := parsePage(c, "name")
+	if err != nil {
+		return err
+	}
+	query := "SELECT name, title, COALESCE(description, ''), COALESCE(created_by, ''), updated_at FROM board_templates"
+	var args []interface{}
+	if page.Cursor != nil {
+		query += " WHERE name > $1"
+		args = append(args, page.Cursor.Key)
+	}
+	rows, err := db.Query(context.Background(), query+" ORDER BY name ASC"+page.limitSQL(), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var templates []TemplateSummary
+	for rows.Next() {
+		var t TemplateSummary
+		if err := rows.Scan(&t.Name, &t.Title, &t.Description, &t.CreatedBy, &t.UpdatedAt); err != nil {
+			return err
+		}
+		templates = append(templates, t)
+	}
+	return sendPage(c, page, templates, func(t TemplateSummary) pageCursor { return cursorAt("name", nil, 0, t.Name) })
+}
+
+func getTemplate(c *fiber.Ctx) error {
+	t, err := loadTemplate(c.Params("name"))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Template not found")
+	}
+	if err != nil {
+		return err
+	}
+	return sendSpec(c, t)
+}
+
+func createTemplate(c *fiber.Ctx) error {
+	// Templates without a creator are built-in and read-only.
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	t := new(BoardTemplate)
+	if err := parseSpec(c, t); err != nil {
+		return badRequest(err)
+	}
+	if err := validateTemplate(t); err != nil {
+		return badRequest(err)
+	}
+	created, err := saveTemplate(t, caller, false)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return fiber.NewError(409, "Template already exists")
+	}
+	return c.Status(201).JSON(t)
+}
+
+// requireTemplateCreator resolves the caller and, if template name exists,
+// checks that they created it. Built-in templates have no creator, so they
+// cannot be changed through the API.
+func requireTemplateCreator(c *fiber.Ctx, name string) (string, bool, error) {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return "", false, err
+	}
+	var createdBy string
+	err = db.QueryRow(context.Background(), "SELECT COALESCE(created_by, '') FROM board_templates WHERE name=$1", name).Scan(&createdBy)
+	if err == pgx.ErrNoRows {
+		return caller, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if createdBy != caller {
+		return "", true, fiber.NewError(403, "Only the template's creator can change it")
+	}
+	return caller, true, nil
+}
+
+func updateTemplate(c *fiber.Ctx) error {
+	caller, _, err := requireTemplateCreator(c, c.Params("name"))
+	if err != nil {
+		return err
+	}
+	t := new(BoardTemplate)
+	if err := parseSpec(c, t); err != nil {
+		return badRequest(err)
+	}
+	t.Name = c.Params("name")
+	if err := validateTemplate(t); err != nil {
+		return badRequest(err)
+	}
+	if _, err := saveTemplate(t, caller, true); err != nil {
+		return err
+	}
+	return c.JSON(t)
+}
+
+func deleteTemplate(c *fiber.Ctx) error {
+	_, exists, err := requireTemplateCreator(c, c.Params("name"))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fiber.NewError(404, "Template not found")
+	}
+	result, err := db.Exec(context.Background(), "DELETE FROM board_templates WHERE name=$1", c.Params("name"))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "Template not found")
+	}
+	return c.SendStatus(200)
+}
+
+// templateFromBoard captures a board's current structure (and optionally its
+// tasks and documents) as a template.
+func templateFromBoard(boardID string, req *TemplateFromBoardReq) (BoardTemplate, error) {
+	ctx := context.Background()
+	b, err := loadBoard(boardID)
+	if err != nil {
+		return BoardTemplate{}, err
+	}
+	t := BoardTemplate{Name: req.Name, Title: b.Title, Description: req.Description, Settings: b.Settings}
+	if t.Description == "" {
+		t.Description = b.Description
+	}
+	if t.Lists, err = loadLists(db, boardID); err != nil {
+		return t, err
+	}
+
+	rows, err := db.Query(ctx, "SELECT member_id, role FROM board_members WHERE board_id=$1 ORDER BY joined_at ASC", boardID)
+	if err != nil {
+		return t, err
+	}
+	for rows.Next() {
+		var m BoardMemberReq
+		if err := rows.Scan(&m.MemberID, &m.Role); err != nil {
+			rows.Close()
+			return t, err
+		}
+		t.Members = append(t.Members, m)
+	}
+	rows.Close()
+
+	labels, err := loadLabels(db, boardID)
+	if err != nil {
+		return t, err
+	}
+	for _, l := range labels {
+		t.Labels = append(t.Labels, LabelSpec{Name: l.Name, Color: l.Color})
+	}
+	fields, err := loadFields(db, boardID)
+	if err != nil {
+		return t, err
+	}
+	for _, f := range fields {
+		t.Fields = append(t.Fields, f.CustomFieldSpec)
+	}
+
+	if req.IncludeTasks {
+		rows, err := db.Query(ctx, `
+			SELECT t.title, COALESCE(t.description, ''), t.list_id, t.position, t.assignee_id,
+				ARRAY(SELECT l.name FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE tl.task_id = t.id ORDER BY lower(l.name)),
+				ARRAY(SELECT ci.text FROM checklist_items ci WHERE ci.task_id = t.id ORDER BY ci.position, ci.id)
+			FROM tasks t WHERE t.board_id=$1 ORDER BY t.list_id, t.position, t.id`, boardID)
+		if err != nil {
+			return t, err
+		}
+		for rows.Next() {
+			var tt TemplateTask
+			if err := rows.Scan(&tt.Title, &tt.Description, &tt.ListID, &tt.Position, &tt.AssigneeID, &tt.Labels, &tt.Checklist); err != nil {
+				rows.Close()
+				return t, err
+			}
+			t.Tasks = append(t.Tasks, tt)
+		}
+		rows.Close()
+	}
+	if req.IncludeDocs {
+		rows, err := db.Query(ctx, "SELECT title, content FROM documents WHERE board_id=$1 ORDER BY id", boardID)
+		if err != nil {
+			return t, err
+		}
+		for rows.Next() {
+			var d TemplateDoc
+			if err := rows.Scan(&d.Title, &d.Content); err != nil {
+				rows.Close()
+				return t, err
+			}
+			t.Docs = append(t.Docs, d)
+		}
+		rows.Close()
+	}
+	return t, nil
+}
+
+func createTemplateFromBoard(c *fiber.Ctx) error {
+	boardID := c.Params("id")
+	caller, err := requireBoardRole(c, boardID, RoleOwner, RoleEditor)
+	if err != nil {
+		return err
+	}
+	req := new(TemplateFromBoardReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	t, err := templateFromBoard(boardID, req)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Board not found")
+	}
+	if err != nil {
+		return err
+	}
+	if err := validateTemplate(&t); err != nil {
+		return badRequest(err)
+	}
+	created, err := saveTemplate(&t, caller, false)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return fiber.NewError(409, "Template already exists")
+	}
+	return c.Status(201).JSON(t)
+}
+
+// instantiateTemplate creates a board from t inside tx, with caller as owner.
+// Template members that no longer exist or are inactive are skipped. It
+// returns the new board and the IDs of the created tasks and documents.
+func instantiateTemplate(tx pgx.Tx, t *BoardTemplate, title, description, caller string) (Board, []int, []int, error) {
+	ctx := context.Background()
+	if title == "" {
+		title = t.Title
+	}
+	if description == "" {
+		description = t.Description
+	}
+	b, err := scanBoard(tx.QueryRow(ctx,
+		"INSERT INTO boards (title, description, settings) VALUES ($1, $2, $3) RETURNING "+boardColumns,
+		title, description, t.Settings))
+	if err != nil {
+		return b, nil, nil, err
+	}
+	if err := insertLists(tx, b.ID, t.Lists); err != nil {
+		return b, nil, nil, err
+	}
+	for _, m := range t.Members {
+		if m.MemberID == caller {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO board_members (board_id, member_id, role)
+			SELECT $1, id, $3 FROM members WHERE id=$2 AND active
+			ON CONFLICT DO NOTHING`, b.ID, m.MemberID, m.Role); err != nil {
+			return b, nil, nil, err
+		}
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO board_members (board_id, member_id, role) VALUES ($1, $2, $3)", b.ID, caller, RoleOwner); err != nil {
+		return b, nil, nil, err
+	}
+	if err := insertLabels(tx, b.ID, t.Labels); err != nil {
+		return b, nil, nil, err
+	}
+	if err := insertFields(tx, b.ID, t.Fields); err != nil {
+		return b, nil, nil, err
+	}
+
+	var taskIDs, docIDs []int
+	for _, tt := range t.Tasks {
+		var id int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO tasks (board_id, title, description, list_id, position, assignee_id)
+			VALUES ($1, $2, $3, $4, $5, (SELECT id FROM members WHERE id=$6 AND active)) RETURNING id`,
+			b.ID, tt.Title, tt.Description, tt.ListID, tt.Position, tt.AssigneeID).Scan(&id)
+		if err != nil {
+			return b, nil, nil, err
+		}
+		for _, name := range tt.Labels {
+			if err := addTaskLabelByName(tx, b.ID, id, name); err != nil {
+				return b, nil, nil, err
+			}
+		}
+		for i, text := range tt.Checklist {
+			if _, err := tx.Exec(ctx, "INSERT INTO checklist_items (task_id, position, text) VALUES ($1, $2, $3)", id, i, text); err != nil {
+				return b, nil, nil, err
+			}
+		}
+		taskIDs = append(taskIDs, id)
+	}
+	for _, d := range t.Docs {
+		var id int
+		err := tx.QueryRow(ctx, "INSERT INTO documents (board_id, title, content) VALUES ($1, $2, $3) RETURNING id",
+			b.ID, d.Title, d.Content).Scan(&id)
+		if err != nil {
+			return b, nil, nil, err
+		}
+		docIDs = append(docIDs, id)
+	}
+	return b, taskIDs, docIDs, nil
+}
+
+func createBoardFromTemplate(c *fiber.Ctx, caller string, req *Board, name string) error {
+	t, err := loadTemplate(name)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "Template not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	b, taskIDs, docIDs, err := instantiateTemplate(tx, &t, req.Title, req.Description, caller)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	if embedFor(c, len(taskIDs)+len(docIDs)) {
+		for i, id := range taskIDs {
+			go updateEmbedding(id, t.Tasks[i].Title+" "+t.Tasks[i].Description)
+		}
+		for i, id := range docIDs {
+			go updateDocEmbedding(id, t.Docs[i].Title+" "+t.Docs[i].Content)
+		}
+	}
+	emitEvent(b.ID, EventBoardCreated, nil, caller, b)
+	return c.JSON(b)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Members can create API tokens for agents and scripts. A request with
+// "Authorization: Bearer <token>" acts as the token's member; requests
+// without one are anonymous. Only a hash of each token is stored; the token
+// itself is returned once, when it is created. A member's first token is
+// issued with "moziboard admin token".
+const tokenPrefix = "mzb_"
+
+// callerLocal is the Locals key under which tokenAuth stores the member.
+const callerLocal = "caller"
+
+type MemberToken struct {
+	ID         int        `json:"id"`
+	MemberID   string     `json:"member_id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type MemberTokenReq struct {
+	Name string `json:"name"`
+}
+
+func (r *MemberTokenReq) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return invalidField("name", "is required")
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the request's API token. Browsers cannot set headers
+// on WebSocket and EventSource requests, so the event streams also take
+// ?access_token=.
+func bearerToken(c *fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if c.Method() == fiber.MethodGet && (c.Path() == "/ws" || strings.HasSuffix(c.Path(), "/stream")) {
+		return c.Query("access_token")
+	}
+	return ""
+}
+
+// tokenAuth resolves a bearer token to its member. Requests without one
+// pass through unchanged; unknown, revoked or deactivated tokens get 401.
+func tokenAuth(c *fiber.Ctx) error {
+	token := bearerToken(c)
+	if token == "" {
+		return c.Next()
+	}
+	var tokenID int
+	var memberID string
+	err := db.QueryRow(context.Background(), `
+		SELECT t.id, t.member_id FROM member_tokens t JOIN members m ON m.id = t.member_id
+		WHERE t.token_hash=$1 AND t.revoked_at IS NULL AND m.active`, hashToken(token)).Scan(&tokenID, &memberID)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="moziboard"`)
+		return fiber.NewError(401, "Invalid or revoked API token")
+	}
+	db.Exec(context.Background(), "UPDATE member_tokens SET last_used_at=CURRENT_TIMESTAMP WHERE id=$1", tokenID)
+	c.Locals(callerLocal, memberID)
+	return c.Next()
+}
+
+// requireSelf checks that the caller is the member named in the path.
+func requireSelf(c *fiber.Ctx) (string, error) {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return "", err
+	}
+	if caller != c.Params("id") {
+		return "", fiber.NewError(403, "Members can only manage their own tokens")
+	}
+	return caller, nil
+}
+
+func getMemberTokens(c *fiber.Ctx) error {
+	memberID, err := requireSelf(c)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(context.Background(), `
+		SELECT id, member_id, name, created_at, last_used_at, revoked_at FROM member_tokens
+		WHERE member_id=$1 ORDER BY created_at, id`, memberID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	tokens := []MemberToken{}
+	for rows.Next() {
+		var t MemberToken
+		if err := rows.Scan(&t.ID, &t.MemberID, &t.Name, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+			return err
+		}
+		tokens = append(tokens, t)
+	}
+	return c.JSON(tokens)
+}
+
+func createMemberToken(c *fiber.Ctx) error {
+	memberID, err := requireSelf(c)
+	if err != nil {
+		return err
+	}
+	req := new(MemberTokenReq)
+	if err := parseBody(c, req); err != nil {
+		return err
+	}
+	t, err := issueMemberToken(memberID, req.Name)
+	if err != nil {
+		return err
+	}
+	return c.Status(201).JSON(t)
+}
+
+// issueMemberToken creates a token for memberID. The returned MemberToken
+// is the only place the token itself appears.
+func issueMemberToken(memberID, name string) (MemberToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return MemberToken{}, err
+	}
+	t := MemberToken{MemberID: memberID, Name: name, Token: tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)}
+	err := db.QueryRow(context.Background(),
+		"INSERT INTO member_tokens (member_id, name, token_hash) VALUES ($1, $2, $3) RETURNING id, created_at",
+		memberID, t.Name, hashToken(t.Token)).Scan(&t.ID, &t.CreatedAt)
+	return t, err
+}
+
+func revokeMemberToken(c *fiber.Ctx) error {
+	memberID, err := requireSelf(c)
+	if err != nil {
+		return err
+	}
+	tokenID, err := paramInt(c, "tid")
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(context.Background(),
+		"UPDATE member_tokens SET revoked_at=COALESCE(revoked_at, CURRENT_TIMESTAMP) WHERE id=$1 AND member_id=$2",
+		tokenID, memberID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "Token not found")
+	}
+	return c.SendStatus(200)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// SavedView is a named task query owned by a member, optionally scoped to a
+// board. Shared views on a board are visible to everyone on it.
+type SavedView struct {
+	ID        int       `json:"id"`
+	MemberID  string    `json:"member_id"`
+	BoardID   *string   `json:"board_id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort"`
+	Shared    bool      `json:"shared"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const viewColumns = "id, member_id, board_id::text, name, query, sort, shared, created_at"
+
+func scanView(row pgx.Row) (SavedView, error) {
+	var v SavedView
+	err := row.Scan(&v.ID, &v.MemberID, &v.BoardID, &v.Name, &v.Query, &v.Sort, &v.Shared, &v.CreatedAt)
+	return v, err
+}
+
+func validateView(v *SavedView, caller string) error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return invalidField("name", "is required")
+	}
+	if v.BoardID != nil && *v.BoardID == "" {
+		v.BoardID = nil
+	}
+	if v.Shared && v.BoardID == nil {
+		return invalidField("shared", "requires a board_id")
+	}
+	if _, _, err := compileQuery(v.Query, caller, nil, nil); err != nil {
+		return invalidField("query", "%v", err)
+	}
+	if _, err := taskOrder(v.Sort); err != nil {
+		return badRequest(err)
+	}
+	return nil
+}
+
+// getViews lists the caller's views plus views shared on their boards;
+// ?board_id= narrows to one board.
+func getViews(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	page, err := parsePage(c, "name")
+	if err != nil {
+		return err
+	}
+	where := "(v.member_id=$1 OR (v.shared AND EXISTS (SELECT 1 FROM board_members bm WHERE bm.board_id = v.board_id AND bm.member_id=$1)))"
+	args := []interface{}{caller}
+	if boardID := c.Query("board_id"); boardID != "" {
+		args = append(args, boardID)
+		where += " AND v.board_id::text=$" + strconv.Itoa(len(args))
+	}
+	var after string
+	if ok, err := page.after(&after); err != nil {
+		return err
+	} else if ok {
+		args = append(args, after, page.Cursor.ID)
+		where += " AND (lower(v.name), v.id) > ($" + strconv.Itoa(len(args)-1) + ", $" + strconv.Itoa(len(args)) + ")"
+	}
+	rows, err := db.Query(context.Background(), "SELECT "+viewColumns+" FROM saved_views v WHERE "+where+" ORDER BY lower(v.name), v.id"+page.limitSQL(), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var views []SavedView
+	for rows.Next() {
+		v, err := scanView(rows)
+		if err != nil {
+			return err
+		}
+		views = append(views, v)
+	}
+	return sendPage(c, page, views, func(v SavedView) pageCursor { return cursorAt("name", strings.ToLower(v.Name), v.ID, "") })
+}
+
+// loadVisibleView loads view :id if the caller owns it or it is shared on a
+// board they belong to.
+func loadVisibleView(c *fiber.Ctx, caller string) (SavedView, error) {
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return SavedView{}, err
+	}
+	v, err := scanView(db.QueryRow(context.Background(), "SELECT "+viewColumns+" FROM saved_views WHERE id=$1", id))
+	if err == pgx.ErrNoRows {
+		return v, fiber.NewError(404, "View not found")
+	}
+	if err != nil {
+		return v, err
+	}
+	if v.MemberID != caller && !(v.Shared && boardRole(*v.BoardID, caller) != "") {
+		return v, fiber.NewError(404, "View not found")
+	}
+	return v, nil
+}
+
+func getView(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	v, err := loadVisibleView(c, caller)
+	if err != nil {
+		return err
+	}
+	return c.JSON(v)
+}
+
+func createView(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	v := new(SavedView)
+	if err := parseBody(c, v); err != nil {
+		return err
+	}
+	if err := validateView(v, caller); err != nil {
+		return err
+	}
+	if v.BoardID != nil && boardRole(*v.BoardID, caller) == "" {
+		return fiber.NewError(403, "Not a member of this board")
+	}
+	saved, err := scanView(db.QueryRow(context.Background(), `
+		INSERT INTO saved_views (member_id, board_id, name, query, sort, shared) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING RETURNING `+viewColumns,
+		caller, v.BoardID, v.Name, v.Query, v.Sort, v.Shared))
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(409, "A view with this name already exists")
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(201).JSON(saved)
+}
+
+// updateView replaces a view's name, query, sort and sharing. Only the owner
+// may change it; the board scope is fixed.
+func updateView(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	v, err := loadVisibleView(c, caller)
+	if err != nil {
+		return err
+	}
+	if v.MemberID != caller {
+		return fiber.NewError(403, "Only the owner can change a view")
+	}
+	id, boardID, createdAt := v.ID, v.BoardID, v.CreatedAt
+	if err := parseBody(c, &v); err != nil {
+		return err
+	}
+	v.ID, v.MemberID, v.BoardID, v.CreatedAt = id, caller, boardID, createdAt
+	if err := validateView(&v, caller); err != nil {
+		return err
+	}
+	_, err = db.Exec(context.Background(), "UPDATE saved_views SET name=$1, query=$2, sort=$3, shared=$4 WHERE id=$5",
+		v.Name, v.Query, v.Sort, v.Shared, v.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(v)
+}
+
+func deleteView(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	id, err := paramInt(c, "id")
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(context.Background(), "DELETE FROM saved_views WHERE id=$1 AND member_id=$2", id, caller)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "View not found")
+	}
+	return c.SendStatus(200)
+}
+
+// getViewTasks runs a saved view. Board views search their board; other
+// views search all non-archived boards. Request filters and ?sort= apply on
+// top of the view.
+func getViewTasks(c *fiber.Ctx) error {
+	caller, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+	v, err := loadVisibleView(c, caller)
+	if err != nil {
+		return err
+	}
+	where := []string{"t.board_id IN (SELECT id FROM boards WHERE archived_at IS NULL)"}
+	var args []interface{}
+	if v.BoardID != nil {
+		where, args = []string{"t.board_id = $1"}, []interface{}{*v.BoardID}
+	}
+	sortBy := c.Query("sort", v.Sort)
+	page, err := parsePage(c, sortBy)
+	if err != nil {
+		return err
+	}
+	page.capUnpaged(unpagedQueryResults)
+	tasks, err := findTasks(c, where, args, v.Query, sortBy, page)
+	if err != nil {
+		return err
+	}
+	return sendPage(c, page, tasks, taskCursor(sortBy))
+}
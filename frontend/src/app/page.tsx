@@ -1,21 +1,38 @@
 'use client';
 
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import useSWR, { mutate } from 'swr';
 import Link from 'next/link';
-import { Plus, Layout } from 'lucide-react';
+import { Plus, Layout, KeyRound } from 'lucide-react';
+import { apiFetch, getToken, setToken } from '@/lib/auth';
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 export default function Dashboard() {
   const { data: boards } = useSWR('/api/boards', fetcher);
   const [isCreating, setIsCreating] = useState(false);
   const [newTitle, setNewTitle] = useState('');
   const [newDesc, setNewDesc] = useState('');
+  const [signedIn, setSignedIn] = useState(false);
+
+  useEffect(() => setSignedIn(getToken() !== null), []);
+
+  const handleSignIn = () => {
+    if (signedIn) {
+      setToken(null);
+      setSignedIn(false);
+      return;
+    }
+    const token = window.prompt('API token (from `moziboard admin token <member-id>`)');
+    if (token?.trim()) {
+      setToken(token.trim());
+      setSignedIn(true);
+    }
+  };
 
   const handleCreate = async (e: React.FormEvent) => {
     e.preventDefault();
-    await fetch('/api/boards', {
+    await apiFetch('/api/boards', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ title: newTitle, description: newDesc }),
@@ -35,12 +52,20 @@ export default function Dashboard() {
             </div>
             <h1 className="text-2xl font-bold tracking-tight">Moziboard Dashboard</h1>
         </div>
-        <button 
-            onClick={() => setIsCreating(true)}
-            className="flex items-center gap-2 rounded-lg bg-black px-4 py-2 text-sm font-medium text-white hover:bg-gray-800 dark:bg-white dark:text-black"
-        >
-            <Plus size={16} /> New Project
-        </button>
+        <div className="flex items-center gap-2">
+            <button
+                onClick={handleSignIn}
+                className="flex items-center gap-2 rounded-lg border px-4 py-2 text-sm font-medium hover:bg-gray-100 dark:border-zinc-700 dark:hover:bg-zinc-800"
+            >
+                <KeyRound size={16} /> {signedIn ? 'Sign out' : 'Sign in'}
+            </button>
+            <button 
+                onClick={() => setIsCreating(true)}
+                className="flex items-center gap-2 rounded-lg bg-black px-4 py-2 text-sm font-medium text-white hover:bg-gray-800 dark:bg-white dark:text-black"
+            >
+                <Plus size={16} /> New Project
+            </button>
+        </div>
       </div>
 
       <div className="container mx-auto p-8">
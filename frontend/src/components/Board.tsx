@@ -21,6 +21,7 @@ import { ListContainer } from './ListContainer';
 import { TaskCard } from './TaskCard';
 import { TaskDetailModal } from './TaskDetailModal';
 import { SearchBar } from './SearchBar';
+import { apiFetch, getToken } from '@/lib/auth';
 
 export type Task = {
   id: string | number;
@@ -38,7 +39,7 @@ export type ListType = {
   tasks: Task[];
 };
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 const defaultLists = [
   { id: 'backlog', title: 'Backlog', tasks: [] },
@@ -66,14 +67,19 @@ export function Board({ boardId }: BoardProps) {
     let ws: WebSocket | null = null;
     let reconnectTimeout: ReturnType<typeof setTimeout>;
     let reconnectDelay = 1000;
+    let lastSeq: number | null = null;
     const MAX_RECONNECT_DELAY = 30000;
 
     function connect() {
       const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-      const wsUrl = `${protocol}//${window.location.host}/ws`;
+      // Resume from the last seen event so nothing is missed across reconnects.
+      const since = lastSeq !== null ? `&since=${lastSeq}` : '';
+      // Browsers cannot set headers on WebSocket requests.
+      const token = encodeURIComponent(getToken() ?? '');
+      const wsUrl = `${protocol}//${window.location.host}/ws?board_id=${boardId}${since}`;
 
       console.log("Connecting to WS:", wsUrl);
-      ws = new WebSocket(wsUrl);
+      ws = new WebSocket(`${wsUrl}&access_token=${token}`);
 
       ws.onopen = () => {
         console.log("✅ WS Connected");
@@ -81,8 +87,12 @@ export function Board({ boardId }: BoardProps) {
       };
       ws.onmessage = (event) => {
         console.log("📩 WS Update:", event.data);
-        if (event.data === "UPDATE") {
+        try {
+          const ev = JSON.parse(event.data);
+          if (typeof ev.seq === 'number') lastSeq = ev.seq;
           mutate(`/api/boards/${boardId}/tasks`);
+        } catch {
+          // Ignore non-JSON frames
         }
       };
       ws.onclose = () => {
@@ -120,7 +130,7 @@ export function Board({ boardId }: BoardProps) {
   );
 
   async function updateTask(task: Task) {
-    await fetch(`/api/tasks/${task.id}`, {
+    await apiFetch(`/api/tasks/${task.id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ ...task, board_id: boardId }),
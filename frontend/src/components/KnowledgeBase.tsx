@@ -3,8 +3,9 @@
 import React, { useState, useCallback } from 'react';
 import useSWR, { mutate } from 'swr';
 import { Plus, FileText, Trash2, Save, X, ChevronLeft, Search } from 'lucide-react';
+import { apiFetch } from '@/lib/auth';
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 type Doc = {
     id: number;
@@ -38,7 +39,7 @@ export function KnowledgeBase({ boardId }: KnowledgeBaseProps) {
         }
         setIsSearching(true);
         try {
-            const res = await fetch(`/api/docs/search?q=${encodeURIComponent(q)}&board_id=${boardId}`);
+            const res = await apiFetch(`/api/docs/search?q=${encodeURIComponent(q)}&board_id=${boardId}`);
             const data = await res.json();
             setSearchResults(data);
         } catch {
@@ -57,7 +58,7 @@ export function KnowledgeBase({ boardId }: KnowledgeBaseProps) {
 
     const handleCreate = async () => {
         if (!newTitle.trim()) return;
-        const res = await fetch(`/api/boards/${boardId}/docs`, {
+        const res = await apiFetch(`/api/boards/${boardId}/docs`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ title: newTitle.trim(), content: '' }),
@@ -72,7 +73,7 @@ export function KnowledgeBase({ boardId }: KnowledgeBaseProps) {
 
     const handleSave = async () => {
         if (!selectedDoc) return;
-        const res = await fetch(`/api/docs/${selectedDoc.id}`, {
+        const res = await apiFetch(`/api/docs/${selectedDoc.id}`, {
             method: 'PUT',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ title: editTitle, content: editContent }),
@@ -85,7 +86,7 @@ export function KnowledgeBase({ boardId }: KnowledgeBaseProps) {
 
     const handleDelete = async (docId: number) => {
         if (!confirm('Delete this document?')) return;
-        await fetch(`/api/docs/${docId}`, { method: 'DELETE' });
+        await apiFetch(`/api/docs/${docId}`, { method: 'DELETE' });
         mutate(`/api/boards/${boardId}/docs`);
         if (selectedDoc?.id === docId) {
             setSelectedDoc(null);
@@ -5,6 +5,7 @@ import { TaskCard } from './TaskCard';
 import { ListType, Task } from './Board';
 import { mutate } from 'swr';
 import { Plus, X } from 'lucide-react';
+import { apiFetch } from '@/lib/auth';
 
 interface ListContainerProps {
   list: ListType;
@@ -23,7 +24,7 @@ export function ListContainer({ list, boardId, onTaskClick }: ListContainerProps
   const handleAddTask = async () => {
     if (!newTitle.trim()) return;
 
-    await fetch('/api/tasks', {
+    await apiFetch('/api/tasks', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -1,6 +1,7 @@
 import React, { useState } from 'react';
 import useSWR, { mutate } from 'swr';
 import { X, Plus, Trash2, UserPlus } from 'lucide-react';
+import { apiFetch } from '@/lib/auth';
 
 interface MemberManagerProps {
   boardId: string;
@@ -8,7 +9,7 @@ interface MemberManagerProps {
   onClose: () => void;
 }
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 export function MemberManager({ boardId, isOpen, onClose }: MemberManagerProps) {
   const { data: boardMembers } = useSWR(`/api/boards/${boardId}/members`, fetcher);
@@ -25,7 +26,7 @@ export function MemberManager({ boardId, isOpen, onClose }: MemberManagerProps)
 
   const handleInvite = async () => {
     if (!selectedMember) return;
-    await fetch(`/api/boards/${boardId}/members`, {
+    await apiFetch(`/api/boards/${boardId}/members`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ member_id: selectedMember, role: 'editor' }),
@@ -36,7 +37,7 @@ export function MemberManager({ boardId, isOpen, onClose }: MemberManagerProps)
 
   const handleRemove = async (memberId: string) => {
     if (!confirm("Are you sure you want to remove this member?")) return;
-    await fetch(`/api/boards/${boardId}/members/${memberId}`, {
+    await apiFetch(`/api/boards/${boardId}/members/${memberId}`, {
       method: 'DELETE',
     });
     mutate(`/api/boards/${boardId}/members`);
@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { Search } from 'lucide-react';
 import { Task } from './Board';
+import { apiFetch } from '@/lib/auth';
 
 interface SearchBarProps {
   onTaskSelect: (task: Task) => void;
@@ -22,7 +23,7 @@ export function SearchBar({ onTaskSelect }: SearchBarProps) {
 
       setLoading(true);
       try {
-        const res = await fetch(`/api/search?q=${encodeURIComponent(query)}`);
+        const res = await apiFetch(`/api/search?q=${encodeURIComponent(query)}`);
         if (res.ok) {
           const data = await res.json();
           setResults(data || []);
@@ -5,13 +5,14 @@ import { Task } from './Board';
 import clsx from 'clsx';
 import { GripVertical } from 'lucide-react';
 import useSWR from 'swr';
+import { apiFetch } from '@/lib/auth';
 
 interface TaskCardProps {
   task: Task;
   onClick?: () => void;
 }
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 export function TaskCard({ task, onClick }: TaskCardProps) {
   const { setNodeRef, attributes, listeners, transform, transition, isDragging } = useSortable({
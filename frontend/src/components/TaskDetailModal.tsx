@@ -2,6 +2,7 @@ import React, { useEffect, useState, useRef } from 'react';
 import { Task } from './Board';
 import { X, Send, User, MessageCircle } from 'lucide-react';
 import useSWR, { mutate } from 'swr';
+import { apiFetch } from '@/lib/auth';
 
 interface Activity {
   id: number;
@@ -33,7 +34,7 @@ interface TaskDetailModalProps {
   onClose: () => void;
 }
 
-const fetcher = (url: string) => fetch(url).then((res) => res.json());
+const fetcher = (url: string) => apiFetch(url).then((res) => res.json());
 
 export function TaskDetailModal({ task, isOpen, onClose }: TaskDetailModalProps) {
   const [description, setDescription] = useState(task.description || '');
@@ -65,7 +66,7 @@ export function TaskDetailModal({ task, isOpen, onClose }: TaskDetailModalProps)
   };
 
   const handleSave = async () => {
-    await fetch(`/api/tasks/${task.id}`, {
+    await apiFetch(`/api/tasks/${task.id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ ...task, description, assignee_id: assigneeId || null }),
@@ -78,10 +79,10 @@ export function TaskDetailModal({ task, isOpen, onClose }: TaskDetailModalProps)
     if (!commentInput.trim() || isSending) return;
     setIsSending(true);
     try {
-      await fetch(`/api/tasks/${task.id}/comments`, {
+      await apiFetch(`/api/tasks/${task.id}/comments`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ user_id: 'mirza', content: commentInput.trim() }),
+        body: JSON.stringify({ content: commentInput.trim() }),
       });
       setCommentInput('');
       mutate(`/api/tasks/${task.id}/comments`);
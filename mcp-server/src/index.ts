@@ -13,7 +13,7 @@ import { z } from "zod";
 
 // --- Configuration ---
 const PORT = 3005;
-const API_URL = process.env.API_URL || "http://localhost:8080/api";
+const API_URL = process.env.API_URL || "http://localhost:8080/api/v1";
 const MCP_API_KEY = process.env.MCP_API_KEY;
 
 if (!MCP_API_KEY) {
@@ -21,15 +21,37 @@ if (!MCP_API_KEY) {
 }
 
 // --- Schemas ---
+const prioritySchema = z.enum(["low", "medium", "high", "urgent"]);
+
 const listTasksSchema = z.object({
   board_id: z.string().optional().describe("Board ID to list tasks from (defaults to the first board)"),
+  priority: z.string().optional().describe("Comma-separated priorities to include (low, medium, high, urgent)"),
+  assignee: z.string().optional().describe("Comma-separated assignee IDs to include"),
+  overdue: z.boolean().optional().describe("Only open tasks past their due date"),
+  label: z.string().optional().describe("Comma-separated label names; tasks with any of them are included"),
+  blocked: z.boolean().optional().describe("true for only tasks with unfinished blockers, false to exclude them"),
+});
+
+const queryTasksSchema = z.object({
+  q: z.string().describe('Task query, e.g. assignee:kodinger list:doing label:bug due<7d priority>=high "free text"'),
+  sort: z.string().optional().describe("Sort key: position, priority, due_date, start_date, title, created or cf.<key>; prefix '-' for descending"),
+  limit: z.number().optional().describe("Page size (max 500); the result then carries next_cursor"),
+  cursor: z.string().optional().describe("next_cursor from a previous page"),
 });
 
 const createTaskSchema = z.object({
   title: z.string().describe("Task title"),
   description: z.string().optional().describe("Task description"),
-  list_id: z.string().optional().default("todo").describe("List/Status (todo, doing, done)"),
+  list_id: z.string().optional().default("todo").describe("List id on the board (defaults: backlog, todo, doing, done)"),
   assignee_id: z.string().optional().describe("Assignee user ID"),
+  priority: prioritySchema.optional().describe("Priority (defaults to medium)"),
+  start_date: z.string().optional().describe("Start date (RFC 3339)"),
+  due_date: z.string().optional().describe("Due date (RFC 3339)"),
+  estimate: z.number().optional().describe("Estimate in estimate_unit"),
+  estimate_unit: z.enum(["points", "hours"]).optional(),
+  parent_id: z.number().optional().describe("Parent task ID; creates the task as a subtask on the parent's board"),
+  custom_fields: z.record(z.any()).optional().describe("Values for the board's custom fields, by field key"),
+  updated_by: z.string().optional().describe("Agent/User ID creating the task"),
 });
 
 const updateTaskSchema = z.object({
@@ -39,6 +61,46 @@ const updateTaskSchema = z.object({
   list_id: z.string().optional(),
   position: z.number().optional(),
   assignee_id: z.string().optional(),
+  priority: prioritySchema.optional(),
+  start_date: z.string().nullable().optional().describe("Start date (RFC 3339); null clears it"),
+  due_date: z.string().nullable().optional().describe("Due date (RFC 3339); null clears it"),
+  estimate: z.number().nullable().optional().describe("Estimate; null clears it"),
+  estimate_unit: z.enum(["points", "hours"]).optional(),
+  parent_id: z.number().nullable().optional().describe("Parent task ID; null detaches the subtask"),
+  custom_fields: z.record(z.any()).optional().describe("Custom field values by key; null clears a field"),
+  updated_by: z.string().optional().describe("Agent/User ID performing the update"),
+});
+
+const getTaskSchema = z.object({
+  id: z.number().describe("Task ID"),
+});
+
+const linkTasksSchema = z.object({
+  task_id: z.number().describe("Task ID"),
+  type: z.enum(["blocks", "blocked_by", "relates_to", "duplicates"]).describe("Relationship from task_id to target_id"),
+  target_id: z.number().describe("Target task ID"),
+  updated_by: z.string().optional().describe("Agent/User ID creating the link"),
+});
+
+// --- Checklist Schemas ---
+const addChecklistItemSchema = z.object({
+  task_id: z.number().describe("Task ID"),
+  text: z.string().describe("Checklist item text"),
+  assignee_id: z.string().optional().describe("Assignee user ID"),
+  updated_by: z.string().optional().describe("Agent/User ID adding the item"),
+});
+
+const updateChecklistItemSchema = z.object({
+  id: z.number().describe("Checklist item ID"),
+  text: z.string().optional(),
+  done: z.boolean().optional(),
+  assignee_id: z.string().optional().describe("Assignee user ID; empty string clears it"),
+  position: z.number().optional(),
+  updated_by: z.string().optional().describe("Agent/User ID performing the update"),
+});
+
+const toggleChecklistItemSchema = z.object({
+  id: z.number().describe("Checklist item ID"),
   updated_by: z.string().optional().describe("Agent/User ID performing the update"),
 });
 
@@ -107,9 +169,28 @@ function createMcpServer() {
                 type: "string",
                 description: "Board ID to list tasks from (defaults to the first board)",
               },
+              priority: { type: "string", description: "Comma-separated priorities to include (low, medium, high, urgent)" },
+              assignee: { type: "string", description: "Comma-separated assignee IDs to include" },
+              overdue: { type: "boolean", description: "Only open tasks past their due date" },
+              label: { type: "string", description: "Comma-separated label names; tasks with any of them are included" },
+              blocked: { type: "boolean", description: "true for only tasks with unfinished blockers, false to exclude them" },
             },
           },
         },
+        {
+          name: "query_tasks",
+          description: "Find tasks across all boards with the task query language",
+          inputSchema: {
+            type: "object",
+            properties: {
+              q: { type: "string", description: 'Task query, e.g. assignee:kodinger list:doing label:bug due<7d priority>=high "free text"' },
+              sort: { type: "string", description: "Sort key: position, priority, due_date, start_date, title, created or cf.<key>; prefix '-' for descending" },
+              limit: { type: "number", description: "Page size (max 500); the result then carries next_cursor" },
+              cursor: { type: "string", description: "next_cursor from a previous page" },
+            },
+            required: ["q"],
+          },
+        },
         {
           name: "create_task",
           description: "Create a new task",
@@ -118,8 +199,16 @@ function createMcpServer() {
             properties: {
               title: { type: "string", description: "Task title" },
               description: { type: "string", description: "Task description" },
-              list_id: { type: "string", description: "List/Status (todo, doing, done)", default: "todo" },
+              list_id: { type: "string", description: "List id on the board (defaults: backlog, todo, doing, done)", default: "todo" },
               assignee_id: { type: "string", description: "Assignee user ID" },
+              priority: { type: "string", enum: ["low", "medium", "high", "urgent"], description: "Priority (defaults to medium)" },
+              start_date: { type: "string", description: "Start date (RFC 3339)" },
+              due_date: { type: "string", description: "Due date (RFC 3339)" },
+              estimate: { type: "number", description: "Estimate in estimate_unit" },
+              estimate_unit: { type: "string", enum: ["points", "hours"] },
+              parent_id: { type: "number", description: "Parent task ID; creates the task as a subtask on the parent's board" },
+              custom_fields: { type: "object", description: "Values for the board's custom fields, by field key" },
+              updated_by: { type: "string", description: "Agent/User ID creating the task" },
             },
             required: ["title"],
           },
@@ -136,6 +225,81 @@ function createMcpServer() {
               list_id: { type: "string" },
               position: { type: "number" },
               assignee_id: { type: "string" },
+              priority: { type: "string", enum: ["low", "medium", "high", "urgent"] },
+              start_date: { type: ["string", "null"], description: "Start date (RFC 3339); null clears it" },
+              due_date: { type: ["string", "null"], description: "Due date (RFC 3339); null clears it" },
+              estimate: { type: ["number", "null"], description: "Estimate; null clears it" },
+              estimate_unit: { type: "string", enum: ["points", "hours"] },
+              parent_id: { type: ["number", "null"], description: "Parent task ID; null detaches the subtask" },
+              custom_fields: { type: "object", description: "Custom field values by key; null clears a field" },
+              updated_by: { type: "string", description: "Agent/User ID performing the update" },
+            },
+            required: ["id"],
+          },
+        },
+        {
+          name: "get_task",
+          description: "Get a task with its checklist, subtasks and progress",
+          inputSchema: {
+            type: "object",
+            properties: {
+              id: { type: "number", description: "Task ID" },
+            },
+            required: ["id"],
+          },
+        },
+        {
+          name: "link_tasks",
+          description: "Link two tasks, e.g. mark a task as blocked by another",
+          inputSchema: {
+            type: "object",
+            properties: {
+              task_id: { type: "number", description: "Task ID" },
+              type: { type: "string", enum: ["blocks", "blocked_by", "relates_to", "duplicates"], description: "Relationship from task_id to target_id" },
+              target_id: { type: "number", description: "Target task ID" },
+              updated_by: { type: "string", description: "Agent/User ID creating the link" },
+            },
+            required: ["task_id", "type", "target_id"],
+          },
+        },
+        // --- Checklists ---
+        {
+          name: "add_checklist_item",
+          description: "Add a step to a task's checklist",
+          inputSchema: {
+            type: "object",
+            properties: {
+              task_id: { type: "number", description: "Task ID" },
+              text: { type: "string", description: "Checklist item text" },
+              assignee_id: { type: "string", description: "Assignee user ID" },
+              updated_by: { type: "string", description: "Agent/User ID adding the item" },
+            },
+            required: ["task_id", "text"],
+          },
+        },
+        {
+          name: "update_checklist_item",
+          description: "Edit, reorder, assign or check off a checklist item",
+          inputSchema: {
+            type: "object",
+            properties: {
+              id: { type: "number", description: "Checklist item ID" },
+              text: { type: "string" },
+              done: { type: "boolean" },
+              assignee_id: { type: "string", description: "Assignee user ID; empty string clears it" },
+              position: { type: "number" },
+              updated_by: { type: "string", description: "Agent/User ID performing the update" },
+            },
+            required: ["id"],
+          },
+        },
+        {
+          name: "toggle_checklist_item",
+          description: "Flip a checklist item between done and not done",
+          inputSchema: {
+            type: "object",
+            properties: {
+              id: { type: "number", description: "Checklist item ID" },
               updated_by: { type: "string", description: "Agent/User ID performing the update" },
             },
             required: ["id"],
@@ -238,7 +402,7 @@ function createMcpServer() {
 
     try {
       if (name === "list_tasks") {
-        const { board_id } = listTasksSchema.parse(args || {});
+        const { board_id, ...filters } = listTasksSchema.parse(args || {});
         let targetBoardId = board_id;
 
         if (!targetBoardId) {
@@ -250,20 +414,32 @@ function createMcpServer() {
           }
         }
 
-        const tasks = await axios.get(`${API_URL}/boards/${targetBoardId}/tasks`);
+        const tasks = await axios.get(`${API_URL}/boards/${targetBoardId}/tasks`, { params: filters });
         return {
           content: [{ type: "text", text: JSON.stringify(tasks.data, null, 2) }],
         };
       }
 
+      if (name === "query_tasks") {
+        const params = queryTasksSchema.parse(args);
+        const response = await axios.get(`${API_URL}/tasks`, { params });
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
       if (name === "create_task") {
-        const { title, description, list_id, assignee_id } = createTaskSchema.parse(args);
+        const { title, description, list_id, assignee_id, ...meta } = createTaskSchema.parse(args);
 
-        const boards = await axios.get(`${API_URL}/boards`);
-        if (boards.data.length === 0) {
-          throw new Error("No boards found to create task in.");
+        // Subtasks are created on their parent's board.
+        let board_id: string | undefined;
+        if (meta.parent_id === undefined) {
+          const boards = await axios.get(`${API_URL}/boards`);
+          if (boards.data.length === 0) {
+            throw new Error("No boards found to create task in.");
+          }
+          board_id = boards.data[0].id;
         }
-        const board_id = boards.data[0].id;
 
         const payload = {
           board_id,
@@ -271,6 +447,7 @@ function createMcpServer() {
           description,
           list_id,
           assignee_id,
+          ...meta,
         };
 
         const response = await axios.post(`${API_URL}/tasks`, payload);
@@ -287,6 +464,48 @@ function createMcpServer() {
         };
       }
 
+      if (name === "get_task") {
+        const { id } = getTaskSchema.parse(args);
+        const response = await axios.get(`${API_URL}/tasks/${id}`);
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
+      if (name === "link_tasks") {
+        const { task_id, ...link } = linkTasksSchema.parse(args);
+        const response = await axios.post(`${API_URL}/tasks/${task_id}/links`, link);
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
+      // --- Checklists ---
+
+      if (name === "add_checklist_item") {
+        const { task_id, ...item } = addChecklistItemSchema.parse(args);
+        const response = await axios.post(`${API_URL}/tasks/${task_id}/checklist`, item);
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
+      if (name === "update_checklist_item") {
+        const { id, ...updateData } = updateChecklistItemSchema.parse(args);
+        const response = await axios.put(`${API_URL}/checklist/${id}`, updateData);
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
+      if (name === "toggle_checklist_item") {
+        const { id, updated_by } = toggleChecklistItemSchema.parse(args);
+        const response = await axios.post(`${API_URL}/checklist/${id}/toggle`, { updated_by });
+        return {
+          content: [{ type: "text", text: JSON.stringify(response.data, null, 2) }],
+        };
+      }
+
       // --- Knowledge Base / Documents ---
 
       if (name === "list_docs") {
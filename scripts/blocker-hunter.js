@@ -1,7 +1,7 @@
 const axios = require('axios');
 const { spawn } = require('child_process');
 
-const BASE_URL = 'http://localhost:8080/api';
+const BASE_URL = 'http://localhost:8080/api/v1';
 const REPORT_TO_ID = '41434457'; // Telegram ID
 const STALE_THRESHOLD_HOURS = 24;
 
@@ -34,26 +34,16 @@ async function checkBlockers() {
         let staleTasks = [];
 
         for (const board of boards) {
-            // 2. Fetch Tasks
-            const tasksRes = await axios.get(`${BASE_URL}/boards/${board.id}/tasks`);
-            const tasks = tasksRes.data;
+            // 2. Fetch the board snapshot (tasks carry their last activity time)
+            const snapshotRes = await axios.get(`${BASE_URL}/boards/${board.id}/snapshot`);
+            const tasks = snapshotRes.data.tasks;
 
             // Filter 'doing'
             const doingTasks = tasks.filter(t => t.list_id === 'doing');
 
             for (const task of doingTasks) {
-                // 3. Fetch Activities
-                let lastActivityTime = null;
-                try {
-                    const actRes = await axios.get(`${BASE_URL}/tasks/${task.id}/activities`);
-                    const activities = actRes.data; // Sorted by created_at DESC from backend
-
-                    if (activities && activities.length > 0) {
-                        lastActivityTime = new Date(activities[0].created_at);
-                    }
-                } catch (e) {
-                    console.error(`Failed to fetch activities for task ${task.id}`);
-                }
+                // 3. Last Activity
+                const lastActivityTime = task.last_activity_at ? new Date(task.last_activity_at) : null;
 
                 // 4. Check Stale Status
                 let isStale = false;
@@ -1,7 +1,7 @@
 const axios = require('axios');
 const { spawn } = require('child_process');
 
-const BASE_URL = 'http://localhost:8080/api';
+const BASE_URL = 'http://localhost:8080/api/v1';
 // Agents list as per requirement (lowercase for normalized comparison)
 const AGENTS = ['kodinger', 'devo', 'mozi', 'resepsionis', 'mimin'];
 const POLL_INTERVAL = 60000; // 60 seconds
@@ -80,10 +80,10 @@ async function pollTasks() {
 
         let allTasks = [];
 
-        // 2. Fetch tasks for each board
+        // 2. Fetch tasks for each board, leaving out tasks whose blockers are unfinished
         for (const board of boards) {
             try {
-                const tasksResponse = await axios.get(`${BASE_URL}/boards/${board.id}/tasks`);
+                const tasksResponse = await axios.get(`${BASE_URL}/boards/${board.id}/tasks`, { params: { blocked: false } });
                 const tasks = tasksResponse.data;
 
                 if (Array.isArray(tasks)) {
@@ -110,7 +110,10 @@ async function pollTasks() {
             const assignedLower = t.assignee_id.toLowerCase();
             const isAssigned = AGENTS.includes(assignedLower);
 
-            return isTodo && isAssigned;
+            // Older backends ignore the blocked filter; skip blocked tasks here too
+            const isBlocked = Array.isArray(t.blocked_by) && t.blocked_by.length > 0;
+
+            return isTodo && isAssigned && !isBlocked;
         });
 
         if (todoTasks.length > 0) {